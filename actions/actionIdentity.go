@@ -0,0 +1,212 @@
+package actions
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"strings"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/mr-tron/base58"
+)
+
+// Thrown when a transaction doesn't carry a valid Action Identity
+type ActionIdentityError struct {
+	Message string
+}
+
+func (e *ActionIdentityError) Error() string {
+	return fmt.Sprintf("ActionIdentityError: %s", e.Message)
+}
+
+/*
+Action Identity of a transaction, carried by the memo
+`solana-action:<identity>:<reference>:<signature>`.
+
+`Signature` is the identity signature of `<identity>:<reference>`.
+*/
+type ActionIdentity struct {
+	Identity  common.PublicKey
+	Reference Reference
+	Signature []byte
+}
+
+// Message signed by the identity key
+func (id *ActionIdentity) Message() []byte {
+	return []byte(fmt.Sprintf("%s:%s", id.Identity, id.Reference))
+}
+
+// Memo attached to the transaction
+func (id *ActionIdentity) Memo() Memo {
+	return Memo(fmt.Sprintf("%s:%s:%s:%s", SOLANA_ACTIONS_PROTOCOL, id.Identity, id.Reference, base58.Encode(id.Signature)))
+}
+
+/*
+Check the identity signature of the reference.
+
+@throws {ActionIdentityError}
+*/
+func (id *ActionIdentity) Verify() error {
+	if len(id.Signature) != ed25519.SignatureSize || !ed25519.Verify(id.Identity.Bytes(), id.Message(), id.Signature) {
+		return &ActionIdentityError{"invalid identity signature"}
+	}
+	return nil
+}
+
+/*
+Sign `reference` with the identity key of a provider.
+
+@throws {ActionIdentityError}
+*/
+func NewActionIdentity(identity Signer, reference Reference) (*ActionIdentity, error) {
+	id := &ActionIdentity{Identity: identity.PublicKey(), Reference: reference}
+	sig, err := identity.Sign(id.Message())
+	if err != nil {
+		return nil, &ActionIdentityError{err.Error()}
+	}
+	id.Signature = sig
+	return id, nil
+}
+
+/*
+Parse an Action Identity memo.
+
+@throws {ActionIdentityError}
+*/
+func ParseActionIdentityMemo(memo Memo) (*ActionIdentity, error) {
+	parts := strings.Split(string(memo), ":")
+	if len(parts) != 4 || parts[0] != string(SOLANA_ACTIONS_PROTOCOL) {
+		return nil, &ActionIdentityError{"not an action identity memo"}
+	}
+	identity, err := base58.Decode(parts[1])
+	if err != nil || len(identity) != common.PublicKeyLength {
+		return nil, &ActionIdentityError{"invalid identity"}
+	}
+	reference, err := base58.Decode(parts[2])
+	if err != nil || len(reference) != common.PublicKeyLength {
+		return nil, &ActionIdentityError{"invalid reference"}
+	}
+	sig, err := base58.Decode(parts[3])
+	if err != nil {
+		return nil, &ActionIdentityError{"invalid identity signature"}
+	}
+	return &ActionIdentity{
+		Identity:  common.PublicKeyFromBytes(identity),
+		Reference: Reference(common.PublicKeyFromBytes(reference)),
+		Signature: sig,
+	}, nil
+}
+
+/*
+Attach an Action Identity to the instructions of a transaction.
+
+The identity and reference are added as read-only, non-signer keys of the last
+non-memo instruction and the signed memo is appended.
+
+@param instructions - Instructions of the transaction, at least one must not be a memo.
+
+@param identity - Identity key of the provider.
+
+@param reference - Unique reference of the transaction.
+
+@throws {ActionIdentityError}
+*/
+func AddActionIdentity(instructions []types.Instruction, identity Signer, reference Reference) ([]types.Instruction, error) {
+	memoProgramID := common.PublicKeyFromString(MEMO_PROGRAM_ID)
+	target := lastNonMemoInstruction(instructions)
+	if target < 0 {
+		return nil, &ActionIdentityError{"no instruction to attach the identity to"}
+	}
+	id, err := NewActionIdentity(identity, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Instruction, len(instructions), len(instructions)+1)
+	copy(result, instructions)
+	ix := result[target]
+	ix.Accounts = append(append([]types.AccountMeta{}, ix.Accounts...),
+		types.AccountMeta{PubKey: id.Identity},
+		types.AccountMeta{PubKey: common.PublicKey(reference)},
+	)
+	result[target] = ix
+	return append(result, types.Instruction{ProgramID: memoProgramID, Data: []byte(id.Memo())}), nil
+}
+
+/*
+Find and verify the Action Identity of a transaction.
+
+The memo signature must be valid and both the identity and the reference must
+be keys of a non-memo instruction.
+
+@throws {ActionIdentityError}
+*/
+func VerifyActionIdentity(tx *types.Transaction) (*ActionIdentity, error) {
+	instructions, err := decompileInstructions(&tx.Message)
+	if err != nil {
+		return nil, &ActionIdentityError{err.Error()}
+	}
+	memoProgramID := common.PublicKeyFromString(MEMO_PROGRAM_ID)
+
+	var id *ActionIdentity
+	for _, ix := range instructions {
+		if ix.ProgramID != memoProgramID || !strings.HasPrefix(string(ix.Data), string(SOLANA_ACTIONS_PROTOCOL)+":") {
+			continue
+		}
+		if id != nil {
+			return nil, &ActionIdentityError{"multiple identity memos"}
+		}
+		if id, err = ParseActionIdentityMemo(Memo(ix.Data)); err != nil {
+			return nil, err
+		}
+	}
+	if id == nil {
+		return nil, &ActionIdentityError{"missing identity memo"}
+	}
+	if err := id.Verify(); err != nil {
+		return nil, err
+	}
+
+	for _, ix := range instructions {
+		if ix.ProgramID == memoProgramID {
+			continue
+		}
+		var hasIdentity, hasReference bool
+		for _, meta := range ix.Accounts {
+			hasIdentity = hasIdentity || meta.PubKey == id.Identity
+			hasReference = hasReference || meta.PubKey == common.PublicKey(id.Reference)
+		}
+		if hasIdentity && hasReference {
+			return id, nil
+		}
+	}
+	return nil, &ActionIdentityError{"identity and reference are not keys of an instruction"}
+}
+
+type actionIdentityVerifier struct {
+	identity string
+}
+
+/*
+Create a `TransactionVerifier` requiring transactions to carry a valid Action
+Identity matching the `identity` listed in the provider's actions.json.
+
+@throws {ActionIdentityError}
+*/
+func NewActionIdentityVerifier(actionsJson *ActionsJson) (TransactionVerifier, error) {
+	if actionsJson.Identity == "" {
+		return nil, &ActionIdentityError{"actions.json does not list an identity"}
+	}
+	return &actionIdentityVerifier{actionsJson.Identity}, nil
+}
+
+func (v *actionIdentityVerifier) VerifyTransaction(tx *types.Transaction, account common.PublicKey) error {
+	id, err := VerifyActionIdentity(tx)
+	if err != nil {
+		return err
+	}
+	if id.Identity.String() != v.identity {
+		return &ActionIdentityError{fmt.Sprintf("identity %s is not the one listed in actions.json", id.Identity)}
+	}
+	return nil
+}
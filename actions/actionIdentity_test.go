@@ -0,0 +1,105 @@
+package actions_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"solana-actions/actions"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+func TestActionIdentity(t *testing.T) {
+	user := types.NewAccount()
+	identity := types.NewAccount()
+	reference := actions.Reference(types.NewAccount().PublicKey)
+	transfer := system.Transfer(system.TransferParam{From: user.PublicKey, To: types.NewAccount().PublicKey, Amount: 1})
+
+	identified := func(t *testing.T, signer types.Account) *types.Transaction {
+		t.Helper()
+		instructions, err := actions.AddActionIdentity([]types.Instruction{transfer}, actions.NewKeypairSigner(signer), reference)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		return newTestTransaction(user.PublicKey, instructions...)
+	}
+
+	t.Run("attaches the memo and keys", func(t *testing.T) {
+		instructions, err := actions.AddActionIdentity([]types.Instruction{transfer}, actions.NewKeypairSigner(identity), reference)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(instructions) != 2 || instructions[1].ProgramID != common.PublicKeyFromString(actions.MEMO_PROGRAM_ID) {
+			t.Fatalf("expected the memo to be appended, got %d instructions", len(instructions))
+		}
+		keys := instructions[0].Accounts[len(instructions[0].Accounts)-2:]
+		if keys[0].PubKey != identity.PublicKey || keys[1].PubKey != common.PublicKey(reference) || keys[0].IsSigner || keys[1].IsWritable {
+			t.Errorf("expected read-only identity and reference keys, got %+v", keys)
+		}
+		if len(transfer.Accounts) != 2 {
+			t.Error("expected the original instruction to be left untouched")
+		}
+	})
+
+	t.Run("verifies identified transactions", func(t *testing.T) {
+		id, err := actions.VerifyActionIdentity(identified(t, identity))
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if id.Identity != identity.PublicKey || id.Reference != reference {
+			t.Errorf("got %s:%s want %s:%s", id.Identity, id.Reference, identity.PublicKey, reference)
+		}
+	})
+
+	t.Run("rejects forged signatures", func(t *testing.T) {
+		id, _ := actions.NewActionIdentity(actions.NewKeypairSigner(types.NewAccount()), reference)
+		id.Identity = identity.PublicKey
+		memo := types.Instruction{ProgramID: common.PublicKeyFromString(actions.MEMO_PROGRAM_ID), Data: []byte(id.Memo())}
+		if _, err := actions.VerifyActionIdentity(newTestTransaction(user.PublicKey, transfer, memo)); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("rejects memos without the keys", func(t *testing.T) {
+		id, _ := actions.NewActionIdentity(actions.NewKeypairSigner(identity), reference)
+		memo := types.Instruction{ProgramID: common.PublicKeyFromString(actions.MEMO_PROGRAM_ID), Data: []byte(id.Memo())}
+		if _, err := actions.VerifyActionIdentity(newTestTransaction(user.PublicKey, transfer, memo)); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("checks the identity listed in actions.json", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/actions.json" {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(actions.ActionsJson{
+				Rules:    []actions.ActionRuleObject{{PathPattern: "/donate", ApiPath: "/api/donate"}},
+				Identity: identity.PublicKey.String(),
+			})
+		}))
+		defer server.Close()
+
+		link, _ := url.Parse(server.URL + "/api/donate?amount=1")
+		actionsJson, err := actions.FetchActionsJson(link)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		verifier, err := actions.NewActionIdentityVerifier(actionsJson)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+
+		if err := verifier.VerifyTransaction(identified(t, identity), user.PublicKey); err != nil {
+			t.Errorf("err should be nil: %s", err.Error())
+		}
+		if err := verifier.VerifyTransaction(identified(t, types.NewAccount()), user.PublicKey); err == nil {
+			t.Error("expected an error for another identity")
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package actions
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Thrown when an action payload doesn't match the Solana Action spec
+type ActionValidationError struct {
+	Message string
+}
+
+func (e *ActionValidationError) Error() string {
+	return fmt.Sprintf("ActionValidationError: %s", e.Message)
+}
+
+// Creates an interactive action
+func NewAction(icon string, title string, description string, label string) *ActionGetResponse {
+	return &ActionGetResponse{
+		Type:        ACTION_TYPE_ACTION,
+		Icon:        icon,
+		Title:       title,
+		Description: description,
+		Label:       label,
+	}
+}
+
+// Creates the terminal state of an action chain, `label` is shown on the disabled button
+func NewCompletedAction(icon string, title string, description string, label string) *ActionGetResponse {
+	disabled := true
+	return &ActionGetResponse{
+		Type:        ACTION_TYPE_COMPLETED,
+		Icon:        icon,
+		Title:       title,
+		Description: description,
+		Label:       label,
+		Disabled:    &disabled,
+	}
+}
+
+// Creates an action that sends the user to `externalLink` once its transaction is signed
+func NewExternalLinkAction(icon string, title string, description string, label string, externalLink string) *ActionGetResponse {
+	return &ActionGetResponse{
+		Type:         ACTION_TYPE_EXTERNAL_LINK,
+		Icon:         icon,
+		Title:        title,
+		Description:  description,
+		Label:        label,
+		ExternalLink: externalLink,
+	}
+}
+
+// Appends a related action
+func (a *ActionGetResponse) AddLinkedAction(action LinkedAction) {
+	if a.Links == nil {
+		a.Links = &struct {
+			Actions []LinkedAction `json:"actions"`
+		}{}
+	}
+	a.Links.Actions = append(a.Links.Actions, action)
+}
+
+// Buttons of the action: its linked actions, or the action itself when it has none
+func (a *ActionGetResponse) LinkedActions() []LinkedAction {
+	if a.Links != nil && len(a.Links.Actions) > 0 {
+		return a.Links.Actions
+	}
+	return []LinkedAction{{Href: "", Label: a.Label}}
+}
+
+/*
+Resolve the href of a linked action against the action `link`, filling its
+`{name}` parameters with `values`. An empty href resolves to `link`.
+
+@throws {ActionValidationError}
+*/
+func (l *LinkedAction) ResolveHref(link *url.URL, values map[string]string) (*url.URL, error) {
+	href := l.Href
+	if l.Parameters != nil {
+		for _, param := range *l.Parameters {
+			value := values[param.Name]
+			if value == "" && param.Required != nil && *param.Required {
+				return nil, &ActionValidationError{fmt.Sprintf("missing parameter %s", param.Name)}
+			}
+			href = strings.ReplaceAll(href, "{"+param.Name+"}", url.QueryEscape(value))
+		}
+	}
+	if href == "" {
+		return link, nil
+	}
+	resolved, err := link.Parse(href)
+	if err != nil {
+		return nil, &ActionValidationError{fmt.Sprintf("invalid href %q", l.Href)}
+	}
+	return resolved, nil
+}
+
+// Type of the action, `ACTION_TYPE_ACTION` when omitted
+func (a *ActionGetResponse) ActionType() ActionType {
+	if a.Type == "" {
+		return ACTION_TYPE_ACTION
+	}
+	return a.Type
+}
+
+// Reports whether the user can execute the action
+func (a *ActionGetResponse) IsInteractive() bool {
+	if a.ActionType() == ACTION_TYPE_COMPLETED {
+		return false
+	}
+	return a.Disabled == nil || !*a.Disabled
+}
+
+/*
+Check the action against the spec.
+
+@throws {ActionValidationError}
+*/
+func (a *ActionGetResponse) Validate() error {
+	if a.Title == "" {
+		return &ActionValidationError{"missing title"}
+	}
+	if a.Label == "" {
+		return &ActionValidationError{"missing label"}
+	}
+
+	switch a.ActionType() {
+	case ACTION_TYPE_ACTION:
+		if a.ExternalLink != "" {
+			return &ActionValidationError{"only external-link actions may have an externalLink"}
+		}
+	case ACTION_TYPE_COMPLETED:
+		if a.Links != nil && len(a.Links.Actions) > 0 {
+			return &ActionValidationError{"completed actions must not have links"}
+		}
+		if a.Disabled != nil && !*a.Disabled {
+			return &ActionValidationError{"completed actions must be disabled"}
+		}
+		if a.ExternalLink != "" {
+			return &ActionValidationError{"only external-link actions may have an externalLink"}
+		}
+	case ACTION_TYPE_EXTERNAL_LINK:
+		if _, err := a.ExternalLinkURL(); err != nil {
+			return err
+		}
+	default:
+		return &ActionValidationError{fmt.Sprintf("invalid type %q", a.Type)}
+	}
+
+	if a.Links != nil {
+		for _, linked := range a.Links.Actions {
+			if linked.Href == "" {
+				return &ActionValidationError{"linked action missing href"}
+			}
+			if linked.Label == "" {
+				return &ActionValidationError{"linked action missing label"}
+			}
+		}
+	}
+	return nil
+}
+
+/*
+URL a client must open after the transaction of an `external-link` action is signed.
+
+@throws {ActionValidationError}
+*/
+func (a *ActionGetResponse) ExternalLinkURL() (*url.URL, error) {
+	if a.ActionType() != ACTION_TYPE_EXTERNAL_LINK {
+		return nil, &ActionValidationError{"not an external-link action"}
+	}
+	link, err := url.Parse(a.ExternalLink)
+	if err != nil || (link.Scheme != HTTPS_PROTOCOL && link.Scheme != "http") || link.Host == "" {
+		return nil, &ActionValidationError{"externalLink must be an absolute http(s) URL"}
+	}
+	return link, nil
+}
+
+// Links of a POST response ending the chain with a completed action
+func NewCompletedNextAction(icon string, title string, description string, label string) *ActionPostResponseLinks {
+	return NewInlineNextAction(NewCompletedAction(icon, title, description, label))
+}
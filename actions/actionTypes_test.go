@@ -0,0 +1,156 @@
+package actions_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"solana-actions/actions"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActionTypes(t *testing.T) {
+	icon := "https://example.com/icon.png"
+
+	t.Run("completed actions are disabled and not interactive", func(t *testing.T) {
+		action := actions.NewCompletedAction(icon, "Minted", "Your NFT is minted", "Done")
+		if err := action.Validate(); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if action.IsInteractive() {
+			t.Error("expected a completed action not to be interactive")
+		}
+		raw, _ := json.Marshal(action)
+		var payload map[string]any
+		json.Unmarshal(raw, &payload)
+		if payload["type"] != "completed" || payload["disabled"] != true {
+			t.Errorf("got type %v disabled %v want completed true", payload["type"], payload["disabled"])
+		}
+	})
+
+	t.Run("completed actions cannot have links", func(t *testing.T) {
+		action := actions.NewCompletedAction(icon, "Minted", "Your NFT is minted", "Done")
+		action.AddLinkedAction(actions.LinkedAction{Href: "/api/again", Label: "Again"})
+		if err := action.Validate(); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("external-link actions require an absolute http(s) URL", func(t *testing.T) {
+		action := actions.NewExternalLinkAction(icon, "Vote", "Cast your vote", "Vote", "https://example.com/results")
+		link, err := action.ExternalLinkURL()
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if link.Host != "example.com" {
+			t.Errorf("got %s want %s", link.Host, "example.com")
+		}
+
+		for _, externalLink := range []string{"", "/results", "javascript:alert(1)"} {
+			action.ExternalLink = externalLink
+			if err := action.Validate(); err == nil {
+				t.Errorf("expected an error for %q", externalLink)
+			}
+		}
+	})
+
+	t.Run("only external-link actions have an externalLink", func(t *testing.T) {
+		action := actions.NewAction(icon, "Donate", "Donate SOL", "Donate")
+		action.ExternalLink = "https://example.com"
+		if err := action.Validate(); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("actions without type default to action", func(t *testing.T) {
+		action := &actions.ActionGetResponse{Title: "Donate", Label: "Donate"}
+		if action.ActionType() != actions.ACTION_TYPE_ACTION || !action.IsInteractive() {
+			t.Errorf("got %s want an interactive %s", action.ActionType(), actions.ACTION_TYPE_ACTION)
+		}
+		action.Type = "unknown"
+		if err := action.Validate(); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("FetchAction rejects invalid actions", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			action := actions.NewCompletedAction(icon, "Minted", "Your NFT is minted", "Done")
+			if r.URL.Path == "/invalid" {
+				action.AddLinkedAction(actions.LinkedAction{Href: "/api/again", Label: "Again"})
+			}
+			json.NewEncoder(w).Encode(action)
+		}))
+		defer server.Close()
+
+		link, _ := url.Parse(server.URL + "/valid")
+		action, err := actions.FetchAction(link)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if action.ActionType() != actions.ACTION_TYPE_COMPLETED {
+			t.Errorf("got %s want %s", action.ActionType(), actions.ACTION_TYPE_COMPLETED)
+		}
+
+		link, _ = url.Parse(server.URL + "/invalid")
+		if _, err := actions.FetchAction(link); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("FetchAction bounds the response and follows the context", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				<-release
+				return
+			}
+			w.Write([]byte(`{"title":"` + strings.Repeat("a", actions.MAX_ACTION_RESPONSE_BYTES) + `"}`))
+		}))
+		defer server.Close()
+		defer close(release)
+
+		link, _ := url.Parse(server.URL + "/huge")
+		if _, err := actions.FetchAction(link); err == nil || !strings.Contains(err.Error(), "exceeds") {
+			t.Errorf("expected a size error, got %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		link, _ = url.Parse(server.URL + "/slow")
+		var fetchErr *actions.FetchActionError
+		if _, err := actions.FetchActionWithOptions(ctx, link, nil); !errors.As(err, &fetchErr) {
+			t.Errorf("expected a FetchActionError, got %v", err)
+		}
+	})
+
+	t.Run("the action handler does not serve invalid actions", func(t *testing.T) {
+		server := httptest.NewServer(actions.NewActionHandler(&invalidProvider{}))
+		defer server.Close()
+
+		res, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if res.StatusCode != http.StatusInternalServerError {
+			t.Errorf("got %d want %d", res.StatusCode, http.StatusInternalServerError)
+		}
+	})
+}
+
+// Provider returning a completed action with links
+type invalidProvider struct{}
+
+func (p *invalidProvider) GetAction(r *http.Request) (*actions.ActionGetResponse, error) {
+	action := actions.NewCompletedAction("https://example.com/icon.png", "Minted", "Your NFT is minted", "Done")
+	action.AddLinkedAction(actions.LinkedAction{Href: "/api/again", Label: "Again"})
+	return action, nil
+}
+
+func (p *invalidProvider) PostAction(r *http.Request, req actions.ActionPostRequest) (*actions.ActionPostResponse, error) {
+	return nil, &actions.ActionError{Message: "completed"}
+}
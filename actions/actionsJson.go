@@ -0,0 +1,102 @@
+package actions
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+Fetch the actions.json of the host serving an action.
+
+@param link - Any URL of the action host.
+
+@throws {FetchActionError}
+*/
+func FetchActionsJson(link *url.URL) (*ActionsJson, error) {
+	return FetchActionsJsonWithContext(context.Background(), link)
+}
+
+/*
+Fetch the actions.json of the host serving an action, see `FetchActionsJson`.
+
+@param ctx - Cancels the request.
+
+@throws {FetchActionError}
+*/
+func FetchActionsJsonWithContext(ctx context.Context, link *url.URL) (*ActionsJson, error) {
+	actionsJsonURL := &url.URL{Scheme: link.Scheme, Host: link.Host, Path: "/actions.json"}
+	var actionsJson ActionsJson
+	if _, err := requestAction(ctx, http.MethodGet, actionsJsonURL, nil, &actionsJson, nil); err != nil {
+		return nil, err
+	}
+	return &actionsJson, nil
+}
+
+/*
+Map a website URL to its Action API URL with the first matching rule.
+
+`*` in a `pathPattern` matches a single path segment and `**` the rest of the
+path, the matched segments replace the wildcards of the `apiPath` in order.
+The query of `link` is kept unless the `apiPath` has its own.
+
+Returns false when no rule matches.
+*/
+func (aj *ActionsJson) ResolveActionURL(link *url.URL) (*url.URL, bool) {
+	for _, rule := range aj.Rules {
+		pattern, err := link.Parse(rule.PathPattern)
+		if err != nil || pattern.Host != link.Host {
+			continue
+		}
+		captures, ok := matchPathPattern(pattern.Path, link.Path)
+		if !ok {
+			continue
+		}
+		apiURL, err := link.Parse(rule.ApiPath)
+		if err != nil {
+			continue
+		}
+		apiURL.Path = expandPathPattern(apiURL.Path, captures)
+		apiURL.RawPath = ""
+		if apiURL.RawQuery == "" {
+			apiURL.RawQuery = link.RawQuery
+		}
+		return apiURL, true
+	}
+	return nil, false
+}
+
+func matchPathPattern(pattern string, path string) ([]string, bool) {
+	patternSegs := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegs := strings.Split(strings.Trim(path, "/"), "/")
+	var captures []string
+	for i, seg := range patternSegs {
+		if seg == "**" {
+			if i != len(patternSegs)-1 {
+				return nil, false
+			}
+			return append(captures, strings.Join(pathSegs[i:], "/")), true
+		}
+		if i >= len(pathSegs) {
+			return nil, false
+		}
+		if seg == "*" {
+			captures = append(captures, pathSegs[i])
+		} else if seg != pathSegs[i] {
+			return nil, false
+		}
+	}
+	return captures, len(patternSegs) == len(pathSegs)
+}
+
+func expandPathPattern(pattern string, captures []string) string {
+	segs := strings.Split(pattern, "/")
+	for i, seg := range segs {
+		if (seg == "*" || seg == "**") && len(captures) > 0 {
+			segs[i] = captures[0]
+			captures = captures[1:]
+		}
+	}
+	return strings.Join(segs, "/")
+}
@@ -0,0 +1,179 @@
+package actions
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Thrown when an amount is invalid or doesn't fit the decimals of a mint
+type AmountError struct {
+	Message string
+}
+
+func (e *AmountError) Error() string {
+	return fmt.Sprintf("AmountError: %s", e.Message)
+}
+
+// Decimals of SOL, its base unit is the lamport
+const SOL_DECIMALS = 9
+
+// Non-negative integer or decimal number, without sign, exponent or missing digits
+var amountRegexp = regexp.MustCompile(`^\d+(\.\d+)?$`)
+
+/*
+A non-negative decimal quantity of SOL or tokens, `amount` in the Solana Pay spec.
+
+Amounts are exact: they are parsed, formatted and converted to base units
+without going through floats. The zero value is 0.
+*/
+type Amount struct {
+	// The amount is `units / 10^decimals`, without trailing zero decimals
+	units    *big.Int
+	decimals int
+}
+
+/*
+Parse a decimal amount, e.g. `1.25`.
+
+Scientific notation, signs and fractions without a leading digit like `.5` are rejected.
+
+@throws {AmountError}
+*/
+func ParseAmount(value string) (Amount, error) {
+	if !amountRegexp.MatchString(value) {
+		return Amount{}, &AmountError{fmt.Sprintf("invalid amount %q", value)}
+	}
+	whole, fraction, _ := strings.Cut(value, ".")
+	units, _ := new(big.Int).SetString(whole+fraction, 10)
+	return newAmount(units, len(fraction)), nil
+}
+
+// Amount of `units` base units of a mint with `decimals` decimals
+func NewAmountFromBaseUnits(units uint64, decimals uint8) Amount {
+	return newAmount(new(big.Int).SetUint64(units), int(decimals))
+}
+
+// Amount of SOL of `lamports`
+func NewAmountFromLamports(lamports uint64) Amount {
+	return NewAmountFromBaseUnits(lamports, SOL_DECIMALS)
+}
+
+func newAmount(units *big.Int, decimals int) Amount {
+	ten := big.NewInt(10)
+	remainder := new(big.Int)
+	for decimals > 0 {
+		quotient, r := new(big.Int).QuoRem(units, ten, remainder)
+		if r.Sign() != 0 {
+			break
+		}
+		units, decimals = quotient, decimals-1
+	}
+	return Amount{units: units, decimals: decimals}
+}
+
+func (a Amount) int() *big.Int {
+	if a.units == nil {
+		return new(big.Int)
+	}
+	return a.units
+}
+
+// Units of both amounts at the same scale
+func alignAmounts(a Amount, b Amount) (*big.Int, *big.Int, int) {
+	decimals := max(a.decimals, b.decimals)
+	return scaleUnits(a.int(), decimals-a.decimals), scaleUnits(b.int(), decimals-b.decimals), decimals
+}
+
+func scaleUnits(units *big.Int, digits int) *big.Int {
+	return new(big.Int).Mul(units, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil))
+}
+
+// Decimal string of the amount, without trailing zero decimals
+func (a Amount) String() string {
+	s := a.int().String()
+	if a.decimals == 0 {
+		return s
+	}
+	if len(s) <= a.decimals {
+		s = strings.Repeat("0", a.decimals-len(s)+1) + s
+	}
+	return s[:len(s)-a.decimals] + "." + s[len(s)-a.decimals:]
+}
+
+// Decimals needed to write the amount
+func (a Amount) Decimals() int {
+	return a.decimals
+}
+
+func (a Amount) IsZero() bool {
+	return a.int().Sign() == 0
+}
+
+// Returns -1, 0 or +1 when `a` is less than, equal to or greater than `b`
+func (a Amount) Cmp(b Amount) int {
+	x, y, _ := alignAmounts(a, b)
+	return x.Cmp(y)
+}
+
+func (a Amount) Add(b Amount) Amount {
+	x, y, decimals := alignAmounts(a, b)
+	return newAmount(x.Add(x, y), decimals)
+}
+
+/*
+Subtract `b` from `a`.
+
+@throws {AmountError} When `b` is greater than `a`.
+*/
+func (a Amount) Sub(b Amount) (Amount, error) {
+	x, y, decimals := alignAmounts(a, b)
+	if x.Cmp(y) < 0 {
+		return Amount{}, &AmountError{fmt.Sprintf("%s is less than %s", a, b)}
+	}
+	return newAmount(x.Sub(x, y), decimals), nil
+}
+
+// Multiply the amount by `n`, e.g. a unit price by a quantity
+func (a Amount) Mul(n uint64) Amount {
+	return newAmount(new(big.Int).Mul(a.int(), new(big.Int).SetUint64(n)), a.decimals)
+}
+
+/*
+Convert the amount to base units of a mint with `decimals` decimals.
+
+@throws {AmountError} When the amount has more decimals than the mint or overflows a u64.
+*/
+func (a Amount) BaseUnits(decimals uint8) (uint64, error) {
+	if a.decimals > int(decimals) {
+		return 0, &AmountError{fmt.Sprintf("amount %s has more than %d decimals", a, decimals)}
+	}
+	units := scaleUnits(a.int(), int(decimals)-a.decimals)
+	if !units.IsUint64() {
+		return 0, &AmountError{fmt.Sprintf("amount %s overflows the base units", a)}
+	}
+	return units.Uint64(), nil
+}
+
+/*
+Convert an amount of SOL to lamports.
+
+@throws {AmountError}
+*/
+func (a Amount) Lamports() (uint64, error) {
+	return a.BaseUnits(SOL_DECIMALS)
+}
+
+func (a Amount) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalText(text []byte) error {
+	amount, err := ParseAmount(string(text))
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
@@ -0,0 +1,123 @@
+package actions_test
+
+import (
+	"encoding/json"
+	"errors"
+	"solana-actions/actions"
+	"strings"
+	"testing"
+)
+
+func TestAmount(t *testing.T) {
+	t.Run("parses and formats decimal amounts", func(t *testing.T) {
+		for value, expected := range map[string]string{
+			"1":                     "1",
+			"0":                     "0",
+			"1.50":                  "1.5",
+			"0.000000001":           "0.000000001",
+			"10.0":                  "10",
+			"123456789012345678901": "123456789012345678901",
+		} {
+			amount, err := actions.ParseAmount(value)
+			if err != nil {
+				t.Errorf("%s: err should be nil: %s", value, err.Error())
+			} else if amount.String() != expected {
+				t.Errorf("%s: got %s want %s", value, amount, expected)
+			}
+		}
+	})
+
+	t.Run("rejects invalid amounts", func(t *testing.T) {
+		for _, value := range []string{"", "1e9", "1E-3", "-1", "+1", ".5", "1.", "1,5", "0x10", " 1", "NaN"} {
+			var amountErr *actions.AmountError
+			if _, err := actions.ParseAmount(value); !errors.As(err, &amountErr) {
+				t.Errorf("%q: expected an AmountError, got %v", value, err)
+			}
+		}
+	})
+
+	t.Run("converts to lamports and base units", func(t *testing.T) {
+		amount, _ := actions.ParseAmount("1.5")
+		lamports, err := amount.Lamports()
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if lamports != 1_500_000_000 {
+			t.Errorf("got %d want 1500000000", lamports)
+		}
+		units, err := amount.BaseUnits(6)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if units != 1_500_000 {
+			t.Errorf("got %d want 1500000", units)
+		}
+		if actions.NewAmountFromLamports(1).String() != "0.000000001" {
+			t.Errorf("got %s want 0.000000001", actions.NewAmountFromLamports(1))
+		}
+		if actions.NewAmountFromBaseUnits(2_500_000, 6).String() != "2.5" {
+			t.Errorf("got %s want 2.5", actions.NewAmountFromBaseUnits(2_500_000, 6))
+		}
+	})
+
+	t.Run("rejects excess precision and overflows", func(t *testing.T) {
+		precise, _ := actions.ParseAmount("0.0000000001")
+		if _, err := precise.Lamports(); err == nil || !strings.Contains(err.Error(), "more than 9 decimals") {
+			t.Errorf("expected an excess precision error, got %v", err)
+		}
+		huge, _ := actions.ParseAmount("18446744073.709551616")
+		if _, err := huge.Lamports(); err == nil || !strings.Contains(err.Error(), "overflows") {
+			t.Errorf("expected an overflow error, got %v", err)
+		}
+		largest, _ := actions.ParseAmount("18446744073.709551615")
+		if lamports, err := largest.Lamports(); err != nil || lamports != ^uint64(0) {
+			t.Errorf("got %d, %v want the max u64", lamports, err)
+		}
+	})
+
+	t.Run("does checked arithmetic", func(t *testing.T) {
+		a, _ := actions.ParseAmount("1.25")
+		b, _ := actions.ParseAmount("0.75")
+		if sum := a.Add(b); sum.String() != "2" {
+			t.Errorf("got %s want 2", sum)
+		}
+		diff, err := a.Sub(b)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if diff.String() != "0.5" {
+			t.Errorf("got %s want 0.5", diff)
+		}
+		if _, err := b.Sub(a); err == nil {
+			t.Error("expected an error for a negative amount")
+		}
+		if product := b.Mul(3); product.String() != "2.25" {
+			t.Errorf("got %s want 2.25", product)
+		}
+		if a.Cmp(b) != 1 || b.Cmp(a) != -1 || a.Cmp(a.Add(actions.Amount{})) != 0 {
+			t.Error("unexpected comparison")
+		}
+		if !(actions.Amount{}).IsZero() || a.IsZero() {
+			t.Error("unexpected zero check")
+		}
+	})
+
+	t.Run("round-trips through JSON", func(t *testing.T) {
+		var fields struct {
+			Amount actions.Amount `json:"amount"`
+		}
+		if err := json.Unmarshal([]byte(`{"amount":"0.10"}`), &fields); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		raw, err := json.Marshal(fields)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if string(raw) != `{"amount":"0.1"}` {
+			t.Errorf("got %s want {\"amount\":\"0.1\"}", raw)
+		}
+		if err := json.Unmarshal([]byte(`{"amount":"1e3"}`), &fields); err == nil {
+			t.Error("expected an error for scientific notation")
+		}
+	})
+}
@@ -0,0 +1,241 @@
+package actions
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// How dangerous a `RiskFinding` is
+type RiskSeverity string
+
+const (
+	// Worth showing to the user but not on its own a reason to refuse signing
+	RISK_SEVERITY_WARNING RiskSeverity = "warning"
+
+	// Wallets should block the transaction or require an explicit override
+	RISK_SEVERITY_CRITICAL RiskSeverity = "critical"
+)
+
+// Rules reported in a `RiskFinding`
+const (
+	RISK_RULE_SET_AUTHORITY       = "token-set-authority"
+	RISK_RULE_APPROVE             = "token-approve"
+	RISK_RULE_CLOSE_ACCOUNT       = "token-close-account"
+	RISK_RULE_ASSIGN              = "system-assign"
+	RISK_RULE_UNKNOWN_PROGRAM     = "unknown-program-writable-account"
+	RISK_RULE_TRANSFER_OVER_LABEL = "transfer-over-label"
+)
+
+// Dangerous pattern found in an action transaction
+type RiskFinding struct {
+	Severity RiskSeverity `json:"severity"`
+
+	// Rule that produced the finding, one of the `RISK_RULE_*` constants
+	Rule string `json:"rule"`
+
+	// Human-readable explanation suitable to show to the user
+	Reason string `json:"reason"`
+
+	// Index of the offending instruction in the transaction
+	Instruction int `json:"instruction"`
+}
+
+// Options for `Analyze`
+type AnalyzeOptions struct {
+	// `label` of the action, used to detect transfers far above the advertised amount
+	Label string
+
+	// Transfers above this multiple of the label amount are flagged, defaults to 2
+	MaxLabelRatio float64
+
+	// Decoders used for the instructions, defaults to `DefaultInstructionDecoders`
+	Decoders *InstructionDecoderRegistry
+}
+
+var labelAmountRegexp = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*SOL\b`)
+
+// Mint of wrapped SOL, its token transfers move lamports
+const WRAPPED_SOL_MINT = "So11111111111111111111111111111111111111112"
+
+/*
+Extracts the SOL amount advertised in an action label, e.g. `Donate 0.5 SOL`.
+
+Returns false when the label carries no SOL amount.
+*/
+func LabelLamports(label string) (uint64, bool) {
+	match := labelAmountRegexp.FindStringSubmatch(label)
+	if match == nil {
+		return 0, false
+	}
+	amount, err := ParseAmount(match[1])
+	if err != nil {
+		return 0, false
+	}
+	lamports, err := amount.Lamports()
+	if err != nil {
+		return 0, false
+	}
+	return lamports, true
+}
+
+// Summarizes the findings as the highest severity found, empty when there are none
+func MaxRiskSeverity(findings []RiskFinding) RiskSeverity {
+	var severity RiskSeverity
+	for _, f := range findings {
+		if f.Severity == RISK_SEVERITY_CRITICAL {
+			return RISK_SEVERITY_CRITICAL
+		}
+		severity = f.Severity
+	}
+	return severity
+}
+
+/*
+Flag dangerous patterns in a transaction returned by an action provider.
+
+@param tx - Output of `SerializeTransaction`.
+
+@param account - Account of the user that will sign the transaction.
+
+@param options - Analysis options, may be nil.
+
+@throws {DecodeInstructionError}
+*/
+func Analyze(tx *types.Transaction, account common.PublicKey, options *AnalyzeOptions) ([]RiskFinding, error) {
+	if options == nil {
+		options = &AnalyzeOptions{}
+	}
+	decoders := options.Decoders
+	if decoders == nil {
+		decoders = DefaultInstructionDecoders
+	}
+	ratio := options.MaxLabelRatio
+	if ratio <= 0 {
+		ratio = 2
+	}
+
+	instructions, err := decoders.DecodeTransaction(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Accounts that belong to the user: the wallet and every token account it is the authority of.
+	userAccounts := map[common.PublicKey]bool{account: true}
+	for _, ix := range instructions {
+		if !isTokenProgram(ix.Program) {
+			if ix.Program == ASSOCIATED_TOKEN_PROGRAM {
+				if wallet, _ := ix.PublicKey("wallet"); wallet == account {
+					ata, _ := ix.PublicKey("account")
+					userAccounts[ata] = true
+				}
+			}
+			continue
+		}
+		for _, authorityKey := range []string{"authority", "owner"} {
+			if authority, ok := ix.PublicKey(authorityKey); ok && authority == account {
+				for _, accountKey := range []string{"source", "account"} {
+					if key, ok := ix.PublicKey(accountKey); ok {
+						userAccounts[key] = true
+					}
+				}
+			}
+		}
+	}
+
+	var findings []RiskFinding
+	var transferred uint64
+	firstTransfer, firstTokenTransfer := -1, -1
+	var tokenSource common.PublicKey
+	wrappedSOL := common.PublicKeyFromString(WRAPPED_SOL_MINT)
+	for _, ix := range instructions {
+		switch {
+		case isTokenProgram(ix.Program) && ix.Type == "setAuthority":
+			target, _ := ix.PublicKey("account")
+			authority, _ := ix.PublicKey("authority")
+			if authority == account || userAccounts[target] {
+				reason := fmt.Sprintf("changes the %s authority of your token account %s", ix.Info["authorityType"], target)
+				if newAuthority, ok := ix.PublicKey("newAuthority"); ok {
+					reason += fmt.Sprintf(" to %s", newAuthority)
+				}
+				findings = append(findings, RiskFinding{RISK_SEVERITY_CRITICAL, RISK_RULE_SET_AUTHORITY, reason, ix.Index})
+			}
+		case isTokenProgram(ix.Program) && (ix.Type == "approve" || ix.Type == "approveChecked"):
+			owner, _ := ix.PublicKey("owner")
+			if owner == account {
+				source, _ := ix.PublicKey("source")
+				delegate, _ := ix.PublicKey("delegate")
+				reason := fmt.Sprintf("allows %s to spend tokens from your account %s", delegate, source)
+				findings = append(findings, RiskFinding{RISK_SEVERITY_CRITICAL, RISK_RULE_APPROVE, reason, ix.Index})
+			}
+		case isTokenProgram(ix.Program) && ix.Type == "closeAccount":
+			owner, _ := ix.PublicKey("owner")
+			destination, _ := ix.PublicKey("destination")
+			if owner == account && destination != account {
+				target, _ := ix.PublicKey("account")
+				reason := fmt.Sprintf("closes your token account %s and sends its lamports to %s", target, destination)
+				findings = append(findings, RiskFinding{RISK_SEVERITY_CRITICAL, RISK_RULE_CLOSE_ACCOUNT, reason, ix.Index})
+			}
+		case ix.Program == SYSTEM_PROGRAM && ix.Type == "assign":
+			target, _ := ix.PublicKey("account")
+			if target == account {
+				owner, _ := ix.PublicKey("owner")
+				reason := fmt.Sprintf("assigns your wallet to program %s", owner)
+				findings = append(findings, RiskFinding{RISK_SEVERITY_CRITICAL, RISK_RULE_ASSIGN, reason, ix.Index})
+			}
+		case ix.Program == SYSTEM_PROGRAM && (ix.Type == "transfer" || ix.Type == "createAccount"):
+			// Funding a new account moves lamports out of the wallet like a transfer
+			source, _ := ix.PublicKey("source")
+			if source == account {
+				lamports, _ := ix.Uint64("lamports")
+				transferred += lamports
+				if firstTransfer < 0 {
+					firstTransfer = ix.Index
+				}
+			}
+		case isTokenProgram(ix.Program) && (ix.Type == "transfer" || ix.Type == "transferChecked"):
+			source, _ := ix.PublicKey("source")
+			authority, _ := ix.PublicKey("authority")
+			if authority != account && !userAccounts[source] {
+				break
+			}
+			if mint, ok := ix.PublicKey("mint"); ok && mint == wrappedSOL {
+				amount, _ := ix.Uint64("amount")
+				transferred += amount
+				if firstTransfer < 0 {
+					firstTransfer = ix.Index
+				}
+			} else if firstTokenTransfer < 0 {
+				firstTokenTransfer, tokenSource = ix.Index, source
+			}
+		case ix.Program == UNKNOWN_PROGRAM:
+			for _, meta := range ix.Accounts {
+				if meta.IsWritable && userAccounts[meta.PubKey] {
+					reason := fmt.Sprintf("unknown program %s can modify your account %s", ix.ProgramID, meta.PubKey)
+					findings = append(findings, RiskFinding{RISK_SEVERITY_WARNING, RISK_RULE_UNKNOWN_PROGRAM, reason, ix.Index})
+					break
+				}
+			}
+		}
+	}
+
+	if expected, ok := LabelLamports(options.Label); ok {
+		if transferred > 0 && float64(transferred) > float64(expected)*ratio {
+			reason := fmt.Sprintf("transfers %s SOL but the action advertises %s SOL", formatLamports(transferred), formatLamports(expected))
+			findings = append(findings, RiskFinding{RISK_SEVERITY_WARNING, RISK_RULE_TRANSFER_OVER_LABEL, reason, firstTransfer})
+		}
+		// Tokens can't be weighed against a SOL amount
+		if firstTokenTransfer >= 0 {
+			reason := fmt.Sprintf("transfers tokens from your account %s but the action advertises %s SOL", tokenSource, formatLamports(expected))
+			findings = append(findings, RiskFinding{RISK_SEVERITY_WARNING, RISK_RULE_TRANSFER_OVER_LABEL, reason, firstTokenTransfer})
+		}
+	}
+
+	return findings, nil
+}
+
+func isTokenProgram(program string) bool {
+	return program == SPL_TOKEN_PROGRAM || program == SPL_TOKEN_2022_PROGRAM
+}
@@ -0,0 +1,161 @@
+package actions_test
+
+import (
+	"solana-actions/actions"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+func TestAnalyze(t *testing.T) {
+	user := types.NewAccount().PublicKey
+	attacker := types.NewAccount().PublicKey
+	tokenAccount := types.NewAccount().PublicKey
+
+	t.Run("flags dangerous token and system instructions", func(t *testing.T) {
+		tx := newTestTransaction(user,
+			token.SetAuthority(token.SetAuthorityParam{
+				Account:  tokenAccount,
+				NewAuth:  &attacker,
+				AuthType: token.AuthorityTypeAccountOwner,
+				Auth:     user,
+			}),
+			token.Approve(token.ApproveParam{From: tokenAccount, To: attacker, Auth: user, Amount: 1}),
+			token.CloseAccount(token.CloseAccountParam{Account: tokenAccount, To: attacker, Auth: user}),
+			system.Assign(system.AssignParam{From: user, Owner: attacker}),
+		)
+
+		findings, err := actions.Analyze(tx, user, nil)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		expected := []string{
+			actions.RISK_RULE_SET_AUTHORITY,
+			actions.RISK_RULE_APPROVE,
+			actions.RISK_RULE_CLOSE_ACCOUNT,
+			actions.RISK_RULE_ASSIGN,
+		}
+		if len(findings) != len(expected) {
+			t.Fatalf("got %d findings want %d: %v", len(findings), len(expected), findings)
+		}
+		for i, rule := range expected {
+			if findings[i].Rule != rule || findings[i].Severity != actions.RISK_SEVERITY_CRITICAL {
+				t.Errorf("finding %d: got %s/%s want %s/%s", i, findings[i].Rule, findings[i].Severity, rule, actions.RISK_SEVERITY_CRITICAL)
+			}
+			if findings[i].Instruction != i {
+				t.Errorf("finding %d: got instruction %d", i, findings[i].Instruction)
+			}
+		}
+	})
+
+	t.Run("ignores closing an account back to the user", func(t *testing.T) {
+		tx := newTestTransaction(user,
+			token.CloseAccount(token.CloseAccountParam{Account: tokenAccount, To: user, Auth: user}),
+		)
+		findings, err := actions.Analyze(tx, user, nil)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(findings) != 0 {
+			t.Errorf("expected no findings, got %v", findings)
+		}
+	})
+
+	t.Run("flags unknown programs writing to user accounts", func(t *testing.T) {
+		tx := newTestTransaction(user, types.Instruction{
+			ProgramID: types.NewAccount().PublicKey,
+			Accounts:  []types.AccountMeta{{PubKey: user, IsSigner: true, IsWritable: true}},
+		})
+		findings, err := actions.Analyze(tx, user, nil)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(findings) != 1 || findings[0].Rule != actions.RISK_RULE_UNKNOWN_PROGRAM {
+			t.Fatalf("expected an unknown program finding, got %v", findings)
+		}
+		if actions.MaxRiskSeverity(findings) != actions.RISK_SEVERITY_WARNING {
+			t.Errorf("got %s want %s", actions.MaxRiskSeverity(findings), actions.RISK_SEVERITY_WARNING)
+		}
+	})
+
+	t.Run("flags transfers far above the label", func(t *testing.T) {
+		tx := newTestTransaction(user,
+			system.Transfer(system.TransferParam{From: user, To: attacker, Amount: 5_000_000_000}),
+		)
+
+		findings, err := actions.Analyze(tx, user, &actions.AnalyzeOptions{Label: "Donate 0.1 SOL"})
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(findings) != 1 || findings[0].Rule != actions.RISK_RULE_TRANSFER_OVER_LABEL {
+			t.Fatalf("expected a transfer finding, got %v", findings)
+		}
+
+		findings, _ = actions.Analyze(tx, user, &actions.AnalyzeOptions{Label: "Donate 5 SOL"})
+		if len(findings) != 0 {
+			t.Errorf("expected no findings, got %v", findings)
+		}
+	})
+
+	t.Run("counts account funding against the label", func(t *testing.T) {
+		tx := newTestTransaction(user,
+			system.Transfer(system.TransferParam{From: user, To: attacker, Amount: 100_000_000}),
+			system.CreateAccount(system.CreateAccountParam{From: user, New: attacker, Owner: attacker, Lamports: 2_000_000_000}),
+		)
+		findings, err := actions.Analyze(tx, user, &actions.AnalyzeOptions{Label: "Pay 0.1 SOL"})
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(findings) != 1 || findings[0].Rule != actions.RISK_RULE_TRANSFER_OVER_LABEL || findings[0].Instruction != 0 {
+			t.Fatalf("expected a transfer finding, got %v", findings)
+		}
+	})
+
+	t.Run("flags token transfers under a SOL label", func(t *testing.T) {
+		mint := types.NewAccount().PublicKey
+		tx := newTestTransaction(user,
+			token.TransferChecked(token.TransferCheckedParam{From: tokenAccount, To: attacker, Mint: mint, Auth: user, Amount: 1_000_000, Decimals: 6}),
+		)
+		findings, err := actions.Analyze(tx, user, &actions.AnalyzeOptions{Label: "Pay 1 SOL"})
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(findings) != 1 || findings[0].Rule != actions.RISK_RULE_TRANSFER_OVER_LABEL {
+			t.Fatalf("expected a token transfer finding, got %v", findings)
+		}
+
+		unchecked := newTestTransaction(user, token.Transfer(token.TransferParam{From: tokenAccount, To: attacker, Auth: user, Amount: 1}))
+		if findings, _ := actions.Analyze(unchecked, user, &actions.AnalyzeOptions{Label: "Pay 1 SOL"}); len(findings) != 1 {
+			t.Errorf("expected a token transfer finding, got %v", findings)
+		}
+		if findings, _ := actions.Analyze(unchecked, user, &actions.AnalyzeOptions{Label: "Mint NFT"}); len(findings) != 0 {
+			t.Errorf("expected no findings without a SOL label, got %v", findings)
+		}
+
+		wrapped := newTestTransaction(user,
+			token.TransferChecked(token.TransferCheckedParam{From: tokenAccount, To: attacker, Mint: common.PublicKeyFromString(actions.WRAPPED_SOL_MINT), Auth: user, Amount: 1_000_000_000, Decimals: 9}),
+		)
+		if findings, _ := actions.Analyze(wrapped, user, &actions.AnalyzeOptions{Label: "Pay 1 SOL"}); len(findings) != 0 {
+			t.Errorf("wrapped SOL within the label should pass, got %v", findings)
+		}
+		if findings, _ := actions.Analyze(wrapped, user, &actions.AnalyzeOptions{Label: "Pay 0.1 SOL"}); len(findings) != 1 {
+			t.Errorf("expected a transfer finding, got %v", findings)
+		}
+	})
+
+	t.Run("parses label amounts", func(t *testing.T) {
+		lamports, ok := actions.LabelLamports("Send 1.25 sol")
+		if !ok || lamports != 1_250_000_000 {
+			t.Errorf("got %d want %d", lamports, 1_250_000_000)
+		}
+		if _, ok := actions.LabelLamports("Send 0.0000000001 SOL"); ok {
+			t.Error("expected no amount below a lamport")
+		}
+		if _, ok := actions.LabelLamports("Mint NFT"); ok {
+			t.Error("expected no amount")
+		}
+	})
+}
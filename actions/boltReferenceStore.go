@@ -0,0 +1,195 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltOrdersBucket     = []byte("orders")
+	boltReferencesBucket = []byte("references")
+)
+
+// `ReferenceStore` persisted in a BoltDB file, orders are JSON encoded
+type BoltReferenceStore struct {
+	db *bolt.DB
+}
+
+/*
+Open or create the BoltDB file of a `ReferenceStore`.
+
+The file is locked until `Close`, a single process can open it.
+
+@throws {ReferenceStoreError}
+*/
+func OpenBoltReferenceStore(path string) (*BoltReferenceStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, &ReferenceStoreError{fmt.Sprintf("can't open %s: %s", path, err)}
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{boltOrdersBucket, boltReferencesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, &ReferenceStoreError{err.Error()}
+	}
+	return &BoltReferenceStore{db}, nil
+}
+
+func (s *BoltReferenceStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *BoltReferenceStore) Issue(ctx context.Context, orderID string, transfer TransferRequestURLFields, ttl time.Duration) (*Order, error) {
+	order, err := newOrder(orderID, transfer, ttl)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltOrdersBucket).Get([]byte(orderID)) != nil {
+			return &ReferenceStoreError{fmt.Sprintf("order %s already issued", orderID)}
+		}
+		if err := putBoltOrder(tx, order); err != nil {
+			return err
+		}
+		return tx.Bucket(boltReferencesBucket).Put(order.Reference[:], []byte(orderID))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (s *BoltReferenceStore) Lookup(ctx context.Context, orderID string) (*Order, error) {
+	var order *Order
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		order, err = getBoltOrder(tx, orderID)
+		return err
+	})
+	return order, err
+}
+
+func (s *BoltReferenceStore) LookupReference(ctx context.Context, reference Reference) (*Order, error) {
+	var order *Order
+	err := s.db.View(func(tx *bolt.Tx) error {
+		orderID := tx.Bucket(boltReferencesBucket).Get(reference[:])
+		if orderID == nil {
+			return &ReferenceStoreError{fmt.Sprintf("reference %s not found", reference)}
+		}
+		var err error
+		order, err = getBoltOrder(tx, string(orderID))
+		return err
+	})
+	return order, err
+}
+
+func (s *BoltReferenceStore) Pending(ctx context.Context) ([]Order, error) {
+	return s.withStatus(ORDER_STATUS_PENDING)
+}
+
+func (s *BoltReferenceStore) Expired(ctx context.Context) ([]Order, error) {
+	return s.withStatus(ORDER_STATUS_EXPIRED)
+}
+
+func (s *BoltReferenceStore) withStatus(status OrderStatus) ([]Order, error) {
+	var orders []Order
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachBoltOrder(tx, func(order *Order) error {
+			if order.Status == status {
+				orders = append(orders, *order)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortOrders(orders)
+	return orders, nil
+}
+
+func (s *BoltReferenceStore) MarkPaid(ctx context.Context, orderID string, signature string) (*Order, error) {
+	var order *Order
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if order, err = getBoltOrder(tx, orderID); err != nil {
+			return err
+		}
+		if err := markOrderPaid(order, signature); err != nil {
+			return err
+		}
+		return putBoltOrder(tx, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (s *BoltReferenceStore) Expire(ctx context.Context, now time.Time) ([]Order, error) {
+	var expired []Order
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		err := eachBoltOrder(tx, func(order *Order) error {
+			if order.Status == ORDER_STATUS_PENDING && !order.ExpiresAt.After(now) {
+				order.Status = ORDER_STATUS_EXPIRED
+				expired = append(expired, *order)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i := range expired {
+			if err := putBoltOrder(tx, &expired[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortOrders(expired)
+	return expired, nil
+}
+
+func getBoltOrder(tx *bolt.Tx, orderID string) (*Order, error) {
+	raw := tx.Bucket(boltOrdersBucket).Get([]byte(orderID))
+	if raw == nil {
+		return nil, &ReferenceStoreError{fmt.Sprintf("order %s not found", orderID)}
+	}
+	var order Order
+	if err := json.Unmarshal(raw, &order); err != nil {
+		return nil, &ReferenceStoreError{fmt.Sprintf("invalid order %s: %s", orderID, err)}
+	}
+	return &order, nil
+}
+
+func putBoltOrder(tx *bolt.Tx, order *Order) error {
+	raw, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(boltOrdersBucket).Put([]byte(order.ID), raw)
+}
+
+func eachBoltOrder(tx *bolt.Tx, fn func(order *Order) error) error {
+	return tx.Bucket(boltOrdersBucket).ForEach(func(key []byte, raw []byte) error {
+		var order Order
+		if err := json.Unmarshal(raw, &order); err != nil {
+			return &ReferenceStoreError{fmt.Sprintf("invalid order %s: %s", key, err)}
+		}
+		return fn(&order)
+	})
+}
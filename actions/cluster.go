@@ -0,0 +1,189 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+)
+
+// Thrown when a cluster or blockchain ID is unknown or invalid
+type ClusterError struct {
+	Message string
+}
+
+func (e *ClusterError) Error() string {
+	return fmt.Sprintf("ClusterError: %s", e.Message)
+}
+
+// Thrown when an action targets other clusters than the one of the RPC
+type ClusterMismatchError struct {
+	Message string
+}
+
+func (e *ClusterMismatchError) Error() string {
+	return fmt.Sprintf("ClusterMismatchError: %s", e.Message)
+}
+
+// A Solana cluster
+type Cluster string
+
+const (
+	CLUSTER_MAINNET Cluster = "mainnet"
+	CLUSTER_DEVNET  Cluster = "devnet"
+	CLUSTER_TESTNET Cluster = "testnet"
+)
+
+// Genesis hash of each cluster, returned by `getGenesisHash`
+var CLUSTER_GENESIS_HASHES = map[Cluster]string{
+	CLUSTER_MAINNET: "5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d",
+	CLUSTER_DEVNET:  "EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG",
+	CLUSTER_TESTNET: "4uhcVJyU9pJkvQyS88uRDiswHXSCkY3zQawwpjk2NsNY",
+}
+
+// Namespace of the Solana CAIP-2 blockchain IDs, `solana:<genesis hash>`
+const SOLANA_CAIP2_NAMESPACE = "solana"
+
+// CAIP-2 references are limited to 32 characters, the IDs may carry a truncated genesis hash
+const caip2ReferenceLength = 32
+
+// Version of the Solana Actions spec implemented by the package
+const ACTIONS_SPEC_VERSION = "2.4"
+
+// Response headers of action endpoints
+const (
+	// Version of the spec the action implements
+	ACTION_VERSION_HEADER = "X-Action-Version"
+
+	// Comma-separated CAIP-2 IDs of the blockchains the action supports
+	BLOCKCHAIN_IDS_HEADER = "X-Blockchain-Ids"
+)
+
+/*
+Parse a cluster name, a genesis hash or a CAIP-2 blockchain ID.
+
+`mainnet-beta` is accepted for mainnet.
+
+@throws {ClusterError}
+*/
+func ParseCluster(value string) (Cluster, error) {
+	switch Cluster(value) {
+	case CLUSTER_MAINNET, "mainnet-beta":
+		return CLUSTER_MAINNET, nil
+	case CLUSTER_DEVNET, CLUSTER_TESTNET:
+		return Cluster(value), nil
+	}
+	hash := value
+	if namespace, reference, ok := strings.Cut(value, ":"); ok {
+		if namespace != SOLANA_CAIP2_NAMESPACE {
+			return "", &ClusterError{fmt.Sprintf("unsupported blockchain %q", value)}
+		}
+		hash = reference
+	}
+	for cluster, genesisHash := range CLUSTER_GENESIS_HASHES {
+		if genesisHashMatches(hash, genesisHash) {
+			return cluster, nil
+		}
+	}
+	return "", &ClusterError{fmt.Sprintf("unknown cluster %q", value)}
+}
+
+// Genesis hash of the cluster, empty for unknown clusters
+func (c Cluster) GenesisHash() string {
+	return CLUSTER_GENESIS_HASHES[c]
+}
+
+// CAIP-2 ID of the cluster, `solana:<genesis hash>`
+func (c Cluster) BlockchainID() string {
+	return SOLANA_CAIP2_NAMESPACE + ":" + c.GenesisHash()
+}
+
+// Public RPC endpoint of the cluster
+func (c Cluster) RPCEndpoint() string {
+	switch c {
+	case CLUSTER_DEVNET:
+		return rpc.DevnetRPCEndpoint
+	case CLUSTER_TESTNET:
+		return rpc.TestnetRPCEndpoint
+	}
+	return rpc.MainnetRPCEndpoint
+}
+
+// A hash matches when it is the genesis hash or its CAIP-2 truncation
+func genesisHashMatches(hash string, genesisHash string) bool {
+	return hash == genesisHash || (len(hash) == caip2ReferenceLength && strings.HasPrefix(genesisHash, hash))
+}
+
+/*
+Find the cluster of an RPC from its genesis hash.
+
+@throws {ClusterError} For clusters other than mainnet, devnet and testnet.
+*/
+func FetchCluster(ctx context.Context, conn RPCClient) (Cluster, error) {
+	hash, err := conn.GetGenesisHash(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ParseCluster(hash)
+}
+
+// Parse the comma-separated IDs of the `X-Blockchain-Ids` header
+func ParseBlockchainIds(header string) []string {
+	var ids []string
+	for _, id := range strings.Split(header, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Whether a CAIP-2 ID is the one of the blockchain with the given genesis hash
+func blockchainIDMatches(id string, genesisHash string) bool {
+	namespace, reference, ok := strings.Cut(id, ":")
+	return ok && namespace == SOLANA_CAIP2_NAMESPACE && reference != "" && genesisHashMatches(reference, genesisHash)
+}
+
+/*
+Check the `X-Blockchain-Ids` of an action response against the cluster of the RPC.
+
+Responses without the header are accepted, the spec made it optional.
+
+@param conn - A connection to the cluster the transaction is signed for.
+
+@throws {ClusterMismatchError}
+*/
+func CheckBlockchainIds(ctx context.Context, conn RPCClient, header http.Header) error {
+	ids := ParseBlockchainIds(header.Get(BLOCKCHAIN_IDS_HEADER))
+	if len(ids) == 0 {
+		return nil
+	}
+	genesisHash, err := conn.GetGenesisHash(ctx)
+	if err != nil {
+		return &ClusterMismatchError{fmt.Sprintf("can't fetch the genesis hash of the RPC: %s", err)}
+	}
+	for _, id := range ids {
+		if blockchainIDMatches(id, genesisHash) {
+			return nil
+		}
+	}
+	connected := genesisHash
+	if cluster, err := ParseCluster(genesisHash); err == nil {
+		connected = string(cluster)
+	}
+	return &ClusterMismatchError{fmt.Sprintf("action supports %s, the RPC is on %s", strings.Join(describeBlockchainIds(ids), ", "), connected)}
+}
+
+// Cluster names of the known IDs, the IDs of the others
+func describeBlockchainIds(ids []string) []string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = id
+		if cluster, err := ParseCluster(id); err == nil {
+			names[i] = string(cluster)
+		}
+	}
+	return names
+}
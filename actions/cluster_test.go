@@ -0,0 +1,138 @@
+package actions_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"solana-actions/actions"
+	"strings"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+func genesisHashHandler(cluster actions.Cluster) rpcHandler {
+	return func(params json.RawMessage) (any, *rpc.JsonRpcError) {
+		return cluster.GenesisHash(), nil
+	}
+}
+
+func TestCluster(t *testing.T) {
+	t.Run("parses names, genesis hashes and CAIP-2 IDs", func(t *testing.T) {
+		for value, expected := range map[string]actions.Cluster{
+			"mainnet":      actions.CLUSTER_MAINNET,
+			"mainnet-beta": actions.CLUSTER_MAINNET,
+			"devnet":       actions.CLUSTER_DEVNET,
+			"testnet":      actions.CLUSTER_TESTNET,
+			"EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG":        actions.CLUSTER_DEVNET,
+			"solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d": actions.CLUSTER_MAINNET,
+			"solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp":             actions.CLUSTER_MAINNET,
+			"solana:4uhcVJyU9pJkvQyS88uRDiswHXSCkY3z":             actions.CLUSTER_TESTNET,
+		} {
+			cluster, err := actions.ParseCluster(value)
+			if err != nil {
+				t.Errorf("%s: err should be nil: %s", value, err.Error())
+			} else if cluster != expected {
+				t.Errorf("%s: got %s want %s", value, cluster, expected)
+			}
+		}
+		for _, value := range []string{"", "localnet", "solana:5eykt4", "eip155:1", "https://api.devnet.solana.com"} {
+			if _, err := actions.ParseCluster(value); err == nil {
+				t.Errorf("%s: expected an error", value)
+			}
+		}
+	})
+
+	t.Run("formats CAIP-2 IDs", func(t *testing.T) {
+		expected := "solana:EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG"
+		if id := actions.CLUSTER_DEVNET.BlockchainID(); id != expected {
+			t.Errorf("got %s want %s", id, expected)
+		}
+	})
+
+	t.Run("fetches the cluster of an RPC", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{"getGenesisHash": genesisHashHandler(actions.CLUSTER_TESTNET)})
+		cluster, err := actions.FetchCluster(context.Background(), fake.client())
+		if err != nil || cluster != actions.CLUSTER_TESTNET {
+			t.Errorf("got %s %v", cluster, err)
+		}
+	})
+
+	t.Run("checks the blockchain IDs against the RPC", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{"getGenesisHash": genesisHashHandler(actions.CLUSTER_MAINNET)})
+		header := http.Header{}
+		if err := actions.CheckBlockchainIds(context.Background(), fake.client(), header); err != nil || fake.count("getGenesisHash") != 0 {
+			t.Errorf("responses without IDs should be accepted without a call: %v", err)
+		}
+		header.Set(actions.BLOCKCHAIN_IDS_HEADER, "solana:EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG, solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp")
+		if err := actions.CheckBlockchainIds(context.Background(), fake.client(), header); err != nil {
+			t.Errorf("err should be nil: %s", err.Error())
+		}
+		header.Set(actions.BLOCKCHAIN_IDS_HEADER, actions.CLUSTER_DEVNET.BlockchainID())
+		var mismatch *actions.ClusterMismatchError
+		err := actions.CheckBlockchainIds(context.Background(), fake.client(), header)
+		if !errors.As(err, &mismatch) || mismatch.Message != "action supports devnet, the RPC is on mainnet" {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
+
+func TestActionHandlerClusters(t *testing.T) {
+	handler, err := actions.NewActionHandlerWithOptions(&transferProvider{}, &actions.ActionHandlerOptions{Clusters: []actions.Cluster{actions.CLUSTER_DEVNET}})
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	link, _ := url.Parse(server.URL + "/api/donate?amount=1")
+
+	t.Run("declares the version and clusters", func(t *testing.T) {
+		res, err := http.Get(link.String())
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if res.Header.Get(actions.ACTION_VERSION_HEADER) != actions.ACTIONS_SPEC_VERSION || res.Header.Get(actions.BLOCKCHAIN_IDS_HEADER) != actions.CLUSTER_DEVNET.BlockchainID() {
+			t.Errorf("unexpected headers %v", res.Header)
+		}
+		if exposed := res.Header.Get("Access-Control-Expose-Headers"); !strings.Contains(exposed, actions.BLOCKCHAIN_IDS_HEADER) {
+			t.Errorf("got %q want the blockchain IDs exposed", exposed)
+		}
+	})
+
+	t.Run("clients on the cluster accept the action", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{"getGenesisHash": genesisHashHandler(actions.CLUSTER_DEVNET), "getLatestBlockhash": latestBlockhashHandler(500)})
+		if _, err := actions.FetchActionWithOptions(context.Background(), link, &actions.FetchActionOptions{Conn: fake.client()}); err != nil {
+			t.Errorf("err should be nil: %s", err.Error())
+		}
+		account := types.NewAccount().PublicKey.String()
+		if _, err := actions.FetchTransactionWithOptions(context.Background(), fake.client(), link, actions.ActionPostRequest{Account: account}, nil); err != nil {
+			t.Errorf("err should be nil: %s", err.Error())
+		}
+	})
+
+	t.Run("clients on another cluster refuse the action", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{"getGenesisHash": genesisHashHandler(actions.CLUSTER_MAINNET), "getLatestBlockhash": latestBlockhashHandler(500)})
+		var mismatch *actions.ClusterMismatchError
+		if _, err := actions.FetchActionWithOptions(context.Background(), link, &actions.FetchActionOptions{Conn: fake.client()}); !errors.As(err, &mismatch) {
+			t.Errorf("unexpected error %v", err)
+		}
+		account := types.NewAccount().PublicKey.String()
+		if _, err := actions.FetchTransactionWithOptions(context.Background(), fake.client(), link, actions.ActionPostRequest{Account: account}, nil); !errors.As(err, &mismatch) {
+			t.Errorf("unexpected error %v", err)
+		}
+		options := &actions.FetchTransactionOptions{IgnoreBlockchainIds: true}
+		if _, err := actions.FetchTransactionWithOptions(context.Background(), fake.client(), link, actions.ActionPostRequest{Account: account}, options); err != nil {
+			t.Errorf("err should be nil: %s", err.Error())
+		}
+	})
+
+	t.Run("rejects unknown clusters", func(t *testing.T) {
+		if _, err := actions.NewActionHandlerWithOptions(&transferProvider{}, &actions.ActionHandlerOptions{Clusters: []actions.Cluster{"localnet"}}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
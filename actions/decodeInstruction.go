@@ -0,0 +1,659 @@
+package actions
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Program names reported in a `DecodedInstruction`
+const (
+	SYSTEM_PROGRAM           = "system"
+	SPL_TOKEN_PROGRAM        = "spl-token"
+	SPL_TOKEN_2022_PROGRAM   = "spl-token-2022"
+	ASSOCIATED_TOKEN_PROGRAM = "spl-associated-token-account"
+	MEMO_PROGRAM             = "spl-memo"
+	COMPUTE_BUDGET_PROGRAM   = "compute-budget"
+	UNKNOWN_PROGRAM          = "unknown"
+)
+
+const LAMPORTS_PER_SOL = 1_000_000_000
+
+// Thrown when an instruction can't be decoded by the decoder registered for its program
+type DecodeInstructionError struct {
+	Message string
+}
+
+func (e *DecodeInstructionError) Error() string {
+	return fmt.Sprintf("DecodeInstructionError: %s", e.Message)
+}
+
+/*
+Human-readable summary of a single transaction instruction.
+
+`Info` holds the decoded arguments keyed by name. Account arguments are
+`common.PublicKey` values, amounts are `uint64`.
+*/
+type DecodedInstruction struct {
+	// Index of the instruction in the transaction
+	Index int `json:"index"`
+
+	ProgramID common.PublicKey `json:"programId"`
+
+	// Name of the program, `UNKNOWN_PROGRAM` when no decoder is registered
+	Program string `json:"program"`
+
+	// Instruction type, e.g. `transfer` or `setAuthority`
+	Type string `json:"type"`
+
+	// One line description of what the instruction does
+	Summary string `json:"summary"`
+
+	Info map[string]any `json:"info,omitempty"`
+
+	// Raw account list of the instruction
+	Accounts []types.AccountMeta `json:"accounts"`
+}
+
+// Returns the account stored under `name` in `Info`
+func (d *DecodedInstruction) PublicKey(name string) (common.PublicKey, bool) {
+	key, ok := d.Info[name].(common.PublicKey)
+	return key, ok
+}
+
+// Returns the amount stored under `name` in `Info`
+func (d *DecodedInstruction) Uint64(name string) (uint64, bool) {
+	value, ok := d.Info[name].(uint64)
+	return value, ok
+}
+
+// Decodes the instructions of a single program
+type InstructionDecoder func(ix types.Instruction) (*DecodedInstruction, error)
+
+/*
+Registry mapping program ids to their instruction decoders.
+
+It is safe for concurrent use.
+*/
+type InstructionDecoderRegistry struct {
+	mu       sync.RWMutex
+	decoders map[common.PublicKey]InstructionDecoder
+}
+
+// Creates a registry with decoders for the built-in programs
+func NewInstructionDecoderRegistry() *InstructionDecoderRegistry {
+	r := &InstructionDecoderRegistry{decoders: map[common.PublicKey]InstructionDecoder{}}
+	r.Register(common.SystemProgramID, decodeSystemInstruction)
+	r.Register(common.TokenProgramID, tokenInstructionDecoder(SPL_TOKEN_PROGRAM))
+	r.Register(common.Token2022ProgramID, tokenInstructionDecoder(SPL_TOKEN_2022_PROGRAM))
+	r.Register(common.SPLAssociatedTokenAccountProgramID, decodeAssociatedTokenInstruction)
+	r.Register(common.PublicKeyFromString(MEMO_PROGRAM_ID), decodeMemoInstruction)
+	r.Register(common.ComputeBudgetProgramID, decodeComputeBudgetInstruction)
+	return r
+}
+
+// Registers `decoder` for `programID`, replacing any existing decoder
+func (r *InstructionDecoderRegistry) Register(programID common.PublicKey, decoder InstructionDecoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decoders[programID] = decoder
+}
+
+/*
+Decode a single instruction.
+
+Instructions of programs without a registered decoder are reported as
+`UNKNOWN_PROGRAM` with their raw account list.
+
+@throws {DecodeInstructionError}
+*/
+func (r *InstructionDecoderRegistry) DecodeInstruction(ix types.Instruction) (*DecodedInstruction, error) {
+	r.mu.RLock()
+	decoder, ok := r.decoders[ix.ProgramID]
+	r.mu.RUnlock()
+
+	if !ok {
+		return &DecodedInstruction{
+			ProgramID: ix.ProgramID,
+			Program:   UNKNOWN_PROGRAM,
+			Type:      UNKNOWN_PROGRAM,
+			Summary:   fmt.Sprintf("Unknown instruction of program %s with %d accounts", ix.ProgramID, len(ix.Accounts)),
+			Accounts:  ix.Accounts,
+		}, nil
+	}
+
+	decoded, err := decoder(ix)
+	if err != nil {
+		return nil, &DecodeInstructionError{fmt.Sprintf("program %s: %s", ix.ProgramID, err.Error())}
+	}
+	decoded.ProgramID = ix.ProgramID
+	decoded.Accounts = ix.Accounts
+	return decoded, nil
+}
+
+/*
+Decode every instruction of a transaction.
+
+@throws {DecodeInstructionError}
+*/
+func (r *InstructionDecoderRegistry) DecodeTransaction(tx *types.Transaction) ([]DecodedInstruction, error) {
+	instructions, err := decompileInstructions(&tx.Message)
+	if err != nil {
+		return nil, err
+	}
+	decoded := make([]DecodedInstruction, 0, len(instructions))
+	for i, ix := range instructions {
+		d, err := r.DecodeInstruction(ix)
+		if err != nil {
+			return nil, err
+		}
+		d.Index = i
+		decoded = append(decoded, *d)
+	}
+	return decoded, nil
+}
+
+// Registry used by the package level decode functions
+var DefaultInstructionDecoders = NewInstructionDecoderRegistry()
+
+// Registers a decoder for a third party program on `DefaultInstructionDecoders`
+func RegisterInstructionDecoder(programID common.PublicKey, decoder InstructionDecoder) {
+	DefaultInstructionDecoders.Register(programID, decoder)
+}
+
+// Decode a single instruction with `DefaultInstructionDecoders`
+func DecodeInstruction(ix types.Instruction) (*DecodedInstruction, error) {
+	return DefaultInstructionDecoders.DecodeInstruction(ix)
+}
+
+// Decode every instruction of a transaction with `DefaultInstructionDecoders`
+func DecodeTransaction(tx *types.Transaction) ([]DecodedInstruction, error) {
+	return DefaultInstructionDecoders.DecodeTransaction(tx)
+}
+
+// Resolves the account metas of each instruction. Address lookup tables can't be resolved offline.
+func decompileInstructions(msg *types.Message) ([]types.Instruction, error) {
+	if msg.Version == types.MessageVersionV0 {
+		for _, cix := range msg.Instructions {
+			if cix.ProgramIDIndex >= len(msg.Accounts) {
+				return nil, &DecodeInstructionError{"address lookup tables are not supported"}
+			}
+			for _, idx := range cix.Accounts {
+				if idx >= len(msg.Accounts) {
+					return nil, &DecodeInstructionError{"address lookup tables are not supported"}
+				}
+			}
+		}
+		// v0 messages without lookups share the legacy account layout
+		legacy := *msg
+		legacy.Version = types.MessageVersionLegacy
+		return legacy.DecompileInstructions(), nil
+	}
+	for _, cix := range msg.Instructions {
+		if cix.ProgramIDIndex >= len(msg.Accounts) {
+			return nil, &DecodeInstructionError{"program id index out of range"}
+		}
+		for _, idx := range cix.Accounts {
+			if idx >= len(msg.Accounts) {
+				return nil, &DecodeInstructionError{"account index out of range"}
+			}
+		}
+	}
+	return msg.DecompileInstructions(), nil
+}
+
+// Formats lamports as a decimal SOL string
+func formatLamports(lamports uint64) string {
+	return NewAmountFromLamports(lamports).String()
+}
+
+// Formats base units as a decimal string with `decimals` fraction digits, trimming trailing zeros
+func formatUnits(units uint64, decimals uint8) string {
+	return NewAmountFromBaseUnits(units, decimals).String()
+}
+
+func accountAt(ix types.Instruction, i int) (common.PublicKey, error) {
+	if i >= len(ix.Accounts) {
+		return common.PublicKey{}, fmt.Errorf("missing account %d", i)
+	}
+	return ix.Accounts[i].PubKey, nil
+}
+
+func accountsAt(ix types.Instruction, n int) ([]common.PublicKey, error) {
+	keys := make([]common.PublicKey, n)
+	for i := range keys {
+		key, err := accountAt(ix, i)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+func readUint64(data []byte, offset int) (uint64, error) {
+	if len(data) < offset+8 {
+		return 0, fmt.Errorf("instruction data too short")
+	}
+	return binary.LittleEndian.Uint64(data[offset:]), nil
+}
+
+func readUint32(data []byte, offset int) (uint32, error) {
+	if len(data) < offset+4 {
+		return 0, fmt.Errorf("instruction data too short")
+	}
+	return binary.LittleEndian.Uint32(data[offset:]), nil
+}
+
+func readPublicKey(data []byte, offset int) (common.PublicKey, error) {
+	if len(data) < offset+32 {
+		return common.PublicKey{}, fmt.Errorf("instruction data too short")
+	}
+	return common.PublicKeyFromBytes(data[offset : offset+32]), nil
+}
+
+func decodeSystemInstruction(ix types.Instruction) (*DecodedInstruction, error) {
+	tag, err := readUint32(ix.Data, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case 0: // CreateAccount
+		keys, err := accountsAt(ix, 2)
+		if err != nil {
+			return nil, err
+		}
+		lamports, err := readUint64(ix.Data, 4)
+		if err != nil {
+			return nil, err
+		}
+		space, err := readUint64(ix.Data, 12)
+		if err != nil {
+			return nil, err
+		}
+		owner, err := readPublicKey(ix.Data, 20)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedInstruction{
+			Program: SYSTEM_PROGRAM,
+			Type:    "createAccount",
+			Summary: fmt.Sprintf("Create account %s owned by %s funded with %s SOL from %s", keys[1], owner, formatLamports(lamports), keys[0]),
+			Info: map[string]any{
+				"source":     keys[0],
+				"newAccount": keys[1],
+				"lamports":   lamports,
+				"space":      space,
+				"owner":      owner,
+			},
+		}, nil
+	case 1: // Assign
+		account, err := accountAt(ix, 0)
+		if err != nil {
+			return nil, err
+		}
+		owner, err := readPublicKey(ix.Data, 4)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedInstruction{
+			Program: SYSTEM_PROGRAM,
+			Type:    "assign",
+			Summary: fmt.Sprintf("Assign account %s to program %s", account, owner),
+			Info: map[string]any{
+				"account": account,
+				"owner":   owner,
+			},
+		}, nil
+	case 2: // Transfer
+		keys, err := accountsAt(ix, 2)
+		if err != nil {
+			return nil, err
+		}
+		lamports, err := readUint64(ix.Data, 4)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedInstruction{
+			Program: SYSTEM_PROGRAM,
+			Type:    "transfer",
+			Summary: fmt.Sprintf("Transfer %s SOL from %s to %s", formatLamports(lamports), keys[0], keys[1]),
+			Info: map[string]any{
+				"source":      keys[0],
+				"destination": keys[1],
+				"lamports":    lamports,
+			},
+		}, nil
+	case 4: // AdvanceNonceAccount
+		keys, err := accountsAt(ix, 3)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedInstruction{
+			Program: SYSTEM_PROGRAM,
+			Type:    "advanceNonce",
+			Summary: fmt.Sprintf("Advance nonce account %s", keys[0]),
+			Info: map[string]any{
+				"nonceAccount":   keys[0],
+				"nonceAuthority": keys[2],
+			},
+		}, nil
+	case 5: // WithdrawNonceAccount
+		keys, err := accountsAt(ix, 5)
+		if err != nil {
+			return nil, err
+		}
+		lamports, err := readUint64(ix.Data, 4)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedInstruction{
+			Program: SYSTEM_PROGRAM,
+			Type:    "withdrawNonce",
+			Summary: fmt.Sprintf("Withdraw %s SOL from nonce account %s to %s", formatLamports(lamports), keys[0], keys[1]),
+			Info: map[string]any{
+				"nonceAccount":   keys[0],
+				"destination":    keys[1],
+				"nonceAuthority": keys[4],
+				"lamports":       lamports,
+			},
+		}, nil
+	case 6: // InitializeNonceAccount
+		account, err := accountAt(ix, 0)
+		if err != nil {
+			return nil, err
+		}
+		authority, err := readPublicKey(ix.Data, 4)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedInstruction{
+			Program: SYSTEM_PROGRAM,
+			Type:    "initializeNonce",
+			Summary: fmt.Sprintf("Initialize nonce account %s with authority %s", account, authority),
+			Info: map[string]any{
+				"nonceAccount":   account,
+				"nonceAuthority": authority,
+			},
+		}, nil
+	case 7: // AuthorizeNonceAccount
+		keys, err := accountsAt(ix, 2)
+		if err != nil {
+			return nil, err
+		}
+		newAuthority, err := readPublicKey(ix.Data, 4)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedInstruction{
+			Program: SYSTEM_PROGRAM,
+			Type:    "authorizeNonce",
+			Summary: fmt.Sprintf("Set the authority of nonce account %s to %s", keys[0], newAuthority),
+			Info: map[string]any{
+				"nonceAccount":   keys[0],
+				"nonceAuthority": keys[1],
+				"newAuthority":   newAuthority,
+			},
+		}, nil
+	}
+	return &DecodedInstruction{
+		Program: SYSTEM_PROGRAM,
+		Type:    "unsupported",
+		Summary: fmt.Sprintf("System instruction %d", tag),
+	}, nil
+}
+
+var tokenAuthorityTypes = []string{"mintTokens", "freezeAccount", "accountOwner", "closeAccount"}
+
+func tokenInstructionDecoder(program string) InstructionDecoder {
+	return func(ix types.Instruction) (*DecodedInstruction, error) {
+		if len(ix.Data) == 0 {
+			return nil, fmt.Errorf("instruction data too short")
+		}
+		switch ix.Data[0] {
+		case 3: // Transfer
+			keys, err := accountsAt(ix, 3)
+			if err != nil {
+				return nil, err
+			}
+			amount, err := readUint64(ix.Data, 1)
+			if err != nil {
+				return nil, err
+			}
+			return &DecodedInstruction{
+				Program: program,
+				Type:    "transfer",
+				Summary: fmt.Sprintf("Transfer %d token base units from %s to %s", amount, keys[0], keys[1]),
+				Info: map[string]any{
+					"source":      keys[0],
+					"destination": keys[1],
+					"authority":   keys[2],
+					"amount":      amount,
+				},
+			}, nil
+		case 4: // Approve
+			keys, err := accountsAt(ix, 3)
+			if err != nil {
+				return nil, err
+			}
+			amount, err := readUint64(ix.Data, 1)
+			if err != nil {
+				return nil, err
+			}
+			return &DecodedInstruction{
+				Program: program,
+				Type:    "approve",
+				Summary: fmt.Sprintf("Approve %s to spend %d token base units from %s", keys[1], amount, keys[0]),
+				Info: map[string]any{
+					"source":   keys[0],
+					"delegate": keys[1],
+					"owner":    keys[2],
+					"amount":   amount,
+				},
+			}, nil
+		case 6: // SetAuthority
+			keys, err := accountsAt(ix, 2)
+			if err != nil {
+				return nil, err
+			}
+			if len(ix.Data) < 3 {
+				return nil, fmt.Errorf("instruction data too short")
+			}
+			authorityType := "unknown"
+			if int(ix.Data[1]) < len(tokenAuthorityTypes) {
+				authorityType = tokenAuthorityTypes[ix.Data[1]]
+			}
+			info := map[string]any{
+				"account":       keys[0],
+				"authority":     keys[1],
+				"authorityType": authorityType,
+			}
+			summary := fmt.Sprintf("Remove %s authority of %s", authorityType, keys[0])
+			if ix.Data[2] == 1 {
+				newAuthority, err := readPublicKey(ix.Data, 3)
+				if err != nil {
+					return nil, err
+				}
+				info["newAuthority"] = newAuthority
+				summary = fmt.Sprintf("Set %s authority of %s to %s", authorityType, keys[0], newAuthority)
+			}
+			return &DecodedInstruction{
+				Program: program,
+				Type:    "setAuthority",
+				Summary: summary,
+				Info:    info,
+			}, nil
+		case 9: // CloseAccount
+			keys, err := accountsAt(ix, 3)
+			if err != nil {
+				return nil, err
+			}
+			return &DecodedInstruction{
+				Program: program,
+				Type:    "closeAccount",
+				Summary: fmt.Sprintf("Close token account %s and send its lamports to %s", keys[0], keys[1]),
+				Info: map[string]any{
+					"account":     keys[0],
+					"destination": keys[1],
+					"owner":       keys[2],
+				},
+			}, nil
+		case 12: // TransferChecked
+			keys, err := accountsAt(ix, 4)
+			if err != nil {
+				return nil, err
+			}
+			amount, err := readUint64(ix.Data, 1)
+			if err != nil {
+				return nil, err
+			}
+			if len(ix.Data) < 10 {
+				return nil, fmt.Errorf("instruction data too short")
+			}
+			decimals := ix.Data[9]
+			return &DecodedInstruction{
+				Program: program,
+				Type:    "transferChecked",
+				Summary: fmt.Sprintf("Transfer %s of mint %s from %s to %s", formatUnits(amount, decimals), keys[1], keys[0], keys[2]),
+				Info: map[string]any{
+					"source":      keys[0],
+					"mint":        keys[1],
+					"destination": keys[2],
+					"authority":   keys[3],
+					"amount":      amount,
+					"decimals":    decimals,
+				},
+			}, nil
+		case 13: // ApproveChecked
+			keys, err := accountsAt(ix, 4)
+			if err != nil {
+				return nil, err
+			}
+			amount, err := readUint64(ix.Data, 1)
+			if err != nil {
+				return nil, err
+			}
+			if len(ix.Data) < 10 {
+				return nil, fmt.Errorf("instruction data too short")
+			}
+			decimals := ix.Data[9]
+			return &DecodedInstruction{
+				Program: program,
+				Type:    "approveChecked",
+				Summary: fmt.Sprintf("Approve %s to spend %s of mint %s from %s", keys[2], formatUnits(amount, decimals), keys[1], keys[0]),
+				Info: map[string]any{
+					"source":   keys[0],
+					"mint":     keys[1],
+					"delegate": keys[2],
+					"owner":    keys[3],
+					"amount":   amount,
+					"decimals": decimals,
+				},
+			}, nil
+		}
+		return &DecodedInstruction{
+			Program: program,
+			Type:    "unsupported",
+			Summary: fmt.Sprintf("Token instruction %d", ix.Data[0]),
+		}, nil
+	}
+}
+
+func decodeAssociatedTokenInstruction(ix types.Instruction) (*DecodedInstruction, error) {
+	instructionType := "create"
+	if len(ix.Data) > 0 {
+		switch ix.Data[0] {
+		case 0:
+		case 1:
+			instructionType = "createIdempotent"
+		case 2:
+			return &DecodedInstruction{
+				Program: ASSOCIATED_TOKEN_PROGRAM,
+				Type:    "recoverNested",
+				Summary: "Recover nested associated token account",
+			}, nil
+		default:
+			return nil, fmt.Errorf("invalid instruction %d", ix.Data[0])
+		}
+	}
+	keys, err := accountsAt(ix, 4)
+	if err != nil {
+		return nil, err
+	}
+	return &DecodedInstruction{
+		Program: ASSOCIATED_TOKEN_PROGRAM,
+		Type:    instructionType,
+		Summary: fmt.Sprintf("Create associated token account %s for wallet %s and mint %s", keys[1], keys[2], keys[3]),
+		Info: map[string]any{
+			"source":  keys[0],
+			"account": keys[1],
+			"wallet":  keys[2],
+			"mint":    keys[3],
+		},
+	}, nil
+}
+
+func decodeMemoInstruction(ix types.Instruction) (*DecodedInstruction, error) {
+	if !utf8.Valid(ix.Data) {
+		return nil, fmt.Errorf("memo is not valid utf-8")
+	}
+	memo := string(ix.Data)
+	return &DecodedInstruction{
+		Program: MEMO_PROGRAM,
+		Type:    "memo",
+		Summary: fmt.Sprintf("Memo %q", memo),
+		Info: map[string]any{
+			"memo": memo,
+		},
+	}, nil
+}
+
+func decodeComputeBudgetInstruction(ix types.Instruction) (*DecodedInstruction, error) {
+	if len(ix.Data) == 0 {
+		return nil, fmt.Errorf("instruction data too short")
+	}
+	switch ix.Data[0] {
+	case 1: // RequestHeapFrame
+		bytes, err := readUint32(ix.Data, 1)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedInstruction{
+			Program: COMPUTE_BUDGET_PROGRAM,
+			Type:    "requestHeapFrame",
+			Summary: fmt.Sprintf("Request a heap frame of %d bytes", bytes),
+			Info:    map[string]any{"bytes": uint64(bytes)},
+		}, nil
+	case 2: // SetComputeUnitLimit
+		units, err := readUint32(ix.Data, 1)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedInstruction{
+			Program: COMPUTE_BUDGET_PROGRAM,
+			Type:    "setComputeUnitLimit",
+			Summary: fmt.Sprintf("Set compute unit limit to %d", units),
+			Info:    map[string]any{"units": uint64(units)},
+		}, nil
+	case 3: // SetComputeUnitPrice
+		microLamports, err := readUint64(ix.Data, 1)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedInstruction{
+			Program: COMPUTE_BUDGET_PROGRAM,
+			Type:    "setComputeUnitPrice",
+			Summary: fmt.Sprintf("Set compute unit price to %d micro-lamports", microLamports),
+			Info:    map[string]any{"microLamports": microLamports},
+		}, nil
+	}
+	return &DecodedInstruction{
+		Program: COMPUTE_BUDGET_PROGRAM,
+		Type:    "unsupported",
+		Summary: fmt.Sprintf("Compute budget instruction %d", ix.Data[0]),
+	}, nil
+}
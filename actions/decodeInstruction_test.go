@@ -0,0 +1,141 @@
+package actions_test
+
+import (
+	"solana-actions/actions"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/compute_budget"
+	"github.com/blocto/solana-go-sdk/program/memo"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+func newTestTransaction(feePayer common.PublicKey, instructions ...types.Instruction) *types.Transaction {
+	msg := types.NewMessage(types.NewMessageParam{
+		FeePayer:        feePayer,
+		Instructions:    instructions,
+		RecentBlockhash: "EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG",
+	})
+	return &types.Transaction{
+		Signatures: make([]types.Signature, msg.Header.NumRequireSignatures),
+		Message:    msg,
+	}
+}
+
+func TestDecodeTransaction(t *testing.T) {
+	user := types.NewAccount().PublicKey
+	other := types.NewAccount().PublicKey
+
+	t.Run("decodes built-in programs", func(t *testing.T) {
+		tokenAccount := types.NewAccount().PublicKey
+		token2022 := token.CloseAccount(token.CloseAccountParam{
+			Account: tokenAccount,
+			Auth:    user,
+			To:      other,
+		})
+		token2022.ProgramID = common.Token2022ProgramID
+
+		tx := newTestTransaction(user,
+			compute_budget.SetComputeUnitLimit(compute_budget.SetComputeUnitLimitParam{Units: 200_000}),
+			system.Transfer(system.TransferParam{From: user, To: other, Amount: 1_500_000_000}),
+			token.SetAuthority(token.SetAuthorityParam{
+				Account:  tokenAccount,
+				NewAuth:  &other,
+				AuthType: token.AuthorityTypeAccountOwner,
+				Auth:     user,
+			}),
+			token2022,
+			memo.BuildMemo(memo.BuildMemoParam{SignerPubkeys: []common.PublicKey{user}, Memo: []byte("order-1")}),
+		)
+
+		decoded, err := actions.DecodeTransaction(tx)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		expected := []struct{ program, kind string }{
+			{actions.COMPUTE_BUDGET_PROGRAM, "setComputeUnitLimit"},
+			{actions.SYSTEM_PROGRAM, "transfer"},
+			{actions.SPL_TOKEN_PROGRAM, "setAuthority"},
+			{actions.SPL_TOKEN_2022_PROGRAM, "closeAccount"},
+			{actions.MEMO_PROGRAM, "memo"},
+		}
+		if len(decoded) != len(expected) {
+			t.Fatalf("got %d instructions want %d", len(decoded), len(expected))
+		}
+		for i, e := range expected {
+			if decoded[i].Program != e.program || decoded[i].Type != e.kind {
+				t.Errorf("instruction %d: got %s/%s want %s/%s", i, decoded[i].Program, decoded[i].Type, e.program, e.kind)
+			}
+		}
+
+		lamports, _ := decoded[1].Uint64("lamports")
+		if lamports != 1_500_000_000 {
+			t.Errorf("got %d want %d", lamports, 1_500_000_000)
+		}
+		expectedSummary := "Transfer 1.5 SOL from " + user.String() + " to " + other.String()
+		if decoded[1].Summary != expectedSummary {
+			t.Errorf("got %s want %s", decoded[1].Summary, expectedSummary)
+		}
+		newAuthority, _ := decoded[2].PublicKey("newAuthority")
+		if newAuthority != other {
+			t.Errorf("got %s want %s", newAuthority, other)
+		}
+		if decoded[4].Info["memo"] != "order-1" {
+			t.Errorf("got %v want %s", decoded[4].Info["memo"], "order-1")
+		}
+	})
+
+	t.Run("reports unknown programs with their accounts", func(t *testing.T) {
+		program := types.NewAccount().PublicKey
+		tx := newTestTransaction(user, types.Instruction{
+			ProgramID: program,
+			Accounts: []types.AccountMeta{
+				{PubKey: user, IsSigner: true, IsWritable: true},
+				{PubKey: other, IsSigner: false, IsWritable: false},
+			},
+			Data: []byte{1, 2, 3},
+		})
+
+		decoded, err := actions.DecodeTransaction(tx)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if decoded[0].Program != actions.UNKNOWN_PROGRAM {
+			t.Errorf("got %s want %s", decoded[0].Program, actions.UNKNOWN_PROGRAM)
+		}
+		if len(decoded[0].Accounts) != 2 || decoded[0].Accounts[1].PubKey != other {
+			t.Errorf("expected raw accounts to be reported, got %v", decoded[0].Accounts)
+		}
+	})
+
+	t.Run("uses registered third party decoders", func(t *testing.T) {
+		program := types.NewAccount().PublicKey
+		registry := actions.NewInstructionDecoderRegistry()
+		registry.Register(program, func(ix types.Instruction) (*actions.DecodedInstruction, error) {
+			return &actions.DecodedInstruction{Program: "my-program", Type: "ping", Summary: "Ping"}, nil
+		})
+
+		tx := newTestTransaction(user, types.Instruction{ProgramID: program, Data: []byte{0}})
+		decoded, err := registry.DecodeTransaction(tx)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if decoded[0].Program != "my-program" || decoded[0].ProgramID != program {
+			t.Errorf("got %s (%s) want my-program (%s)", decoded[0].Program, decoded[0].ProgramID, program)
+		}
+	})
+
+	t.Run("throws on malformed instruction data", func(t *testing.T) {
+		tx := newTestTransaction(user, types.Instruction{
+			ProgramID: common.SystemProgramID,
+			Accounts:  []types.AccountMeta{{PubKey: user, IsSigner: true, IsWritable: true}},
+			Data:      []byte{2, 0, 0, 0, 1},
+		})
+		_, err := actions.DecodeTransaction(tx)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
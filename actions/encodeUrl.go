@@ -27,7 +27,12 @@ func EncodeUrl(fields any, protocol SupportedProtocol) (*url.URL, error) {
 		return encodeBlinkUrl(blinkUrlFields, protocol)
 	}
 
-	return nil, &EncodedUrlError{"invalid field type, must be of type *ActionRequestURLFields or *BlinkUrlFields"}
+	transferUrlFields, isTransferField := fields.(*TransferRequestURLFields)
+	if isTransferField {
+		return encodeTransferRequestUrl(transferUrlFields, protocol)
+	}
+
+	return nil, &EncodedUrlError{"invalid field type, must be of type *ActionRequestURLFields, *BlinkUrlFields or *TransferRequestURLFields"}
 
 }
 
@@ -76,3 +81,38 @@ func encodeBlinkUrl(fields *BlinkURLFields, protocol SupportedProtocol) (*url.UR
 	URL.RawQuery = queryParams.Encode()
 	return URL, nil
 }
+
+func encodeTransferRequestUrl(fields *TransferRequestURLFields, protocol SupportedProtocol) (*url.URL, error) {
+	if protocol != SOLANA_PAY_PROTOCOL {
+		return nil, &EncodedUrlError{"transfer requests use the solana protocol"}
+	}
+	// Parameters keep the order of the Solana Pay spec
+	var params []string
+	addParam := func(name string, value string) {
+		params = append(params, name+"="+strings.ReplaceAll(url.QueryEscape(value), "+", "%20"))
+	}
+	if fields.Amount != nil {
+		addParam("amount", fields.Amount.String())
+	}
+	if fields.SplToken != nil {
+		addParam("spl-token", fields.SplToken.String())
+	}
+	for _, reference := range fields.Reference {
+		addParam("reference", reference.String())
+	}
+	if fields.Label != nil {
+		addParam("label", *fields.Label)
+	}
+	if fields.Message != nil {
+		addParam("message", *fields.Message)
+	}
+	if fields.Memo != nil {
+		addParam("memo", *fields.Memo)
+	}
+
+	link := fmt.Sprintf("%s:%s", protocol, fields.Recipient)
+	if len(params) > 0 {
+		link += "?" + strings.Join(params, "&")
+	}
+	return url.Parse(link)
+}
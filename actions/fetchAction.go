@@ -0,0 +1,250 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Limits of the requests to action endpoints, which are chosen by whoever shares the link
+const (
+	// Time allowed for a request, including reading the response
+	ACTION_REQUEST_TIMEOUT = 10 * time.Second
+
+	// Size above which a response is rejected, actions are small JSON documents
+	MAX_ACTION_RESPONSE_BYTES = 1 << 20
+)
+
+// Redirects followed by a request to an action endpoint, as many as `http.Client` follows by default
+const MAX_ACTION_REDIRECTS = 10
+
+/*
+Client of the requests to action endpoints, `http.DefaultClient` bounded by
+`ACTION_REQUEST_TIMEOUT`.
+
+Every redirect is checked by `checkers` before it is followed, so a link
+accepted by a registry can't forward the request to a blocked host.
+*/
+func actionHTTPClient(checkers []ActionURLChecker) *http.Client {
+	client := *http.DefaultClient
+	if client.Timeout == 0 || client.Timeout > ACTION_REQUEST_TIMEOUT {
+		client.Timeout = ACTION_REQUEST_TIMEOUT
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= MAX_ACTION_REDIRECTS {
+			return fmt.Errorf("stopped after %d redirects", MAX_ACTION_REDIRECTS)
+		}
+		if err := checkActionURL(req.URL, checkers); err != nil {
+			return &refusedRedirectError{err}
+		}
+		return nil
+	}
+	return &client
+}
+
+// Error of a URL checker refusing a redirect, unwrapped from the `url.Error` of the client
+type refusedRedirectError struct {
+	err error
+}
+
+func (e *refusedRedirectError) Error() string {
+	return e.err.Error()
+}
+
+/*
+Fetch the metadata of an action from a Solana Action request link.
+
+@param link - `link` in the Solana Action spec.
+
+@throws {FetchActionError}
+*/
+func FetchAction(link *url.URL) (*ActionGetResponse, error) {
+	return FetchActionWithOptions(context.Background(), link, nil)
+}
+
+// Options for `FetchActionWithOptions`
+type FetchActionOptions struct {
+	// Run in order on the action link before it is requested
+	URLCheckers []ActionURLChecker
+
+	// Rejects actions whose `X-Blockchain-Ids` don't include the cluster of this connection when set
+	Conn RPCClient
+}
+
+/*
+Fetch the metadata of an action once the URL checkers accept its link.
+
+Errors returned by a URL checker, e.g. `RegistryBlockedError`, or by the
+cluster check, `ClusterMismatchError`, are returned as is.
+
+@param ctx - Cancels the request, e.g. the context of the request being served.
+
+@param link - `link` in the Solana Action spec.
+
+@param options - Fetch options, may be nil.
+
+@throws {FetchActionError}
+*/
+func FetchActionWithOptions(ctx context.Context, link *url.URL, options *FetchActionOptions) (*ActionGetResponse, error) {
+	if options == nil {
+		options = &FetchActionOptions{}
+	}
+	if err := checkActionURL(link, options.URLCheckers); err != nil {
+		return nil, err
+	}
+	var action ActionGetResponse
+	header, err := requestAction(ctx, http.MethodGet, link, nil, &action, options.URLCheckers)
+	if err != nil {
+		return nil, err
+	}
+	if options.Conn != nil {
+		if err := CheckBlockchainIds(ctx, options.Conn, header); err != nil {
+			return nil, err
+		}
+	}
+	if err := action.Validate(); err != nil {
+		return nil, &FetchActionError{err.Error()}
+	}
+	return &action, nil
+}
+
+/*
+Fetch the next action of a chain once the transaction of an action is confirmed.
+
+Returns nil when the POST response has no next action.
+
+@param link - `link` of the action that returned `response`, `post` hrefs are resolved against it.
+
+@param response - POST response of the action.
+
+@param callback - Account and signature of the confirmed transaction.
+
+@throws {FetchActionError}
+*/
+func FetchNextAction(link *url.URL, response *ActionPostResponse, callback NextActionPostRequest) (*ActionGetResponse, error) {
+	return FetchNextActionWithContext(context.Background(), link, response, callback)
+}
+
+/*
+Fetch the next action of a chain, see `FetchNextAction`.
+
+@param ctx - Cancels the callback request.
+
+@throws {FetchActionError}
+*/
+func FetchNextActionWithContext(ctx context.Context, link *url.URL, response *ActionPostResponse, callback NextActionPostRequest) (*ActionGetResponse, error) {
+	if response.Links == nil {
+		return nil, nil
+	}
+	next := response.Links.Next
+	switch next.Type {
+	case NEXT_ACTION_LINK_INLINE:
+		if next.Action == nil {
+			return nil, &FetchActionError{"inline next action missing action"}
+		}
+		if err := next.Action.Validate(); err != nil {
+			return nil, &FetchActionError{err.Error()}
+		}
+		return next.Action, nil
+	case NEXT_ACTION_LINK_POST:
+		if callback.Signature == "" {
+			return nil, &FetchActionError{"next action callback requires the transaction signature"}
+		}
+		href, err := link.Parse(next.Href)
+		if err != nil {
+			return nil, &FetchActionError{err.Error()}
+		}
+		var action ActionGetResponse
+		if _, err := requestAction(ctx, http.MethodPost, href, callback, &action, nil); err != nil {
+			return nil, err
+		}
+		if err := action.Validate(); err != nil {
+			return nil, &FetchActionError{err.Error()}
+		}
+		return &action, nil
+	}
+	return nil, &FetchActionError{fmt.Sprintf("invalid next action type %q", next.Type)}
+}
+
+/*
+Send a JSON request to an action endpoint and decode the response into `out`.
+
+Error responses are returned as `FetchActionError` with the `message` of the
+action error when present. Requests time out after `ACTION_REQUEST_TIMEOUT` and
+responses above `MAX_ACTION_RESPONSE_BYTES` are rejected. Redirects refused by
+`checkers` return the error of the checker as is.
+*/
+func requestAction(ctx context.Context, method string, link *url.URL, body any, out any, checkers []ActionURLChecker) (http.Header, error) {
+	var reqBody io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, &FetchActionError{err.Error()}
+		}
+		reqBody = bytes.NewBuffer(jsonData)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, link.String(), reqBody)
+	if err != nil {
+		return nil, &FetchActionError{err.Error()}
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := actionHTTPClient(checkers).Do(req)
+	if err != nil {
+		var refused *refusedRedirectError
+		if errors.As(err, &refused) {
+			return nil, refused.err
+		}
+		return nil, &FetchActionError{err.Error()}
+	}
+
+	defer res.Body.Close()
+
+	resBody, err := readLimited(res.Body, MAX_ACTION_RESPONSE_BYTES)
+	if err != nil {
+		return nil, &FetchActionError{err.Error()}
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		var actionErr ActionError
+		if json.Unmarshal(resBody, &actionErr) == nil && actionErr.Message != "" {
+			return res.Header, &FetchActionError{actionErr.Message}
+		}
+		return res.Header, &FetchActionError{fmt.Sprintf("unexpected status %s", res.Status)}
+	}
+	if err := json.Unmarshal(resBody, out); err != nil {
+		return res.Header, &FetchActionError{err.Error()}
+	}
+	return res.Header, nil
+}
+
+func checkActionURL(link *url.URL, checkers []ActionURLChecker) error {
+	for _, checker := range checkers {
+		if err := checker.CheckActionURL(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read a body, failing when it is larger than `limit` bytes
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("response exceeds %d bytes", limit)
+	}
+	return body, nil
+}
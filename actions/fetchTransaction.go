@@ -1,11 +1,11 @@
 package actions
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 
@@ -13,12 +13,14 @@ import (
 	"github.com/blocto/solana-go-sdk/common"
 	"github.com/blocto/solana-go-sdk/rpc"
 	"github.com/blocto/solana-go-sdk/types"
-	"github.com/twystd/tweetnacl-go/tweetnacl"
 )
 
 type ActionPostResponseWithSerializedTransaction struct {
 	ActionPostResponse
 	Transaction types.Transaction
+
+	// Last block height at which the transaction's blockhash is valid, zero when the blockhash came from the action
+	LastValidBlockHeight uint64
 }
 
 type FetchActionError struct {
@@ -29,6 +31,40 @@ func (e *FetchActionError) Error() string {
 	return fmt.Sprintf("SerializeTransactionError: %s", e.Message)
 }
 
+/*
+Checks a transaction returned by an action before it is handed to the caller for signing.
+
+Returning an error rejects the transaction.
+*/
+type TransactionVerifier interface {
+	VerifyTransaction(tx *types.Transaction, account common.PublicKey) error
+}
+
+/*
+Checks the link of an action before it is requested and every redirect before
+it is followed, e.g. a `Registry`.
+
+Returning an error refuses the action.
+*/
+type ActionURLChecker interface {
+	CheckActionURL(link *url.URL) error
+}
+
+// Options for `FetchTransactionWithOptions`
+type FetchTransactionOptions struct {
+	// Commitment used for `getLatestBlockhash`
+	Commitment rpc.Commitment
+
+	// Run in order on the action link before it is requested
+	URLCheckers []ActionURLChecker
+
+	// Run in order on the serialized transaction, the first error rejects it
+	Verifiers []TransactionVerifier
+
+	// Skip the check of the `X-Blockchain-Ids` of the response against the cluster of the connection
+	IgnoreBlockchainIds bool
+}
+
 /*
 Fetch the action payload from a Solana Action request link.
 
@@ -42,47 +78,96 @@ Fetch the action payload from a Solana Action request link.
 
 @throws {FetchActionError}
 */
-func FetchTransaction(conn *client.Client, link *url.URL, fields ActionPostRequest, commitment rpc.Commitment) (*ActionPostResponseWithSerializedTransaction, error) {
-	client := &http.Client{}
-	jsonData, err := json.Marshal(fields)
-	if err != nil {
-		return nil, &FetchActionError{err.Error()}
-	}
-	req, err := http.NewRequest(http.MethodPost, link.String(), bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, &FetchActionError{err.Error()}
-	}
+func FetchTransaction(conn RPCClient, link *url.URL, fields ActionPostRequest, commitment rpc.Commitment) (*ActionPostResponseWithSerializedTransaction, error) {
+	return FetchTransactionWithOptions(context.Background(), conn, link, fields, &FetchTransactionOptions{Commitment: commitment})
+}
 
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
+/*
+Fetch the action payload from a Solana Action request link and run the
+configured verifiers on the transaction.
 
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, &FetchActionError{err.Error()}
-	}
+Errors returned by a URL checker or a verifier, e.g. `RegistryBlockedError` or
+`PolicyViolationError`, are returned as is.
+
+`message` actions are not serialized: the response is returned with an empty
+`Transaction` and its payload in `Data`, to be signed with `SignMessage`.
+
+Responses declaring `X-Blockchain-Ids` without the cluster of `conn` are
+rejected with a `ClusterMismatchError`.
+
+@param ctx - Cancels the request and the RPC calls, e.g. the context of the request being served.
 
-	defer res.Body.Close()
+@param connection - A connection to the cluster, pinned with `PinRPC` when the
+transaction is then sent with `SendAndConfirm`.
 
-	body, err := io.ReadAll(res.Body)
+@param link - `link` in the Solana Action spec.
+
+@param fields - Action Post Request Fields
+
+@param options - Fetch options, may be nil.
+
+@throws {FetchActionError}
+*/
+func FetchTransactionWithOptions(ctx context.Context, conn RPCClient, link *url.URL, fields ActionPostRequest, options *FetchTransactionOptions) (*ActionPostResponseWithSerializedTransaction, error) {
+	if options == nil {
+		options = &FetchTransactionOptions{}
+	}
+	if err := checkActionURL(link, options.URLCheckers); err != nil {
+		return nil, err
+	}
+	var actionResp ActionPostResponse
+	header, err := requestAction(ctx, http.MethodPost, link, fields, &actionResp, options.URLCheckers)
 	if err != nil {
-		return nil, &FetchActionError{err.Error()}
+		return nil, err
+	}
+	if !options.IgnoreBlockchainIds {
+		if err := CheckBlockchainIds(ctx, conn, header); err != nil {
+			return nil, err
+		}
 	}
 
-	var actionResp ActionPostResponse
-	json.Unmarshal(body, &actionResp)
+	if actionResp.ResponseType() == POST_RESPONSE_TYPE_MESSAGE {
+		if actionResp.Data == nil {
+			return nil, &FetchActionError{"missing message data"}
+		}
+		if err := actionResp.Data.Validate(); err != nil {
+			return nil, &FetchActionError{err.Error()}
+		}
+		if actionResp.Data.Address != fields.Account {
+			return nil, &FetchActionError{"message address does not match the account"}
+		}
+		return &ActionPostResponseWithSerializedTransaction{ActionPostResponse: actionResp}, nil
+	}
+	if actionResp.ResponseType() != POST_RESPONSE_TYPE_TRANSACTION {
+		return nil, &FetchActionError{fmt.Sprintf("invalid response type %q", actionResp.Type)}
+	}
 
 	if actionResp.Transaction == "" {
 		return nil, &FetchActionError{"missing transaction"}
 	}
 	account := common.PublicKeyFromString(fields.Account)
-	tx, err := SerializeTransaction(conn, account, actionResp.Transaction, commitment)
+	tx, lastValidBlockHeight, err := serializeTransaction(ctx, conn, account, actionResp.Transaction, options.Commitment)
 	if err != nil {
 		return nil, &FetchActionError{err.Error()}
 	}
+	for i, verifier := range options.Verifiers {
+		if err := verifier.VerifyTransaction(tx, account); err != nil {
+			// The transaction won't be sent, earlier verifiers give back what they reserved
+			for _, accepted := range options.Verifiers[:i] {
+				if settler, ok := accepted.(SpendSettler); ok {
+					if releaseErr := settler.ReleaseSpend(tx, account); releaseErr != nil {
+						err = errors.Join(err, releaseErr)
+					}
+				}
+			}
+			return nil, err
+		}
+	}
 
 	actionPostResp := new(ActionPostResponseWithSerializedTransaction)
 	actionPostResp.ActionPostResponse = actionResp
 	actionPostResp.Transaction = *tx
+	actionPostResp.LastValidBlockHeight = lastValidBlockHeight
 
 	return actionPostResp, nil
 }
@@ -111,66 +196,115 @@ Serialize a base64 encoded transaction into a web3.js `Transaction`.
 
 @throws {SerializeTransactionError}
 */
-func SerializeTransaction(conn *client.Client, account common.PublicKey, base64Tx string, commitment rpc.Commitment) (*types.Transaction, error) {
+func SerializeTransaction(conn RPCClient, account common.PublicKey, base64Tx string, commitment rpc.Commitment) (*types.Transaction, error) {
+	return SerializeTransactionWithContext(context.Background(), conn, account, base64Tx, commitment)
+}
+
+/*
+Serialize a base64 encoded transaction, see `SerializeTransaction`.
+
+@param ctx - Cancels the blockhash request.
+
+@throws {SerializeTransactionError}
+*/
+func SerializeTransactionWithContext(ctx context.Context, conn RPCClient, account common.PublicKey, base64Tx string, commitment rpc.Commitment) (*types.Transaction, error) {
+	tx, _, err := serializeTransaction(ctx, conn, account, base64Tx, commitment)
+	return tx, err
+}
 
-	tx, err := types.TransactionDeserialize([]byte(base64Tx))
+/*
+Serialize a base64 encoded transaction and return the last block height at
+which its blockhash is valid. The block height is zero when the blockhash was
+provided by the action and not fetched.
+*/
+func serializeTransaction(ctx context.Context, conn RPCClient, account common.PublicKey, base64Tx string, commitment rpc.Commitment) (*types.Transaction, uint64, error) {
+	tx, err := decodeTransaction(base64Tx)
 	if err != nil {
-		return nil, &SerializeTransactionError{err.Error()}
+		return nil, 0, err
 	}
 	sigs := tx.Signatures
-	feePayer := tx.Message.Accounts[0]
-	recentBlockHash := tx.Message.RecentBlockHash
+	var lastValidBlockHeight uint64
 
 	if len(sigs) > 0 {
-		if feePayer.String() == "" {
-			return nil, &SerializeTransactionError{"missing fee payer"}
-		}
-		if feePayer.String() != string(sigs[0]) {
-			return nil, &SerializeTransactionError{"invalid fee payer"}
+		if len(tx.Message.Accounts) < len(sigs) {
+			return nil, 0, &SerializeTransactionError{"missing fee payer"}
 		}
-		if recentBlockHash == "" {
-			return nil, &SerializeTransactionError{"recent block hash missing"}
+		if tx.Message.RecentBlockHash == "" {
+			return nil, 0, &SerializeTransactionError{"recent block hash missing"}
 		}
 
 		// A valid signature for everything except `account` must be provided.
-		msg, err := tx.Serialize()
+		msg, err := tx.Message.Serialize()
 		if err != nil {
-			return nil, &SerializeTransactionError{err.Error()}
+			return nil, 0, &SerializeTransactionError{err.Error()}
 		}
 
-		for _, s := range sigs {
-			if s != nil {
-				ok, err := tweetnacl.CryptoOneTimeAuthVerify(s, msg, feePayer.Bytes())
-				if err != nil {
-					return nil, &SerializeTransactionError{err.Error()}
+		for i, s := range sigs {
+			signer := tx.Message.Accounts[i]
+			if !isEmptySignature(s) {
+				if !verifySignature(signer, msg, s) {
+					return nil, 0, &SerializeTransactionError{"invalid signature"}
 				}
-				if !ok {
-					return nil, &SerializeTransactionError{"invalid signature"}
-				} else if feePayer == account {
-					// If the only signature expected is for `account`, ignore the recent blockhash in the transaction.
-					if len(sigs) == 1 {
-						recentBlkHash, err := conn.GetLatestBlockhashWithConfig(context.Background(), client.GetLatestBlockhashConfig{
-							Commitment: commitment,
-						})
-						if err != nil {
-							return nil, &SerializeTransactionError{err.Error()}
-						}
-						tx.Message.RecentBlockHash = recentBlkHash.Blockhash
+			} else if signer == account {
+				// If the only signature expected is for `account`, ignore the recent blockhash in the transaction.
+				// Durable nonce transactions carry the nonce value instead of a blockhash and are kept as is.
+				if len(sigs) == 1 && !IsNonceTransaction(&tx) {
+					recentBlkHash, err := conn.GetLatestBlockhashWithConfig(ctx, client.GetLatestBlockhashConfig{
+						Commitment: commitment,
+					})
+					if err != nil {
+						return nil, 0, &SerializeTransactionError{err.Error()}
 					}
+					tx.Message.RecentBlockHash = recentBlkHash.Blockhash
+					lastValidBlockHeight = recentBlkHash.LatestValidBlockHeight
 				}
 			} else {
-				return nil, &SerializeTransactionError{"missing signature"}
+				return nil, 0, &SerializeTransactionError{"missing signature"}
 			}
 		}
 	} else {
+		if len(tx.Message.Accounts) == 0 {
+			return nil, 0, &SerializeTransactionError{"missing fee payer"}
+		}
 		tx.Message.Accounts[0] = account
-		recentBlkHash, err := conn.GetLatestBlockhashWithConfig(context.Background(), client.GetLatestBlockhashConfig{
-			Commitment: commitment,
-		})
-		if err != nil {
-			return nil, &SerializeTransactionError{err.Error()}
+		if !IsNonceTransaction(&tx) {
+			recentBlkHash, err := conn.GetLatestBlockhashWithConfig(ctx, client.GetLatestBlockhashConfig{
+				Commitment: commitment,
+			})
+			if err != nil {
+				return nil, 0, &SerializeTransactionError{err.Error()}
+			}
+			tx.Message.RecentBlockHash = recentBlkHash.Blockhash
+			lastValidBlockHeight = recentBlkHash.LatestValidBlockHeight
+		}
+	}
+	return &tx, lastValidBlockHeight, nil
+}
+
+// Decode the wire format of a transaction, base64 encoded with padding as in the spec
+func decodeTransaction(base64Tx string) (types.Transaction, error) {
+	rawTx, err := base64.StdEncoding.DecodeString(base64Tx)
+	if err != nil {
+		return types.Transaction{}, &SerializeTransactionError{err.Error()}
+	}
+	tx, err := types.TransactionDeserialize(rawTx)
+	if err != nil {
+		return types.Transaction{}, &SerializeTransactionError{err.Error()}
+	}
+	return tx, nil
+}
+
+// Reports whether `sig` is the ed25519 signature of the serialized message by `signer`
+func verifySignature(signer common.PublicKey, message []byte, sig types.Signature) bool {
+	return len(sig) == ed25519.SignatureSize && ed25519.Verify(signer.Bytes(), message, sig)
+}
+
+// Signature slots of a transaction that hasn't been signed yet are zero filled
+func isEmptySignature(sig types.Signature) bool {
+	for _, b := range sig {
+		if b != 0 {
+			return false
 		}
-		tx.Message.RecentBlockHash = recentBlkHash.Blockhash
 	}
-	return &tx, nil
+	return true
 }
@@ -2,8 +2,9 @@ package actions
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/blocto/solana-go-sdk/client"
 	"github.com/blocto/solana-go-sdk/rpc"
@@ -17,6 +18,9 @@ func (e *FindReferenceError) Error() string {
 	return fmt.Sprintf("FindReferenceError: %s", e.Message)
 }
 
+// Signatures per `getSignaturesForAddress` page when no limit is given
+const FIND_REFERENCE_LIMIT = 1000
+
 /*
 Find the oldest transaction signature referencing a given public key.
 
@@ -24,43 +28,246 @@ Find the oldest transaction signature referencing a given public key.
 
 @param reference - `reference` in the Solana Action spec.
 
-@param options - Options for `getSignaturesForAddress`.
+@param options - Options for `getSignaturesForAddress`, `Until` only searches newer signatures.
 
 @throws {FindReferenceError}
 */
-func FindReference(connection *client.Client, reference Reference, options *client.GetSignaturesForAddressConfig) (*rpc.SignatureWithStatus, error) {
-	signatures, err := connection.GetSignaturesForAddress(context.Background(), reference.String())
+func FindReference(connection RPCClient, reference Reference, options *client.GetSignaturesForAddressConfig) (*rpc.SignatureWithStatus, error) {
+	oldest, _, err := findOldestSignature(context.Background(), connection, reference, options, nil)
+	return oldest, err
+}
+
+// Signatures per `getSignatureStatuses` request, the most the RPC accepts
+const SIGNATURE_STATUSES_LIMIT = 256
+
+/*
+Fetch the confirmation status of signatures, e.g. found by `FindReference`.
+
+The signatures returned by `getSignaturesForAddress` don't carry it, so the
+statuses are looked up in the transaction history of the cluster.
+
+@param connection - A connection to the cluster.
+
+@param signatures - Base58 encoded signatures.
+
+@return The status of each signature in order, empty for signatures the cluster doesn't know.
+*/
+func FetchConfirmationStatuses(ctx context.Context, connection RPCClient, signatures []string) ([]rpc.Commitment, error) {
+	statuses := make([]rpc.Commitment, 0, len(signatures))
+	for start := 0; start < len(signatures); start += SIGNATURE_STATUSES_LIMIT {
+		end := min(start+SIGNATURE_STATUSES_LIMIT, len(signatures))
+		page, err := connection.GetSignatureStatusesWithConfig(ctx, signatures[start:end], client.GetSignatureStatusesConfig{SearchTransactionHistory: true})
+		if err != nil {
+			return nil, err
+		}
+		for i := start; i < end; i++ {
+			var status rpc.Commitment
+			if i-start < len(page) && page[i-start] != nil && page[i-start].ConfirmationStatus != nil {
+				status = *page[i-start].ConfirmationStatus
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
+/*
+Page through the signatures of `reference` from `Before` back to `Until`.
+
+@param wait - Called before each request, may be nil.
+
+@return The oldest signature and the newest one, the cursor of the next scan.
+*/
+func findOldestSignature(ctx context.Context, connection RPCClient, reference Reference, options *client.GetSignaturesForAddressConfig, wait func(ctx context.Context) error) (*rpc.SignatureWithStatus, string, error) {
+	var oldest *rpc.SignatureWithStatus
+	var newest string
+	err := pageSignatures(ctx, connection, reference, options, wait, func(page []rpc.SignatureWithStatus) bool {
+		if newest == "" {
+			newest = page[0].Signature
+		}
+		oldest = &page[len(page)-1]
+		return true
+	})
 	if err != nil {
-		return nil, err
+		return nil, newest, err
 	}
-	sigLen := len(signatures)
-	if sigLen < 1 {
-		return nil, &FindReferenceError{"not found"}
+	if oldest == nil {
+		return nil, newest, &FindReferenceError{"not found"}
 	}
-	oldest := &signatures[sigLen-1]
-	var limit int
-	if options != nil && options.Limit > 0 {
-		limit = options.Limit
-	} else {
-		limit = 1000
+	return oldest, newest, nil
+}
+
+/*
+Page through the signatures of `reference`, newest first, from `Before` back to
+`Until`, until `visit` returns false.
+
+@param wait - Called before each request, may be nil.
+
+@param visit - Called with each non-empty page.
+*/
+func pageSignatures(ctx context.Context, connection RPCClient, reference Reference, options *client.GetSignaturesForAddressConfig, wait func(ctx context.Context) error, visit func(page []rpc.SignatureWithStatus) bool) error {
+	config := client.GetSignaturesForAddressConfig{}
+	if options != nil {
+		config = *options
 	}
-	if sigLen < limit {
-		return oldest, nil
+	if config.Limit <= 0 {
+		config.Limit = FIND_REFERENCE_LIMIT
 	}
 
-	// Recursively find the oldest one in the unlikely event that signatures up to the limit are found.
-	opts := &client.GetSignaturesForAddressConfig{
-		Limit:      options.Limit,
-		Before:     oldest.Signature,
-		Until:      options.Until,
-		Commitment: options.Commitment,
-	}
-	oldest, err = FindReference(connection, reference, opts)
-	if err != nil {
-		if errors.Is(err, &FindReferenceError{}) {
-			return oldest, nil
+	for {
+		if wait != nil {
+			if err := wait(ctx); err != nil {
+				return err
+			}
+		}
+		page, err := connection.GetSignaturesForAddressWithConfig(ctx, reference.String(), config)
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 || !visit(page) {
+			return nil
 		}
-		return nil, err
+		// A full page may have older signatures before `Until`
+		if len(page) < config.Limit {
+			return nil
+		}
+		config.Before = page[len(page)-1].Signature
+	}
+}
+
+// Options for `FindReferences`
+type FindReferencesOptions struct {
+	// References searched in parallel, defaults to 8
+	Concurrency int
+
+	// Global limit of `getSignaturesForAddress` requests, unlimited when 0
+	RequestsPerSecond float64
+
+	// Signatures per page, defaults to `FIND_REFERENCE_LIMIT`
+	Limit int
+
+	Commitment rpc.Commitment
+
+	// Cursors of an incremental scan: only signatures newer than the given one are searched for a reference
+	Until map[Reference]string
+}
+
+// Result of `FindReferences` for a reference
+type FindReferenceResult struct {
+	Reference Reference
+
+	// Oldest signature found, nil when the reference isn't found or on errors
+	Signature *rpc.SignatureWithStatus
+
+	// Newest signature seen, the `Until` cursor of the next scan, empty when nothing new was found
+	Newest string
+
+	// Error other than the reference not being found
+	Err error
+}
+
+// Reports whether the reference was found
+func (r *FindReferenceResult) Found() bool {
+	return r.Signature != nil
+}
+
+/*
+Find the oldest signature of many references with a pool of workers, streaming
+the results as they finish.
+
+The channel receives exactly one result per reference, then is closed. It must
+be drained, results of the references left when `ctx` is done carry its error.
+
+@param connection - A connection to the cluster.
+
+@param references - `reference` in the Solana Action spec.
+
+@param options - Options of the search, may be nil.
+*/
+func FindReferences(ctx context.Context, connection RPCClient, references []Reference, options *FindReferencesOptions) <-chan FindReferenceResult {
+	if options == nil {
+		options = &FindReferencesOptions{}
+	}
+	limiter := newRateLimiter(options.RequestsPerSecond)
+	results := make(chan FindReferenceResult, max(options.Concurrency, 1))
+	go func() {
+		defer close(results)
+		forEachReference(references, options.Concurrency, func(reference Reference) {
+			result := FindReferenceResult{Reference: reference}
+			if result.Err = ctx.Err(); result.Err == nil {
+				config := &client.GetSignaturesForAddressConfig{Limit: options.Limit, Until: options.Until[reference], Commitment: options.Commitment}
+				signature, newest, err := findOldestSignature(ctx, connection, reference, config, limiter.wait)
+				result.Signature, result.Newest = signature, newest
+				if _, notFound := err.(*FindReferenceError); !notFound {
+					result.Err = err
+				}
+			}
+			results <- result
+		})
+	}()
+	return results
+}
+
+// Run `work` for each reference with a pool of `concurrency` workers, 8 when not positive
+func forEachReference(references []Reference, concurrency int, work func(reference Reference)) {
+	if concurrency <= 0 {
+		concurrency = 8
+	}
+	concurrency = min(concurrency, max(len(references), 1))
+
+	jobs := make(chan Reference, len(references))
+	for _, reference := range references {
+		jobs <- reference
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for reference := range jobs {
+				work(reference)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Spaces requests evenly to stay under a rate
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Wait for the next request slot
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
-	return oldest, nil
 }
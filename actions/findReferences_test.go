@@ -0,0 +1,183 @@
+package actions_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"solana-actions/actions"
+	"testing"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Answers `getSignaturesForAddress` from newest first signatures, honoring `limit`, `before` and `until`
+func pagedSignaturesHandler(signatures map[string][]string, failing ...string) rpcHandler {
+	return func(params json.RawMessage) (any, *rpc.JsonRpcError) {
+		var args []json.RawMessage
+		var address string
+		var config struct {
+			Limit  int    `json:"limit"`
+			Before string `json:"before"`
+			Until  string `json:"until"`
+		}
+		json.Unmarshal(params, &args)
+		json.Unmarshal(args[0], &address)
+		if len(args) > 1 {
+			json.Unmarshal(args[1], &config)
+		}
+		for _, failure := range failing {
+			if failure == address {
+				return nil, &rpc.JsonRpcError{Code: -32005, Message: "node is behind"}
+			}
+		}
+		page := []rpc.SignatureWithStatus{}
+		started := config.Before == ""
+		for i, signature := range signatures[address] {
+			if signature == config.Until || (config.Limit > 0 && len(page) == config.Limit) {
+				break
+			}
+			if started {
+				page = append(page, rpc.SignatureWithStatus{Signature: signature, Slot: uint64(100 - i)})
+			}
+			started = started || signature == config.Before
+		}
+		return page, nil
+	}
+}
+
+func TestFindReferences(t *testing.T) {
+	ctx := context.Background()
+	paid := actions.Reference(types.NewAccount().PublicKey)
+	unpaid := actions.Reference(types.NewAccount().PublicKey)
+	broken := actions.Reference(types.NewAccount().PublicKey)
+	signatures := map[string][]string{paid.String(): {"s0", "s1", "s2", "s3", "s4"}}
+
+	t.Run("streams a result per reference", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{"getSignaturesForAddress": pagedSignaturesHandler(signatures, broken.String())})
+		results := map[actions.Reference]actions.FindReferenceResult{}
+		for result := range actions.FindReferences(ctx, fake.client(), []actions.Reference{paid, unpaid, broken}, &actions.FindReferencesOptions{Limit: 2}) {
+			results[result.Reference] = result
+		}
+		if len(results) != 3 {
+			t.Fatalf("got %d results want 3", len(results))
+		}
+		if found := results[paid]; !found.Found() || found.Signature.Signature != "s4" || found.Newest != "s0" || found.Err != nil {
+			t.Errorf("unexpected result %+v", found)
+		}
+		if notFound := results[unpaid]; notFound.Found() || notFound.Err != nil {
+			t.Errorf("unexpected result %+v", notFound)
+		}
+		if failed := results[broken]; failed.Found() || failed.Err == nil {
+			t.Errorf("unexpected result %+v", failed)
+		}
+		if calls := fake.count("getSignaturesForAddress"); calls != 5 {
+			t.Errorf("got %d calls want 5", calls)
+		}
+	})
+
+	t.Run("only scans signatures newer than the cursor", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{"getSignaturesForAddress": pagedSignaturesHandler(signatures)})
+		options := &actions.FindReferencesOptions{Limit: 2, Until: map[actions.Reference]string{paid: "s3"}}
+		result := <-actions.FindReferences(ctx, fake.client(), []actions.Reference{paid}, options)
+		if !result.Found() || result.Signature.Signature != "s2" || result.Newest != "s0" {
+			t.Errorf("unexpected result %+v", result)
+		}
+
+		options.Until[paid] = "s0"
+		result = <-actions.FindReferences(ctx, fake.client(), []actions.Reference{paid}, options)
+		if result.Found() || result.Err != nil || result.Newest != "" {
+			t.Errorf("unexpected result %+v", result)
+		}
+	})
+
+	t.Run("limits the request rate", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{"getSignaturesForAddress": pagedSignaturesHandler(signatures)})
+		references := make([]actions.Reference, 6)
+		for i := range references {
+			references[i] = actions.Reference(types.NewAccount().PublicKey)
+		}
+		start := time.Now()
+		for range actions.FindReferences(ctx, fake.client(), references, &actions.FindReferencesOptions{Concurrency: 6, RequestsPerSecond: 50}) {
+		}
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Errorf("6 requests at 50/s took %s", elapsed)
+		}
+	})
+
+	t.Run("reports the cancellation", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{"getSignaturesForAddress": pagedSignaturesHandler(signatures)})
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		count := 0
+		for result := range actions.FindReferences(cancelled, fake.client(), []actions.Reference{paid, unpaid}, nil) {
+			if result.Err == nil {
+				t.Errorf("unexpected result %+v", result)
+			}
+			count++
+		}
+		if count != 2 || fake.count("getSignaturesForAddress") != 0 {
+			t.Errorf("got %d results and %d calls", count, fake.count("getSignaturesForAddress"))
+		}
+	})
+
+	t.Run("fetches the confirmation statuses of signatures", func(t *testing.T) {
+		statuses := func(params json.RawMessage) (any, *rpc.JsonRpcError) {
+			var args []json.RawMessage
+			var requested []string
+			var config struct {
+				SearchTransactionHistory bool `json:"searchTransactionHistory"`
+			}
+			json.Unmarshal(params, &args)
+			json.Unmarshal(args[0], &requested)
+			if len(args) > 1 {
+				json.Unmarshal(args[1], &config)
+			}
+			if !config.SearchTransactionHistory || len(requested) > actions.SIGNATURE_STATUSES_LIMIT {
+				return nil, &rpc.JsonRpcError{Code: -32602, Message: "invalid params"}
+			}
+			page := []any{}
+			for _, signature := range requested {
+				if signature == "unknown" {
+					page = append(page, nil)
+					continue
+				}
+				page = append(page, map[string]any{"slot": 1, "confirmationStatus": "finalized"})
+			}
+			return withContext(page), nil
+		}
+		fake := newFakeRPC(t, map[string]rpcHandler{"getSignatureStatuses": statuses})
+		signatures := make([]string, actions.SIGNATURE_STATUSES_LIMIT+1)
+		for i := range signatures {
+			signatures[i] = fmt.Sprintf("s%d", i)
+		}
+		signatures[actions.SIGNATURE_STATUSES_LIMIT] = "unknown"
+
+		got, err := actions.FetchConfirmationStatuses(ctx, fake.client(), signatures)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(got) != len(signatures) || got[0] != rpc.CommitmentFinalized || got[len(got)-1] != "" {
+			t.Errorf("unexpected statuses %v", got)
+		}
+		if fake.count("getSignatureStatuses") != 2 {
+			t.Errorf("got %d calls want 2", fake.count("getSignatureStatuses"))
+		}
+	})
+
+	t.Run("FindReference honors its options", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{"getSignaturesForAddress": pagedSignaturesHandler(signatures)})
+		signature, err := actions.FindReference(fake.client(), paid, &client.GetSignaturesForAddressConfig{Until: "s2", Limit: 1})
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if signature.Signature != "s1" {
+			t.Errorf("got %s want s1", signature.Signature)
+		}
+		if _, err := actions.FindReference(fake.client(), unpaid, nil); err == nil || err.Error() != "FindReferenceError: not found" {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
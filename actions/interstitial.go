@@ -0,0 +1,289 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+)
+
+/*
+Connects the interstitial page to the user's wallet.
+
+`Account` returns the connected account of the request, empty when no wallet
+is connected. `HandleTransaction` receives the transaction returned by the
+action and writes the response shown to the user, e.g. a signing page.
+`message` actions are handed over with their payload in `Data`.
+*/
+type WalletBridge interface {
+	Account(r *http.Request) (string, error)
+	HandleTransaction(w http.ResponseWriter, r *http.Request, resp *ActionPostResponseWithSerializedTransaction) error
+}
+
+// Options for `NewInterstitialHandler`
+type InterstitialOptions struct {
+	// Scheme and host of the blink URLs, defaults to the host of the request
+	BaseURL *url.URL
+
+	// Commitment used for `getLatestBlockhash`
+	Commitment rpc.Commitment
+
+	// Run in order on the action links before they are requested, e.g. a `Registry`
+	URLCheckers []ActionURLChecker
+
+	// Run in order on the transactions before they are handed to the wallet bridge
+	Verifiers []TransactionVerifier
+}
+
+type interstitialInput struct {
+	Name     string
+	Label    string
+	Required bool
+}
+
+type interstitialForm struct {
+	Index    int
+	Label    string
+	Inputs   []interstitialInput
+	Disabled bool
+}
+
+// Form field carrying the index of the submitted linked action, reserved in the action parameters
+const interstitialIndexField = "_index"
+
+type interstitialPage struct {
+	IndexField  string
+	Title       string
+	Description string
+	Icon        string
+	ActionURL   string
+	Error       string
+	Forms       []interstitialForm
+}
+
+// The forms POST back to the page itself, so the page works without JavaScript
+var interstitialTemplate = template.Must(template.New("interstitial").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<meta name="solana-action" content="{{.ActionURL}}">
+</head>
+<body>
+<main class="blink">
+{{- if .Icon}}
+<img class="blink-icon" src="{{.Icon}}" alt="{{.Title}}">
+{{- end}}
+<h1 class="blink-title">{{.Title}}</h1>
+<p class="blink-description">{{.Description}}</p>
+{{- if .Error}}
+<p class="blink-error" role="alert">{{.Error}}</p>
+{{- end}}
+{{- range .Forms}}
+<form class="blink-action" method="post">
+<input type="hidden" name="{{$.IndexField}}" value="{{.Index}}">
+{{- range .Inputs}}
+<input type="text" name="{{.Name}}" placeholder="{{.Label}}" aria-label="{{.Label}}"{{if .Required}} required{{end}}>
+{{- end}}
+<button type="submit"{{if .Disabled}} disabled{{end}}>{{.Label}}</button>
+</form>
+{{- end}}
+</main>
+</body>
+</html>
+`))
+
+/*
+Create an `http.Handler` hosting the interstitial page of blinks.
+
+GET requests render the action of the `action` query param with a form per
+linked action, inputs are generated from its `ActionParameter`s. The forms
+POST back to the page: the href of the submitted action is filled with the
+form values, POSTed with the account of the wallet bridge, and the returned
+transaction is handed to the wallet bridge. POST requests are only accepted
+from the page itself, as told by their `Sec-Fetch-Site` or `Origin` header.
+
+@param conn - A connection to the cluster, used to serialize transactions.
+
+@param bridge - Wallet of the user.
+
+@param options - Handler options, may be nil.
+*/
+func NewInterstitialHandler(conn RPCClient, bridge WalletBridge, options *InterstitialOptions) http.Handler {
+	if options == nil {
+		options = &InterstitialOptions{}
+	}
+	return &interstitialHandler{conn, bridge, options}
+}
+
+type interstitialHandler struct {
+	conn    RPCClient
+	bridge  WalletBridge
+	options *InterstitialOptions
+}
+
+func (h *interstitialHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	fields, err := parseRequestBlink(r, h.options.BaseURL)
+	if err != nil {
+		http.Error(w, "invalid blink", http.StatusBadRequest)
+		return
+	}
+	link := fields.Action.Link
+	// The wallet bridge acts for the user, so forms posted by other sites are refused
+	if r.Method == http.MethodPost && !isSameOriginRequest(r, fields.Blink) {
+		http.Error(w, "cross-site request", http.StatusForbidden)
+		return
+	}
+
+	// The action is fetched again on POST so hrefs and parameters never come from the form
+	action, err := FetchActionWithOptions(r.Context(), link, &FetchActionOptions{URLCheckers: h.options.URLCheckers, Conn: h.conn})
+	if err != nil {
+		var fetchErr *FetchActionError
+		if errors.As(err, &fetchErr) {
+			http.Error(w, "action unavailable", http.StatusBadGateway)
+			return
+		}
+		http.Error(w, "action blocked", http.StatusForbidden)
+		return
+	}
+	if usesInterstitialIndexField(action) {
+		http.Error(w, "action unsupported", http.StatusBadGateway)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		h.render(w, http.StatusOK, action, link, "")
+		return
+	}
+
+	if !action.IsInteractive() {
+		h.render(w, http.StatusConflict, action, link, "This action is no longer available")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.render(w, http.StatusBadRequest, action, link, "Invalid form")
+		return
+	}
+	href, err := interstitialHref(action, link, r.PostForm)
+	if err != nil {
+		var validationErr *ActionValidationError
+		if errors.As(err, &validationErr) {
+			h.render(w, http.StatusBadRequest, action, link, validationErr.Message)
+		} else {
+			h.render(w, http.StatusBadGateway, action, link, "This action is unavailable")
+		}
+		return
+	}
+	account, err := h.bridge.Account(r)
+	if err != nil || account == "" {
+		h.render(w, http.StatusUnauthorized, action, link, "Connect a wallet to continue")
+		return
+	}
+
+	resp, err := FetchTransactionWithOptions(r.Context(), h.conn, href, ActionPostRequest{Account: account}, &FetchTransactionOptions{
+		Commitment:  h.options.Commitment,
+		URLCheckers: h.options.URLCheckers,
+		Verifiers:   h.options.Verifiers,
+	})
+	if err != nil {
+		var fetchErr *FetchActionError
+		if errors.As(err, &fetchErr) {
+			h.render(w, http.StatusBadGateway, action, link, fetchErr.Message)
+			return
+		}
+		h.render(w, http.StatusForbidden, action, link, "The transaction was blocked by your wallet policy")
+		return
+	}
+	if err := h.bridge.HandleTransaction(w, r, resp); err != nil {
+		h.render(w, http.StatusInternalServerError, action, link, "The wallet could not handle the transaction")
+	}
+}
+
+/*
+Reports whether a request was sent by a page of the blink origin.
+
+Browsers send `Sec-Fetch-Site` or at least `Origin` with form posts, requests
+carrying neither are refused.
+*/
+func isSameOriginRequest(r *http.Request, blink *url.URL) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin"
+	}
+	origin, err := url.Parse(r.Header.Get("Origin"))
+	if err != nil {
+		return false
+	}
+	return origin.Scheme == blink.Scheme && strings.EqualFold(origin.Host, blink.Host)
+}
+
+func (h *interstitialHandler) render(w http.ResponseWriter, status int, action *ActionGetResponse, link *url.URL, message string) {
+	page := interstitialPage{
+		IndexField:  interstitialIndexField,
+		Title:       action.Title,
+		Description: action.Description,
+		ActionURL:   fmt.Sprintf("%s:%s", SOLANA_ACTIONS_PROTOCOL, link),
+		Error:       message,
+	}
+	page.Icon = resolveIconURL(link, action.Icon)
+	if message == "" && action.Error != nil {
+		page.Error = action.Error.Message
+	}
+
+	disabled := !action.IsInteractive()
+	for i, linked := range action.LinkedActions() {
+		form := interstitialForm{Index: i, Label: linked.Label, Disabled: disabled}
+		if linked.Parameters != nil {
+			for _, param := range *linked.Parameters {
+				input := interstitialInput{Name: param.Name, Label: param.Name, Required: param.Required != nil && *param.Required}
+				if param.Label != nil {
+					input.Label = *param.Label
+				}
+				form.Inputs = append(form.Inputs, input)
+			}
+		}
+		page.Forms = append(page.Forms, form)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	interstitialTemplate.Execute(w, page)
+}
+
+// Href of the submitted action with its `{name}` parameters filled from the form
+func interstitialHref(action *ActionGetResponse, link *url.URL, form url.Values) (*url.URL, error) {
+	linked := action.LinkedActions()
+	index, err := strconv.Atoi(form.Get(interstitialIndexField))
+	if err != nil || index < 0 || index >= len(linked) {
+		return nil, &ActionValidationError{"unknown action"}
+	}
+	values := map[string]string{}
+	for name := range form {
+		values[name] = form.Get(name)
+	}
+	return linked[index].ResolveHref(link, values)
+}
+
+// Actions with a parameter named like the index field can't be told apart from the submitted action
+func usesInterstitialIndexField(action *ActionGetResponse) bool {
+	for _, linked := range action.LinkedActions() {
+		if linked.Parameters == nil {
+			continue
+		}
+		for _, param := range *linked.Parameters {
+			if param.Name == interstitialIndexField {
+				return true
+			}
+		}
+	}
+	return false
+}
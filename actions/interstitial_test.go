@@ -0,0 +1,195 @@
+package actions_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"solana-actions/actions"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Wallet bridge of a user identified by the `X-Wallet` header
+type headerWalletBridge struct {
+	mu           sync.Mutex
+	transactions []*actions.ActionPostResponseWithSerializedTransaction
+}
+
+func (b *headerWalletBridge) Account(r *http.Request) (string, error) {
+	return r.Header.Get("X-Wallet"), nil
+}
+
+func (b *headerWalletBridge) HandleTransaction(w http.ResponseWriter, r *http.Request, resp *actions.ActionPostResponseWithSerializedTransaction) error {
+	b.mu.Lock()
+	b.transactions = append(b.transactions, resp)
+	b.mu.Unlock()
+	fmt.Fprint(w, "sign the transaction")
+	return nil
+}
+
+func TestInterstitialHandler(t *testing.T) {
+	user := types.NewAccount()
+	var posted []string
+	provider := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posted = append(posted, r.URL.RequestURI())
+			var req actions.ActionPostRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			tx := newTestTransaction(user.PublicKey, system.Transfer(system.TransferParam{From: user.PublicKey, To: types.NewAccount().PublicKey, Amount: 1}))
+			json.NewEncoder(w).Encode(actions.ActionPostResponse{Transaction: encodeTestTransaction(t, tx)})
+			return
+		}
+		required := true
+		label := "Amount in SOL"
+		action := actions.NewAction("https://example.com/icon.png", "Donate", "Support <b>builders</b>", "Donate")
+		action.AddLinkedAction(actions.LinkedAction{Href: "/api/donate?amount=1", Label: "1 SOL"})
+		action.AddLinkedAction(actions.LinkedAction{
+			Href:       "/api/donate?amount={amount}",
+			Label:      "Custom",
+			Parameters: &[]actions.ActionParameter{{Name: "amount", Label: &label, Required: &required}},
+		})
+		if r.URL.Path == "/api/relative" {
+			action.Icon = "/static/icon.png"
+		}
+		if r.URL.Path == "/api/reserved" {
+			action.AddLinkedAction(actions.LinkedAction{Href: "/api/donate?to={_index}", Label: "Reserved", Parameters: &[]actions.ActionParameter{{Name: "_index"}}})
+		}
+		json.NewEncoder(w).Encode(action)
+	}))
+	defer provider.Close()
+	defaultClient := http.DefaultClient
+	http.DefaultClient = provider.Client()
+	t.Cleanup(func() { http.DefaultClient = defaultClient })
+
+	fake := newFakeRPC(t, map[string]rpcHandler{"getLatestBlockhash": latestBlockhashHandler(500)})
+	bridge := &headerWalletBridge{}
+	server := httptest.NewServer(actions.NewInterstitialHandler(fake.client(), bridge, nil))
+	defer server.Close()
+	blink := server.URL + "/?action=" + url.QueryEscape("solana-action:"+provider.URL+"/api/donate")
+
+	submitFrom := func(t *testing.T, headers http.Header, wallet string, form url.Values) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, blink, strings.NewReader(form.Encode()))
+		for key, values := range headers {
+			req.Header[key] = values
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Wallet", wallet)
+		res, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		body, _ := io.ReadAll(res.Body)
+		return res, string(body)
+	}
+	submit := func(t *testing.T, wallet string, form url.Values) (*http.Response, string) {
+		t.Helper()
+		return submitFrom(t, http.Header{"Origin": {server.URL}}, wallet, form)
+	}
+
+	t.Run("renders a form per linked action", func(t *testing.T) {
+		res, err := http.Get(blink)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		body, _ := io.ReadAll(res.Body)
+		html := string(body)
+		for _, want := range []string{
+			`<button type="submit">1 SOL</button>`,
+			`<input type="text" name="amount" placeholder="Amount in SOL" aria-label="Amount in SOL" required>`,
+			`Support &lt;b&gt;builders&lt;/b&gt;`,
+		} {
+			if !strings.Contains(html, want) {
+				t.Errorf("expected the page to contain %s", want)
+			}
+		}
+	})
+
+	t.Run("resolves relative icons against the action link", func(t *testing.T) {
+		res, err := http.Get(server.URL + "/?action=" + url.QueryEscape("solana-action:"+provider.URL+"/api/relative"))
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		body, _ := io.ReadAll(res.Body)
+		if want := `src="` + provider.URL + `/static/icon.png"`; !strings.Contains(string(body), want) {
+			t.Errorf("expected the page to contain %s", want)
+		}
+	})
+
+	t.Run("posts the filled href and hands the transaction to the wallet", func(t *testing.T) {
+		res, body := submit(t, user.PublicKey.String(), url.Values{"_index": {"1"}, "amount": {"2.5"}})
+		if res.StatusCode != http.StatusOK || body != "sign the transaction" {
+			t.Fatalf("got %d %s", res.StatusCode, body)
+		}
+		if posted[len(posted)-1] != "/api/donate?amount=2.5" {
+			t.Errorf("got %s want %s", posted[len(posted)-1], "/api/donate?amount=2.5")
+		}
+		if len(bridge.transactions) != 1 || bridge.transactions[0].Transaction.Message.RecentBlockHash != testBlockhash {
+			t.Errorf("expected the serialized transaction to reach the wallet bridge")
+		}
+	})
+
+	t.Run("refuses cross-site posts", func(t *testing.T) {
+		transactions := len(bridge.transactions)
+		for name, headers := range map[string]http.Header{
+			"no origin":      {},
+			"other origin":   {"Origin": {"https://evil.example"}},
+			"null origin":    {"Origin": {"null"}},
+			"cross-site":     {"Sec-Fetch-Site": {"cross-site"}, "Origin": {server.URL}},
+			"same-site only": {"Sec-Fetch-Site": {"same-site"}},
+		} {
+			res, _ := submitFrom(t, headers, user.PublicKey.String(), url.Values{"_index": {"0"}})
+			if res.StatusCode != http.StatusForbidden {
+				t.Errorf("%s: got %d want %d", name, res.StatusCode, http.StatusForbidden)
+			}
+		}
+		if len(bridge.transactions) != transactions {
+			t.Error("expected no transaction to reach the wallet bridge")
+		}
+
+		res, body := submitFrom(t, http.Header{"Sec-Fetch-Site": {"same-origin"}}, user.PublicKey.String(), url.Values{"_index": {"0"}})
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("same-origin: got %d %s", res.StatusCode, body)
+		}
+	})
+
+	t.Run("requires the parameters", func(t *testing.T) {
+		res, body := submit(t, user.PublicKey.String(), url.Values{"_index": {"1"}})
+		if res.StatusCode != http.StatusBadRequest || !strings.Contains(body, "missing parameter amount") {
+			t.Errorf("got %d %s", res.StatusCode, body)
+		}
+	})
+
+	t.Run("requires a connected wallet", func(t *testing.T) {
+		res, _ := submit(t, "", url.Values{"_index": {"0"}})
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("got %d want %d", res.StatusCode, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("refuses actions using the reserved index field", func(t *testing.T) {
+		reserved := server.URL + "/?action=" + url.QueryEscape("solana-action:"+provider.URL+"/api/reserved")
+		req, _ := http.NewRequest(http.MethodGet, reserved, nil)
+		res, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if res.StatusCode != http.StatusBadGateway {
+			t.Errorf("got %d want %d", res.StatusCode, http.StatusBadGateway)
+		}
+	})
+
+	t.Run("rejects unknown actions", func(t *testing.T) {
+		res, _ := submit(t, user.PublicKey.String(), url.Values{"_index": {"7"}})
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("got %d want %d", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
@@ -0,0 +1,446 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/mr-tron/base58"
+)
+
+// Outcome of a lint rule
+type LintStatus string
+
+const (
+	LINT_PASS LintStatus = "pass"
+
+	// The endpoint works but some clients may not render or execute it
+	LINT_WARN LintStatus = "warn"
+
+	// The endpoint doesn't follow the spec
+	LINT_FAIL LintStatus = "fail"
+)
+
+// Rules checked by `Lint`
+const (
+	LINT_RULE_OPTIONS_PREFLIGHT = "options-preflight"
+	LINT_RULE_CORS_HEADERS      = "cors-headers"
+	LINT_RULE_GET_RESPONSE      = "get-response"
+	LINT_RULE_LINKED_HREFS      = "linked-hrefs"
+	LINT_RULE_POST_RESPONSE     = "post-response"
+	LINT_RULE_FEE_PAYER         = "fee-payer"
+	LINT_RULE_BLOCKHASH         = "blockhash"
+	LINT_RULE_ACTIONS_JSON      = "actions-json"
+)
+
+// Sections of the Solana Actions spec referenced by the lint rules
+var lintSpecReferences = map[string]string{
+	LINT_RULE_OPTIONS_PREFLIGHT: "https://solana.com/docs/advanced/actions#options-response",
+	LINT_RULE_CORS_HEADERS:      "https://solana.com/docs/advanced/actions#options-response",
+	LINT_RULE_GET_RESPONSE:      "https://solana.com/docs/advanced/actions#get-response",
+	LINT_RULE_LINKED_HREFS:      "https://solana.com/docs/advanced/actions#linked-actions",
+	LINT_RULE_POST_RESPONSE:     "https://solana.com/docs/advanced/actions#post-response",
+	LINT_RULE_FEE_PAYER:         "https://solana.com/docs/advanced/actions#post-response",
+	LINT_RULE_BLOCKHASH:         "https://solana.com/docs/advanced/actions#post-response",
+	LINT_RULE_ACTIONS_JSON:      "https://solana.com/docs/advanced/actions#actionsjson",
+}
+
+// Result of a lint rule
+type LintResult struct {
+	Rule    string     `json:"rule"`
+	Status  LintStatus `json:"status"`
+	Message string     `json:"message"`
+
+	// Section of the spec the rule checks
+	Spec string `json:"spec"`
+}
+
+// Results of `Lint` for an action endpoint
+type LintReport struct {
+	URL     string       `json:"url"`
+	Results []LintResult `json:"results"`
+}
+
+// Worst status of the report
+func (r *LintReport) Status() LintStatus {
+	status := LINT_PASS
+	for _, result := range r.Results {
+		if result.Status == LINT_FAIL {
+			return LINT_FAIL
+		}
+		if result.Status == LINT_WARN {
+			status = LINT_WARN
+		}
+	}
+	return status
+}
+
+// Reports whether no rule failed
+func (r *LintReport) Passed() bool {
+	return r.Status() != LINT_FAIL
+}
+
+func (r *LintReport) add(rule string, status LintStatus, format string, args ...any) {
+	r.Results = append(r.Results, LintResult{Rule: rule, Status: status, Message: fmt.Sprintf(format, args...), Spec: lintSpecReferences[rule]})
+}
+
+// Options for `LintWithOptions`
+type LintOptions struct {
+	// Account POSTed to the action, defaults to a random key
+	Account common.PublicKey
+
+	// Checks the blockhash of the transaction is still valid when set
+	Conn RPCClient
+}
+
+/*
+Exercise an action endpoint the way a blink client would and report how it
+follows the spec.
+
+Unreachable endpoints are reported as failed rules, not as errors.
+
+@param actionURL - URL of the Action API.
+*/
+func Lint(ctx context.Context, actionURL *url.URL) *LintReport {
+	return LintWithOptions(ctx, actionURL, nil)
+}
+
+/*
+Lint an action endpoint with options.
+
+@param actionURL - URL of the Action API.
+
+@param options - Lint options, may be nil.
+*/
+func LintWithOptions(ctx context.Context, actionURL *url.URL, options *LintOptions) *LintReport {
+	if options == nil {
+		options = &LintOptions{}
+	}
+	account := options.Account
+	if account == (common.PublicKey{}) {
+		account = types.NewAccount().PublicKey
+	}
+	report := &LintReport{URL: actionURL.String()}
+
+	lintPreflight(ctx, report, actionURL)
+	action := lintGet(ctx, report, actionURL)
+	if action != nil {
+		href := lintLinkedHrefs(ctx, report, action, actionURL)
+		if href != nil && action.IsInteractive() {
+			tx := lintPost(ctx, report, href, account)
+			if tx != nil {
+				lintTransaction(ctx, report, tx, account, options.Conn)
+			}
+		}
+	}
+	lintActionsJson(ctx, report, actionURL)
+	return report
+}
+
+func lintPreflight(ctx context.Context, report *LintReport, actionURL *url.URL) {
+	res, _, err := lintRequest(ctx, http.MethodOptions, actionURL, nil)
+	if err != nil {
+		report.add(LINT_RULE_OPTIONS_PREFLIGHT, LINT_FAIL, "OPTIONS request failed: %s", err)
+		return
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		report.add(LINT_RULE_OPTIONS_PREFLIGHT, LINT_FAIL, "OPTIONS responded with %s", res.Status)
+		return
+	}
+	methods := strings.ToUpper(res.Header.Get("Access-Control-Allow-Methods"))
+	if res.Header.Get("Access-Control-Allow-Origin") != "*" || !strings.Contains(methods, http.MethodGet) || !strings.Contains(methods, http.MethodPost) {
+		report.add(LINT_RULE_OPTIONS_PREFLIGHT, LINT_FAIL, "preflight must allow any origin to GET and POST")
+		return
+	}
+	report.add(LINT_RULE_OPTIONS_PREFLIGHT, LINT_PASS, "preflight allows GET and POST from any origin")
+}
+
+func lintGet(ctx context.Context, report *LintReport, actionURL *url.URL) *ActionGetResponse {
+	res, body, err := lintRequest(ctx, http.MethodGet, actionURL, nil)
+	if err != nil {
+		report.add(LINT_RULE_GET_RESPONSE, LINT_FAIL, "GET request failed: %s", err)
+		return nil
+	}
+	lintCorsHeaders(report, res.Header)
+	if res.StatusCode != http.StatusOK {
+		report.add(LINT_RULE_GET_RESPONSE, LINT_FAIL, "GET responded with %s", res.Status)
+		return nil
+	}
+
+	var action ActionGetResponse
+	if err := json.Unmarshal(body, &action); err != nil {
+		report.add(LINT_RULE_GET_RESPONSE, LINT_FAIL, "GET response is not a JSON action: %s", err)
+		return nil
+	}
+	if err := action.Validate(); err != nil {
+		report.add(LINT_RULE_GET_RESPONSE, LINT_FAIL, "%s", err)
+		return nil
+	}
+	icon, err := url.Parse(action.Icon)
+	switch {
+	case err != nil || icon.Host == "":
+		report.add(LINT_RULE_GET_RESPONSE, LINT_FAIL, "icon must be an absolute URL")
+	case icon.Scheme != HTTPS_PROTOCOL:
+		report.add(LINT_RULE_GET_RESPONSE, LINT_WARN, "icon should be served over https")
+	case action.Description == "":
+		report.add(LINT_RULE_GET_RESPONSE, LINT_WARN, "description is empty")
+	default:
+		report.add(LINT_RULE_GET_RESPONSE, LINT_PASS, "GET response is a valid %s", action.ActionType())
+	}
+	return &action
+}
+
+func lintCorsHeaders(report *LintReport, header http.Header) {
+	var missing []string
+	for key, value := range ACTIONS_CORS_HEADERS {
+		got := header.Get(key)
+		if key == "Content-Type" {
+			if !strings.HasPrefix(got, value) {
+				report.add(LINT_RULE_CORS_HEADERS, LINT_FAIL, "Content-Type is %q, want %s", got, value)
+				return
+			}
+			continue
+		}
+		if got == "" {
+			missing = append(missing, key)
+		} else if !sameHeaderValues(got, value) {
+			missing = append(missing, fmt.Sprintf("%s (%q)", key, got))
+		}
+	}
+	if header.Get("Access-Control-Allow-Origin") != "*" {
+		report.add(LINT_RULE_CORS_HEADERS, LINT_FAIL, "Access-Control-Allow-Origin must be *")
+	} else if len(missing) > 0 {
+		report.add(LINT_RULE_CORS_HEADERS, LINT_WARN, "headers differ from the standard headers: %s", strings.Join(missing, ", "))
+	} else {
+		report.add(LINT_RULE_CORS_HEADERS, LINT_PASS, "standard headers are set")
+	}
+}
+
+// Returns the href POSTed by the linter: the first linked action, with `1` for each parameter
+func lintLinkedHrefs(ctx context.Context, report *LintReport, action *ActionGetResponse, actionURL *url.URL) *url.URL {
+	var first *url.URL
+	var problems, failures []string
+	requested := map[string]bool{}
+	for _, linked := range action.LinkedActions() {
+		values := map[string]string{}
+		if linked.Parameters != nil {
+			for _, param := range *linked.Parameters {
+				values[param.Name] = "1"
+				if !strings.Contains(linked.Href, "{"+param.Name+"}") {
+					problems = append(problems, fmt.Sprintf("%q does not use parameter %s", linked.Href, param.Name))
+				}
+			}
+		}
+		href, err := linked.ResolveHref(actionURL, values)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if href.Scheme != actionURL.Scheme {
+			problems = append(problems, fmt.Sprintf("%q does not use %s", linked.Href, actionURL.Scheme))
+		}
+		if !requested[href.String()] {
+			requested[href.String()] = true
+			if failure := lintReachable(ctx, http.MethodGet, href); failure != "" {
+				failures = append(failures, failure)
+				continue
+			}
+		}
+		if first == nil {
+			first = href
+		}
+	}
+	if action.ActionType() == ACTION_TYPE_EXTERNAL_LINK {
+		if link, err := action.ExternalLinkURL(); err == nil {
+			if failure := lintReachable(ctx, http.MethodHead, link); failure != "" {
+				failures = append(failures, failure)
+			}
+		}
+	}
+	switch {
+	case len(failures) > 0:
+		report.add(LINT_RULE_LINKED_HREFS, LINT_FAIL, "%s", strings.Join(append(failures, problems...), ", "))
+	case first == nil:
+		report.add(LINT_RULE_LINKED_HREFS, LINT_FAIL, "no linked href resolves: %s", strings.Join(problems, ", "))
+	case len(problems) > 0:
+		report.add(LINT_RULE_LINKED_HREFS, LINT_WARN, "%s", strings.Join(problems, ", "))
+	default:
+		report.add(LINT_RULE_LINKED_HREFS, LINT_PASS, "%d linked hrefs resolve", len(action.LinkedActions()))
+	}
+	return first
+}
+
+// Requests a linked href, returning why it isn't reachable or an empty string. HEAD falls back to GET for servers not supporting it.
+func lintReachable(ctx context.Context, method string, link *url.URL) string {
+	res, _, err := lintRequest(ctx, method, link, nil)
+	if err == nil && method == http.MethodHead && (res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusNotImplemented) {
+		res, _, err = lintRequest(ctx, http.MethodGet, link, nil)
+	}
+	if err != nil {
+		return fmt.Sprintf("%s is unreachable: %s", link, err)
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Sprintf("%s responded with %s", link, res.Status)
+	}
+	return ""
+}
+
+func lintPost(ctx context.Context, report *LintReport, href *url.URL, account common.PublicKey) *types.Transaction {
+	res, body, err := lintRequest(ctx, http.MethodPost, href, ActionPostRequest{Account: account.String()})
+	if err != nil {
+		report.add(LINT_RULE_POST_RESPONSE, LINT_FAIL, "POST request failed: %s", err)
+		return nil
+	}
+	if res.StatusCode >= http.StatusBadRequest && res.StatusCode < http.StatusInternalServerError {
+		var actionErr ActionError
+		if json.Unmarshal(body, &actionErr) == nil && actionErr.Message != "" {
+			report.add(LINT_RULE_POST_RESPONSE, LINT_WARN, "POST with a dummy account was refused: %s", actionErr.Message)
+			return nil
+		}
+		report.add(LINT_RULE_POST_RESPONSE, LINT_FAIL, "POST responded with %s without an action error message", res.Status)
+		return nil
+	}
+	if res.StatusCode != http.StatusOK {
+		report.add(LINT_RULE_POST_RESPONSE, LINT_FAIL, "POST responded with %s", res.Status)
+		return nil
+	}
+
+	var resp ActionPostResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		report.add(LINT_RULE_POST_RESPONSE, LINT_FAIL, "POST response is not JSON: %s", err)
+		return nil
+	}
+	if resp.ResponseType() == POST_RESPONSE_TYPE_MESSAGE {
+		if resp.Data == nil || resp.Data.Validate() != nil || resp.Data.Address != account.String() {
+			report.add(LINT_RULE_POST_RESPONSE, LINT_FAIL, "message response must carry a valid payload for the account")
+			return nil
+		}
+		report.add(LINT_RULE_POST_RESPONSE, LINT_PASS, "POST returned a message to sign")
+		return nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(resp.Transaction)
+	if err != nil || resp.Transaction == "" {
+		report.add(LINT_RULE_POST_RESPONSE, LINT_FAIL, "transaction must be base64 encoded")
+		return nil
+	}
+	tx, err := types.TransactionDeserialize(raw)
+	if err != nil {
+		report.add(LINT_RULE_POST_RESPONSE, LINT_FAIL, "transaction can't be deserialized: %s", err)
+		return nil
+	}
+	report.add(LINT_RULE_POST_RESPONSE, LINT_PASS, "POST returned a transaction")
+	return &tx
+}
+
+func lintTransaction(ctx context.Context, report *LintReport, tx *types.Transaction, account common.PublicKey, conn RPCClient) {
+	switch {
+	case len(tx.Message.Accounts) == 0:
+		report.add(LINT_RULE_FEE_PAYER, LINT_FAIL, "transaction has no fee payer")
+	case tx.Message.Accounts[0] == account:
+		report.add(LINT_RULE_FEE_PAYER, LINT_PASS, "the account pays the fees")
+	case len(tx.Signatures) > 0 && !isEmptySignature(tx.Signatures[0]):
+		report.add(LINT_RULE_FEE_PAYER, LINT_WARN, "fee payer %s is not the account, the transaction is partially signed", tx.Message.Accounts[0])
+	default:
+		report.add(LINT_RULE_FEE_PAYER, LINT_FAIL, "fee payer %s is neither the account nor a signer of the transaction", tx.Message.Accounts[0])
+	}
+
+	blockhash, err := base58.Decode(tx.Message.RecentBlockHash)
+	if err != nil || len(blockhash) != 32 {
+		report.add(LINT_RULE_BLOCKHASH, LINT_FAIL, "recent blockhash %q is invalid", tx.Message.RecentBlockHash)
+		return
+	}
+	if IsNonceTransaction(tx) {
+		report.add(LINT_RULE_BLOCKHASH, LINT_PASS, "transaction uses a durable nonce")
+		return
+	}
+	if conn != nil {
+		valid, err := conn.IsBlockhashValid(ctx, tx.Message.RecentBlockHash)
+		if err != nil {
+			report.add(LINT_RULE_BLOCKHASH, LINT_WARN, "blockhash could not be checked: %s", err)
+			return
+		}
+		if !valid {
+			report.add(LINT_RULE_BLOCKHASH, LINT_WARN, "blockhash is expired, clients must replace it")
+			return
+		}
+	}
+	report.add(LINT_RULE_BLOCKHASH, LINT_PASS, "recent blockhash is set")
+}
+
+func lintActionsJson(ctx context.Context, report *LintReport, actionURL *url.URL) {
+	actionsJsonURL := &url.URL{Scheme: actionURL.Scheme, Host: actionURL.Host, Path: "/actions.json"}
+	res, body, err := lintRequest(ctx, http.MethodGet, actionsJsonURL, nil)
+	if err != nil || res.StatusCode != http.StatusOK {
+		report.add(LINT_RULE_ACTIONS_JSON, LINT_WARN, "actions.json is not served on %s, required on the website linking the action", actionURL.Host)
+		return
+	}
+	var actionsJson ActionsJson
+	if err := json.Unmarshal(body, &actionsJson); err != nil {
+		report.add(LINT_RULE_ACTIONS_JSON, LINT_FAIL, "actions.json is not valid JSON: %s", err)
+		return
+	}
+	if res.Header.Get("Access-Control-Allow-Origin") != "*" {
+		report.add(LINT_RULE_ACTIONS_JSON, LINT_FAIL, "actions.json must allow any origin")
+		return
+	}
+	if len(actionsJson.Rules) == 0 {
+		report.add(LINT_RULE_ACTIONS_JSON, LINT_WARN, "actions.json has no rules")
+		return
+	}
+	report.add(LINT_RULE_ACTIONS_JSON, LINT_PASS, "actions.json has %d rules", len(actionsJson.Rules))
+}
+
+func lintRequest(ctx context.Context, method string, link *url.URL, body any) (*http.Response, []byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		reqBody = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, link.String(), reqBody)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if method == http.MethodOptions {
+		req.Header.Set("Origin", "https://blink.example")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	}
+	res, err := actionHTTPClient(nil).Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	resBody, err := readLimited(res.Body, MAX_ACTION_RESPONSE_BYTES)
+	return res, resBody, err
+}
+
+func sameHeaderValues(a string, b string) bool {
+	split := func(s string) map[string]bool {
+		values := map[string]bool{}
+		for _, v := range strings.Split(s, ",") {
+			values[strings.ToLower(strings.TrimSpace(v))] = true
+		}
+		return values
+	}
+	want := split(b)
+	for v := range split(a) {
+		if !want[v] {
+			return false
+		}
+	}
+	return len(split(a)) == len(want)
+}
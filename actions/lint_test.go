@@ -0,0 +1,179 @@
+package actions_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"solana-actions/actions"
+	"strings"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Action provider returning a transfer paid by the account
+type transferProvider struct {
+	feePayer *common.PublicKey
+}
+
+func (p *transferProvider) GetAction(r *http.Request) (*actions.ActionGetResponse, error) {
+	required := true
+	action := actions.NewAction("https://example.com/icon.png", "Donate", "Support builders", "Donate")
+	action.AddLinkedAction(actions.LinkedAction{
+		Href:       "/api/donate?amount={amount}",
+		Label:      "Donate",
+		Parameters: &[]actions.ActionParameter{{Name: "amount", Required: &required}},
+	})
+	return action, nil
+}
+
+func (p *transferProvider) PostAction(r *http.Request, req actions.ActionPostRequest) (*actions.ActionPostResponse, error) {
+	account := common.PublicKeyFromString(req.Account)
+	feePayer := account
+	if p.feePayer != nil {
+		feePayer = *p.feePayer
+	}
+	tx := newTestTransaction(feePayer, system.Transfer(system.TransferParam{From: account, To: types.NewAccount().PublicKey, Amount: 1}))
+	for i := range tx.Signatures {
+		tx.Signatures[i] = make(types.Signature, 64)
+	}
+	raw, err := tx.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	return &actions.ActionPostResponse{Transaction: base64.StdEncoding.EncodeToString(raw)}, nil
+}
+
+// Action provider of an external link
+type externalLinkProvider string
+
+func (p externalLinkProvider) GetAction(r *http.Request) (*actions.ActionGetResponse, error) {
+	return actions.NewExternalLinkAction("https://example.com/icon.png", "Docs", "Read the docs", "Read", string(p)), nil
+}
+
+func (p externalLinkProvider) PostAction(r *http.Request, req actions.ActionPostRequest) (*actions.ActionPostResponse, error) {
+	return nil, &actions.ActionError{Message: "nothing to sign"}
+}
+
+// Action provider with a linked action that isn't served
+type brokenHrefProvider struct {
+	transferProvider
+}
+
+func (p *brokenHrefProvider) GetAction(r *http.Request) (*actions.ActionGetResponse, error) {
+	action, _ := p.transferProvider.GetAction(r)
+	action.AddLinkedAction(actions.LinkedAction{Href: "/api/missing", Label: "Missing"})
+	return action, nil
+}
+
+func TestLint(t *testing.T) {
+	lint := func(t *testing.T, handler http.Handler) *actions.LintReport {
+		t.Helper()
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		link, _ := url.Parse(server.URL + "/api/donate")
+		return actions.Lint(context.Background(), link)
+	}
+	statusOf := func(report *actions.LintReport, rule string) actions.LintStatus {
+		for _, result := range report.Results {
+			if result.Rule == rule {
+				return result.Status
+			}
+		}
+		return ""
+	}
+
+	t.Run("conforming endpoint passes", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.Handle("/api/", actions.NewActionHandler(&transferProvider{}))
+		mux.HandleFunc("/actions.json", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			json.NewEncoder(w).Encode(actions.ActionsJson{Rules: []actions.ActionRuleObject{{PathPattern: "/donate", ApiPath: "/api/donate"}}})
+		})
+		report := lint(t, mux)
+		if report.Status() != actions.LINT_PASS {
+			t.Fatalf("report should pass: %+v", report.Results)
+		}
+		for _, rule := range []string{actions.LINT_RULE_OPTIONS_PREFLIGHT, actions.LINT_RULE_CORS_HEADERS, actions.LINT_RULE_GET_RESPONSE, actions.LINT_RULE_LINKED_HREFS, actions.LINT_RULE_POST_RESPONSE, actions.LINT_RULE_FEE_PAYER, actions.LINT_RULE_BLOCKHASH, actions.LINT_RULE_ACTIONS_JSON} {
+			if statusOf(report, rule) != actions.LINT_PASS {
+				t.Fatalf("rule %s should pass: %+v", rule, report.Results)
+			}
+		}
+		for _, result := range report.Results {
+			if result.Spec == "" {
+				t.Fatalf("rule %s should reference the spec", result.Rule)
+			}
+		}
+	})
+
+	t.Run("missing actions.json warns", func(t *testing.T) {
+		report := lint(t, actions.NewActionHandler(&transferProvider{}))
+		if !report.Passed() || statusOf(report, actions.LINT_RULE_ACTIONS_JSON) != actions.LINT_WARN {
+			t.Fatalf("actions.json should warn: %+v", report.Results)
+		}
+	})
+
+	t.Run("foreign unsigned fee payer fails", func(t *testing.T) {
+		feePayer := types.NewAccount().PublicKey
+		report := lint(t, actions.NewActionHandler(&transferProvider{feePayer: &feePayer}))
+		if report.Passed() || statusOf(report, actions.LINT_RULE_FEE_PAYER) != actions.LINT_FAIL {
+			t.Fatalf("fee payer should fail: %+v", report.Results)
+		}
+	})
+
+	t.Run("endpoint without CORS and label fails", func(t *testing.T) {
+		report := lint(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			json.NewEncoder(w).Encode(actions.ActionGetResponse{Icon: "https://example.com/icon.png", Title: "Donate", Description: "Support builders"})
+		}))
+		for _, rule := range []string{actions.LINT_RULE_OPTIONS_PREFLIGHT, actions.LINT_RULE_CORS_HEADERS, actions.LINT_RULE_GET_RESPONSE} {
+			if statusOf(report, rule) != actions.LINT_FAIL {
+				t.Fatalf("rule %s should fail: %+v", rule, report.Results)
+			}
+		}
+		if statusOf(report, actions.LINT_RULE_POST_RESPONSE) != "" {
+			t.Fatalf("invalid action should not be posted: %+v", report.Results)
+		}
+	})
+
+	t.Run("broken linked hrefs fail", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.Handle("/api/donate", actions.NewActionHandler(&brokenHrefProvider{}))
+		report := lint(t, mux)
+		if report.Passed() || statusOf(report, actions.LINT_RULE_LINKED_HREFS) != actions.LINT_FAIL {
+			t.Fatalf("linked hrefs should fail: %+v", report.Results)
+		}
+		for _, result := range report.Results {
+			if result.Rule == actions.LINT_RULE_LINKED_HREFS && !strings.Contains(result.Message, "/api/missing responded with 404") {
+				t.Errorf("got %s want the broken href", result.Message)
+			}
+		}
+	})
+
+	t.Run("unreachable external links fail", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+		report := lint(t, actions.NewActionHandler(externalLinkProvider(closed.URL+"/docs")))
+		if statusOf(report, actions.LINT_RULE_LINKED_HREFS) != actions.LINT_FAIL {
+			t.Fatalf("external link should fail: %+v", report.Results)
+		}
+	})
+
+	t.Run("unreachable endpoint fails", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		link, _ := url.Parse(server.URL + "/api/donate")
+		report := actions.Lint(context.Background(), link)
+		if report.Passed() {
+			t.Fatalf("report should fail: %+v", report.Results)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package actions
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Thrown when a nonce account can't be fetched or isn't initialized
+type NonceAccountError struct {
+	Message string
+}
+
+func (e *NonceAccountError) Error() string {
+	return fmt.Sprintf("NonceAccountError: %s", e.Message)
+}
+
+/*
+Durable nonce used in place of a recent blockhash, so that a transaction can
+be signed long after it was built.
+*/
+type DurableNonce struct {
+	// Nonce account storing the nonce value
+	Account common.PublicKey
+
+	// Authority that must sign `AdvanceNonceAccount`
+	Authority common.PublicKey
+
+	// Current nonce value, used as the transaction blockhash
+	Value string
+}
+
+// Parameters for `CreateNonceAccountInstructions`
+type CreateNonceAccountParams struct {
+	// Account funding the nonce account
+	From common.PublicKey
+
+	// New nonce account, must sign the transaction
+	NonceAccount common.PublicKey
+
+	// Authority allowed to advance, withdraw and authorize the nonce
+	Authority common.PublicKey
+
+	// Rent exempt balance for `system.NonceAccountSize` bytes
+	Lamports uint64
+}
+
+// Instructions creating and initializing a nonce account
+func CreateNonceAccountInstructions(params CreateNonceAccountParams) []types.Instruction {
+	return []types.Instruction{
+		system.CreateAccount(system.CreateAccountParam{
+			From:     params.From,
+			New:      params.NonceAccount,
+			Owner:    common.SystemProgramID,
+			Lamports: params.Lamports,
+			Space:    system.NonceAccountSize,
+		}),
+		system.InitializeNonceAccount(system.InitializeNonceAccountParam{
+			Nonce: params.NonceAccount,
+			Auth:  params.Authority,
+		}),
+	}
+}
+
+/*
+Fetch the current nonce value of a nonce account.
+
+@param connection - A connection to the cluster.
+
+@param nonceAccount - Address of the nonce account.
+
+@throws {NonceAccountError}
+*/
+func FetchDurableNonce(ctx context.Context, conn RPCClient, nonceAccount common.PublicKey) (*DurableNonce, error) {
+	account, err := conn.GetNonceAccount(ctx, nonceAccount.String())
+	if err != nil {
+		return nil, &NonceAccountError{err.Error()}
+	}
+	// state 1 is `Initialized`
+	if account.State != 1 {
+		return nil, &NonceAccountError{"nonce account is not initialized"}
+	}
+	return &DurableNonce{
+		Account:   nonceAccount,
+		Authority: account.AuthorizedPubkey,
+		Value:     account.Nonce.String(),
+	}, nil
+}
+
+// Instruction advancing the nonce, it must be the first instruction of a nonce transaction
+func (n *DurableNonce) AdvanceInstruction() types.Instruction {
+	return system.AdvanceNonceAccount(system.AdvanceNonceAccountParam{
+		Nonce: n.Account,
+		Auth:  n.Authority,
+	})
+}
+
+// Instruction withdrawing lamports from the nonce account
+func (n *DurableNonce) WithdrawInstruction(to common.PublicKey, lamports uint64) types.Instruction {
+	return system.WithdrawNonceAccount(system.WithdrawNonceAccountParam{
+		Nonce:  n.Account,
+		Auth:   n.Authority,
+		To:     to,
+		Amount: lamports,
+	})
+}
+
+// Instruction handing the nonce authority over to `newAuthority`
+func (n *DurableNonce) AuthorizeInstruction(newAuthority common.PublicKey) types.Instruction {
+	return system.AuthorizeNonceAccount(system.AuthorizeNonceAccountParam{
+		Nonce:   n.Account,
+		Auth:    n.Authority,
+		NewAuth: newAuthority,
+	})
+}
+
+// Builds a message that uses the nonce as its blockhash and advances it first
+func (n *DurableNonce) NewMessage(feePayer common.PublicKey, instructions []types.Instruction) types.Message {
+	return types.NewMessage(types.NewMessageParam{
+		FeePayer:        feePayer,
+		Instructions:    append([]types.Instruction{n.AdvanceInstruction()}, instructions...),
+		RecentBlockhash: n.Value,
+	})
+}
+
+/*
+Reports whether a transaction uses a durable nonce, i.e. its first
+instruction is `AdvanceNonceAccount`. Its `RecentBlockHash` is the nonce
+value and must not be replaced.
+*/
+func IsNonceTransaction(tx *types.Transaction) bool {
+	msg := tx.Message
+	if len(msg.Instructions) == 0 {
+		return false
+	}
+	ix := msg.Instructions[0]
+	if ix.ProgramIDIndex >= len(msg.Accounts) || msg.Accounts[ix.ProgramIDIndex] != common.SystemProgramID {
+		return false
+	}
+	if len(ix.Data) < 4 || binary.LittleEndian.Uint32(ix.Data) != uint32(system.InstructionAdvanceNonceAccount) {
+		return false
+	}
+	return len(ix.Accounts) >= 3
+}
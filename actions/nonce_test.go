@@ -0,0 +1,81 @@
+package actions_test
+
+import (
+	"solana-actions/actions"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+func TestDurableNonce(t *testing.T) {
+	user := types.NewAccount()
+	nonce := &actions.DurableNonce{
+		Account:   types.NewAccount().PublicKey,
+		Authority: user.PublicKey,
+		Value:     "EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG",
+	}
+	transfer := system.Transfer(system.TransferParam{From: user.PublicKey, To: types.NewAccount().PublicKey, Amount: 1})
+
+	t.Run("builds messages that advance the nonce first", func(t *testing.T) {
+		msg := nonce.NewMessage(user.PublicKey, []types.Instruction{transfer})
+		tx := &types.Transaction{Signatures: make([]types.Signature, msg.Header.NumRequireSignatures), Message: msg}
+
+		if msg.RecentBlockHash != nonce.Value {
+			t.Errorf("got %s want %s", msg.RecentBlockHash, nonce.Value)
+		}
+		if !actions.IsNonceTransaction(tx) {
+			t.Error("expected a nonce transaction")
+		}
+		decoded, err := actions.DecodeTransaction(tx)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if decoded[0].Type != "advanceNonce" {
+			t.Errorf("got %s want %s", decoded[0].Type, "advanceNonce")
+		}
+	})
+
+	t.Run("does not treat regular transactions as nonce transactions", func(t *testing.T) {
+		if actions.IsNonceTransaction(newTestTransaction(user.PublicKey, transfer)) {
+			t.Error("expected a regular transaction")
+		}
+	})
+
+	t.Run("SerializeTransaction keeps the nonce as blockhash", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{
+			"getLatestBlockhash": latestBlockhashHandler(500),
+		})
+		msg := nonce.NewMessage(user.PublicKey, []types.Instruction{transfer})
+		tx := &types.Transaction{Signatures: make([]types.Signature, msg.Header.NumRequireSignatures), Message: msg}
+
+		serialized, err := actions.SerializeTransaction(fake.client(), user.PublicKey, encodeTestTransaction(t, tx), rpc.CommitmentConfirmed)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if serialized.Message.RecentBlockHash != nonce.Value {
+			t.Errorf("got %s want %s", serialized.Message.RecentBlockHash, nonce.Value)
+		}
+		if fake.count("getLatestBlockhash") != 0 {
+			t.Error("expected no blockhash to be fetched")
+		}
+	})
+
+	t.Run("creates nonce accounts", func(t *testing.T) {
+		instructions := actions.CreateNonceAccountInstructions(actions.CreateNonceAccountParams{
+			From:         user.PublicKey,
+			NonceAccount: nonce.Account,
+			Authority:    user.PublicKey,
+			Lamports:     1_447_680,
+		})
+		tx := newTestTransaction(user.PublicKey, instructions...)
+		decoded, err := actions.DecodeTransaction(tx)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if decoded[0].Type != "createAccount" || decoded[1].Type != "initializeNonce" {
+			t.Errorf("got %s, %s want createAccount, initializeNonce", decoded[0].Type, decoded[1].Type)
+		}
+	})
+}
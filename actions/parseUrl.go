@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/mr-tron/base58"
 )
 
 // Thrown when a URL can't be parsed as a Solana Action URL
@@ -16,6 +19,12 @@ func (err ParseUrlError) Error() string {
 }
 
 func ParseURL(url *url.URL) (any, error) {
+	if target, ok, err := parseWalletLink(url); ok {
+		if err != nil {
+			return nil, err
+		}
+		return parseWalletLinkTarget(target)
+	}
 	match, _ := regexp.MatchString(`^https?`, url.Scheme)
 	if match {
 		return parseBlinksURL(url)
@@ -30,11 +39,68 @@ func ParseURL(url *url.URL) (any, error) {
 	}
 	match, _ = regexp.MatchString(`[:%]`, url.Opaque)
 	if !match {
+		if url.Scheme == string(SOLANA_PAY_PROTOCOL) {
+			return parseTransferRequestURL(url)
+		}
 		return nil, &ParseUrlError{"pathname invalid"}
 	}
 	return parseActionRequestURL(url)
 }
 
+// The browse links of an action embed its link, the others a blink or a `solana-action:` URL
+func parseWalletLinkTarget(target *url.URL) (any, error) {
+	if target.Scheme == HTTPS_PROTOCOL && !target.Query().Has(BLINKS_QUERY_PARAM) {
+		return &ActionRequestURLFields{Link: target}, nil
+	}
+	return ParseURL(target)
+}
+
+func parseTransferRequestURL(url *url.URL) (*TransferRequestURLFields, error) {
+	recipient, err := parsePublicKey(url.Opaque)
+	if err != nil {
+		return nil, &ParseUrlError{"recipient invalid"}
+	}
+	queryParams := url.Query()
+	fields := &TransferRequestURLFields{Recipient: recipient}
+
+	if queryParams.Has("amount") {
+		amount, err := ParseAmount(queryParams.Get("amount"))
+		if err != nil {
+			return nil, &ParseUrlError{"amount invalid"}
+		}
+		fields.Amount = &amount
+	}
+	if queryParams.Has("spl-token") {
+		splToken, err := parsePublicKey(queryParams.Get("spl-token"))
+		if err != nil {
+			return nil, &ParseUrlError{"spl-token invalid"}
+		}
+		fields.SplToken = &splToken
+	}
+	for _, value := range queryParams["reference"] {
+		reference, err := parsePublicKey(value)
+		if err != nil {
+			return nil, &ParseUrlError{"reference invalid"}
+		}
+		fields.Reference = append(fields.Reference, Reference(reference))
+	}
+	for name, field := range map[string]**string{"label": &fields.Label, "message": &fields.Message, "memo": &fields.Memo} {
+		if queryParams.Has(name) {
+			value := queryParams.Get(name)
+			*field = &value
+		}
+	}
+	return fields, nil
+}
+
+func parsePublicKey(s string) (common.PublicKey, error) {
+	decoded, err := base58.Decode(s)
+	if err != nil || len(decoded) != common.PublicKeyLength {
+		return common.PublicKey{}, fmt.Errorf("invalid public key %q", s)
+	}
+	return common.PublicKeyFromBytes(decoded), nil
+}
+
 func parseActionRequestURL(url *url.URL) (*ActionRequestURLFields, error) {
 	opaque := url.Opaque
 	queryParams := url.Query()
@@ -43,6 +109,14 @@ func parseActionRequestURL(url *url.URL) (*ActionRequestURLFields, error) {
 	if err != nil {
 		return nil, &ParseUrlError{"invalid url"}
 	}
+	// Links with query params are URL-encoded
+	if link.Scheme != HTTPS_PROTOCOL {
+		if unescaped, err := unescapeActionLink(opaque); err == nil {
+			if link, err = url.Parse(unescaped); err != nil {
+				return nil, &ParseUrlError{"invalid url"}
+			}
+		}
+	}
 	if link.Scheme != HTTPS_PROTOCOL {
 		return nil, &ParseUrlError{"invalid link"}
 	}
@@ -58,6 +132,11 @@ func parseActionRequestURL(url *url.URL) (*ActionRequestURLFields, error) {
 	return actionUrlFields, nil
 }
 
+// The `url` package is shadowed in `parseActionRequestURL`
+func unescapeActionLink(link string) (string, error) {
+	return url.QueryUnescape(link)
+}
+
 func parseBlinksURL(blink *url.URL) (*BlinkURLFields, error) {
 	blinkQuery := blink.Query()
 	link := blinkQuery.Get(BLINKS_QUERY_PARAM)
@@ -0,0 +1,586 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Thrown when a policy file can't be loaded
+type PolicyError struct {
+	Message string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("PolicyError: %s", e.Message)
+}
+
+// Thrown when a transaction violates the wallet policy
+type PolicyViolationError struct {
+	Message string
+
+	// Every rule the transaction violates
+	Reasons []string
+}
+
+func (e *PolicyViolationError) Error() string {
+	return fmt.Sprintf("PolicyViolationError: %s", e.Message)
+}
+
+/*
+Declarative policy applied to action transactions before signing.
+
+Policies are loaded from JSON with `LoadPolicy`, e.g.
+
+	{
+		"allowedPrograms": ["system", "spl-token", "spl-memo"],
+		"forbiddenInstructions": ["spl-token:setAuthority", "system:assign"],
+		"perAction": {"sol": "0.5"},
+		"perDay": {"sol": "2", "mints": {"EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v": 100000000}},
+		"requireMemo": true
+	}
+*/
+type Policy struct {
+	// Programs the transaction may invoke, by name or program id. Empty allows any program.
+	AllowedPrograms []string `json:"allowedPrograms,omitempty"`
+
+	// Instructions that are never allowed, as `program:type`, e.g. `spl-token:approve`
+	ForbiddenInstructions []string `json:"forbiddenInstructions,omitempty"`
+
+	// Caps applied to a single transaction
+	PerAction *SpendLimit `json:"perAction,omitempty"`
+
+	// Caps applied to all transactions accepted for an account during a UTC day
+	PerDay *SpendLimit `json:"perDay,omitempty"`
+
+	// Require a memo instruction
+	RequireMemo bool `json:"requireMemo,omitempty"`
+
+	// Require at least one reference account on a transfer instruction
+	RequireReference bool `json:"requireReference,omitempty"`
+}
+
+// Spend caps of a `Policy`
+type SpendLimit struct {
+	// Maximum SOL spent, as a decimal string
+	SOL string `json:"sol,omitempty"`
+
+	// Maximum amount spent per mint address, in base units
+	Mints map[string]uint64 `json:"mints,omitempty"`
+}
+
+// Value leaving the user's accounts in a transaction
+type Spend struct {
+	Lamports uint64 `json:"lamports"`
+
+	// Base units spent per mint address
+	Mints map[string]uint64 `json:"mints,omitempty"`
+
+	// Base units spent by token `transfer` instructions, which don't name their mint
+	UncheckedTokens uint64 `json:"uncheckedTokens,omitempty"`
+}
+
+func (s *Spend) add(other Spend) {
+	s.Lamports += other.Lamports
+	s.UncheckedTokens += other.UncheckedTokens
+	for mint, amount := range other.Mints {
+		if s.Mints == nil {
+			s.Mints = map[string]uint64{}
+		}
+		s.Mints[mint] += amount
+	}
+}
+
+// Remove `other`, saturating at zero
+func (s *Spend) sub(other Spend) {
+	s.Lamports -= min(s.Lamports, other.Lamports)
+	s.UncheckedTokens -= min(s.UncheckedTokens, other.UncheckedTokens)
+	for mint, amount := range other.Mints {
+		if s.Mints[mint] <= amount {
+			delete(s.Mints, mint)
+		} else {
+			s.Mints[mint] -= amount
+		}
+	}
+}
+
+/*
+Load a policy from JSON.
+
+@throws {PolicyError}
+*/
+func LoadPolicy(r io.Reader) (*Policy, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	var policy Policy
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, &PolicyError{err.Error()}
+	}
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+/*
+Load a policy from a JSON file.
+
+@throws {PolicyError}
+*/
+func LoadPolicyFile(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &PolicyError{err.Error()}
+	}
+	defer f.Close()
+	return LoadPolicy(f)
+}
+
+func (p *Policy) validate() error {
+	for _, forbidden := range p.ForbiddenInstructions {
+		if !strings.Contains(forbidden, ":") {
+			return &PolicyError{fmt.Sprintf("forbidden instruction %q must be of the form program:type", forbidden)}
+		}
+	}
+	for _, limit := range []*SpendLimit{p.PerAction, p.PerDay} {
+		if limit == nil {
+			continue
+		}
+		if _, err := limit.lamports(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the SOL cap in lamports, or nil when SOL isn't capped
+func (l *SpendLimit) lamports() (*uint64, error) {
+	if l == nil || l.SOL == "" {
+		return nil, nil
+	}
+	sol, err := ParseAmount(l.SOL)
+	if err != nil {
+		return nil, &PolicyError{fmt.Sprintf("invalid SOL amount %q", l.SOL)}
+	}
+	value, err := sol.Lamports()
+	if err != nil {
+		return nil, &PolicyError{fmt.Sprintf("invalid SOL amount %q", l.SOL)}
+	}
+	return &value, nil
+}
+
+/*
+Records what each account spent per UTC day.
+
+Spend is reserved when a transaction is verified, so concurrent transactions
+of an account can't all pass a cap, and released when it isn't sent.
+*/
+type SpendLedger interface {
+	Spent(account common.PublicKey, day string) (Spend, error)
+
+	/*
+		Atomically add `spend` to the day of `account` unless the total exceeds `limit`.
+
+		@return The caps the total would exceed, nothing is reserved when there are any.
+	*/
+	Reserve(account common.PublicKey, day string, spend Spend, limit *SpendLimit) ([]string, error)
+
+	// Take back a reservation of a transaction that wasn't sent
+	Release(account common.PublicKey, day string, spend Spend) error
+}
+
+// Settles the spend reserved by a `TransactionVerifier` once `SendAndConfirm` knows the outcome
+type SpendSettler interface {
+	// The transaction was confirmed, its spend stays counted
+	CommitSpend(tx *types.Transaction, account common.PublicKey) error
+
+	// The transaction wasn't sent, failed or expired, its spend is released
+	ReleaseSpend(tx *types.Transaction, account common.PublicKey) error
+}
+
+// In-memory `SpendLedger`, safe for concurrent use
+type MemorySpendLedger struct {
+	mu     sync.Mutex
+	spends map[string]Spend
+}
+
+func NewMemorySpendLedger() *MemorySpendLedger {
+	return &MemorySpendLedger{spends: map[string]Spend{}}
+}
+
+func (l *MemorySpendLedger) Spent(account common.PublicKey, day string) (Spend, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	spent := Spend{}
+	spent.add(l.spends[account.String()+"/"+day])
+	return spent, nil
+}
+
+func (l *MemorySpendLedger) Reserve(account common.PublicKey, day string, spend Spend, limit *SpendLimit) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := account.String() + "/" + day
+	total := Spend{}
+	total.add(l.spends[key])
+	total.add(spend)
+	if reasons := exceededLimits("per day", limit, total); len(reasons) > 0 {
+		return reasons, nil
+	}
+	l.spends[key] = total
+	return nil, nil
+}
+
+func (l *MemorySpendLedger) Release(account common.PublicKey, day string, spend Spend) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := account.String() + "/" + day
+	spent := l.spends[key]
+	spent.sub(spend)
+	l.spends[key] = spent
+	return nil
+}
+
+// Outcome of evaluating a transaction against a `Policy`
+type PolicyDecision struct {
+	Allowed bool `json:"allowed"`
+
+	// Rules the transaction violates, empty when allowed
+	Reasons []string `json:"reasons,omitempty"`
+
+	// Value the transaction moves out of the user's accounts
+	Spend Spend `json:"spend"`
+}
+
+/*
+Applies a `Policy` to transactions and tracks daily spend.
+
+It implements `TransactionVerifier` so it can be passed to `FetchTransactionWithOptions`,
+reserving the spend of the transactions it accepts, and `SpendSettler` so
+`SendAndConfirm` releases the spend of the transactions that don't land.
+*/
+type PolicyEngine struct {
+	Policy *Policy
+
+	Ledger SpendLedger
+
+	// Decoders used for the instructions, defaults to `DefaultInstructionDecoders`
+	Decoders *InstructionDecoderRegistry
+
+	// Clock used for daily caps, defaults to `time.Now`
+	Now func() time.Time
+
+	// Unsettled reservations, by account and message
+	mu           sync.Mutex
+	reservations map[string][]spendReservation
+}
+
+// Spend reserved on a day by `VerifyTransaction`
+type spendReservation struct {
+	day   string
+	spend Spend
+}
+
+/*
+Create an engine for `policy`, tracking spend in memory when `ledger` is nil.
+
+@throws {PolicyError}
+*/
+func NewPolicyEngine(policy *Policy, ledger SpendLedger) (*PolicyEngine, error) {
+	if policy == nil {
+		return nil, &PolicyError{"policy missing"}
+	}
+	if ledger == nil {
+		ledger = NewMemorySpendLedger()
+	}
+	return &PolicyEngine{Policy: policy, Ledger: ledger}, nil
+}
+
+/*
+Evaluate a transaction against the policy without reserving its spend.
+
+@param tx - Output of `SerializeTransaction`.
+
+@param account - Account of the user that will sign the transaction.
+
+@throws {DecodeInstructionError}
+*/
+func (e *PolicyEngine) Evaluate(tx *types.Transaction, account common.PublicKey) (*PolicyDecision, error) {
+	decision, err := e.evaluate(tx, account)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkDaily(decision, account); err != nil {
+		return nil, err
+	}
+	return decision, nil
+}
+
+// Adds the daily caps the spend would exceed to `decision`
+func (e *PolicyEngine) checkDaily(decision *PolicyDecision, account common.PublicKey) error {
+	if e.Policy.PerDay == nil {
+		return nil
+	}
+	spent, err := e.Ledger.Spent(account, e.day())
+	if err != nil {
+		return err
+	}
+	spent.add(decision.Spend)
+	decision.deny(exceededLimits("per day", e.Policy.PerDay, spent))
+	return nil
+}
+
+func (d *PolicyDecision) deny(reasons []string) {
+	d.Reasons = append(d.Reasons, reasons...)
+	d.Allowed = len(d.Reasons) == 0
+}
+
+// Evaluate every rule but the daily caps
+func (e *PolicyEngine) evaluate(tx *types.Transaction, account common.PublicKey) (*PolicyDecision, error) {
+	if e.Policy == nil {
+		return nil, &PolicyError{"policy missing"}
+	}
+	instructions, err := e.decode(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := e.Policy
+	var reasons []string
+
+	if len(policy.AllowedPrograms) > 0 {
+		for _, ix := range instructions {
+			allowedByName := ix.Program != UNKNOWN_PROGRAM && containsString(policy.AllowedPrograms, ix.Program)
+			if !allowedByName && !containsString(policy.AllowedPrograms, ix.ProgramID.String()) {
+				reasons = append(reasons, fmt.Sprintf("instruction %d invokes program %s which is not allowed", ix.Index, ix.ProgramID))
+			}
+		}
+	}
+
+	for _, ix := range instructions {
+		if containsString(policy.ForbiddenInstructions, ix.Program+":"+ix.Type) {
+			reasons = append(reasons, fmt.Sprintf("instruction %d is a forbidden %s:%s instruction", ix.Index, ix.Program, ix.Type))
+		}
+	}
+
+	if policy.RequireMemo && !hasInstruction(instructions, MEMO_PROGRAM, "memo") {
+		reasons = append(reasons, "memo is required")
+	}
+	if policy.RequireReference && len(transferReferenceKeys(instructions)) == 0 {
+		reasons = append(reasons, "reference is required")
+	}
+
+	spend, unknownMint := spendOf(instructions, account)
+	if unknownMint && ((policy.PerAction != nil && len(policy.PerAction.Mints) > 0) || (policy.PerDay != nil && len(policy.PerDay.Mints) > 0)) {
+		reasons = append(reasons, "token transfer without a mint can't be checked against mint caps")
+	}
+	reasons = append(reasons, exceededLimits("per action", policy.PerAction, spend)...)
+
+	return &PolicyDecision{
+		Allowed: len(reasons) == 0,
+		Reasons: reasons,
+		Spend:   spend,
+	}, nil
+}
+
+/*
+Evaluate a transaction, failing when it violates the policy.
+
+The spend of accepted transactions is reserved against the daily caps, so
+concurrent transactions of an account can't exceed them together. Pass the
+engine as `SendAndConfirmOptions.SpendSettler`, or call `ReleaseSpend`, so
+transactions that aren't sent give their spend back.
+
+@throws {PolicyViolationError}
+*/
+func (e *PolicyEngine) VerifyTransaction(tx *types.Transaction, account common.PublicKey) error {
+	decision, err := e.evaluate(tx, account)
+	if err != nil {
+		return err
+	}
+	if !decision.Allowed || e.Policy.PerDay == nil {
+		// Reported along with the other reasons, nothing is reserved for denied transactions
+		if err := e.checkDaily(decision, account); err != nil {
+			return err
+		}
+	} else {
+		key, err := reservationKey(tx, account)
+		if err != nil {
+			return err
+		}
+		day := e.day()
+		reasons, err := e.Ledger.Reserve(account, day, decision.Spend, e.Policy.PerDay)
+		if err != nil {
+			return err
+		}
+		decision.deny(reasons)
+		if decision.Allowed {
+			e.mu.Lock()
+			if e.reservations == nil {
+				e.reservations = map[string][]spendReservation{}
+			}
+			e.reservations[key] = append(e.reservations[key], spendReservation{day, decision.Spend})
+			e.mu.Unlock()
+		}
+	}
+	if !decision.Allowed {
+		return &PolicyViolationError{strings.Join(decision.Reasons, "; "), decision.Reasons}
+	}
+	return nil
+}
+
+// Keep the spend reserved for a confirmed transaction
+func (e *PolicyEngine) CommitSpend(tx *types.Transaction, account common.PublicKey) error {
+	_, err := e.takeReservation(tx, account)
+	return err
+}
+
+// Release the spend reserved for a transaction that wasn't sent or didn't land
+func (e *PolicyEngine) ReleaseSpend(tx *types.Transaction, account common.PublicKey) error {
+	reservation, err := e.takeReservation(tx, account)
+	if err != nil || reservation == nil {
+		return err
+	}
+	return e.Ledger.Release(account, reservation.day, reservation.spend)
+}
+
+// Remove the oldest reservation of a transaction, nil when it has none
+func (e *PolicyEngine) takeReservation(tx *types.Transaction, account common.PublicKey) (*spendReservation, error) {
+	key, err := reservationKey(tx, account)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	reservations := e.reservations[key]
+	if len(reservations) == 0 {
+		return nil, nil
+	}
+	if len(reservations) == 1 {
+		delete(e.reservations, key)
+	} else {
+		e.reservations[key] = reservations[1:]
+	}
+	return &reservations[0], nil
+}
+
+func reservationKey(tx *types.Transaction, account common.PublicKey) (string, error) {
+	message, err := tx.Message.Serialize()
+	if err != nil {
+		return "", &PolicyError{err.Error()}
+	}
+	return account.String() + "/" + string(message), nil
+}
+
+func (e *PolicyEngine) decode(tx *types.Transaction) ([]DecodedInstruction, error) {
+	decoders := e.Decoders
+	if decoders == nil {
+		decoders = DefaultInstructionDecoders
+	}
+	return decoders.DecodeTransaction(tx)
+}
+
+func (e *PolicyEngine) day() string {
+	now := time.Now
+	if e.Now != nil {
+		now = e.Now
+	}
+	return now().UTC().Format(time.DateOnly)
+}
+
+func exceededLimits(scope string, limit *SpendLimit, spend Spend) []string {
+	if limit == nil {
+		return nil
+	}
+	var reasons []string
+	// validated when the policy was loaded
+	if maxLamports, _ := limit.lamports(); maxLamports != nil && spend.Lamports > *maxLamports {
+		reasons = append(reasons, fmt.Sprintf("spends %s SOL, above the %s cap of %s SOL", formatLamports(spend.Lamports), scope, limit.SOL))
+	}
+	for mint, maxAmount := range limit.Mints {
+		if spend.Mints[mint] > maxAmount {
+			reasons = append(reasons, fmt.Sprintf("spends %d of mint %s, above the %s cap of %d", spend.Mints[mint], mint, scope, maxAmount))
+		}
+	}
+	return reasons
+}
+
+// Sums the lamports and tokens moved out of `account`. Reports whether a token transfer had no mint.
+func spendOf(instructions []DecodedInstruction, account common.PublicKey) (Spend, bool) {
+	spend := Spend{Mints: map[string]uint64{}}
+	unknownMint := false
+	for _, ix := range instructions {
+		switch {
+		case ix.Program == SYSTEM_PROGRAM && (ix.Type == "transfer" || ix.Type == "createAccount"):
+			if source, _ := ix.PublicKey("source"); source == account {
+				lamports, _ := ix.Uint64("lamports")
+				spend.Lamports += lamports
+			}
+		case isTokenProgram(ix.Program) && ix.Type == "transferChecked":
+			if authority, _ := ix.PublicKey("authority"); authority == account {
+				mint, _ := ix.PublicKey("mint")
+				amount, _ := ix.Uint64("amount")
+				spend.Mints[mint.String()] += amount
+			}
+		case isTokenProgram(ix.Program) && ix.Type == "transfer":
+			if authority, _ := ix.PublicKey("authority"); authority == account {
+				amount, _ := ix.Uint64("amount")
+				spend.UncheckedTokens += amount
+				unknownMint = true
+			}
+		}
+	}
+	return spend, unknownMint
+}
+
+/*
+Returns the Solana Pay style reference keys attached to transfer instructions:
+read-only, non-signer accounts following the accounts the transfer itself needs.
+*/
+func transferReferenceKeys(instructions []DecodedInstruction) []common.PublicKey {
+	var references []common.PublicKey
+	for _, ix := range instructions {
+		required := 0
+		switch {
+		case ix.Program == SYSTEM_PROGRAM && ix.Type == "transfer":
+			required = 2
+		case isTokenProgram(ix.Program) && ix.Type == "transfer":
+			required = 3
+		case isTokenProgram(ix.Program) && ix.Type == "transferChecked":
+			required = 4
+		default:
+			continue
+		}
+		for i := required; i < len(ix.Accounts); i++ {
+			meta := ix.Accounts[i]
+			if !meta.IsSigner && !meta.IsWritable {
+				references = append(references, meta.PubKey)
+			}
+		}
+	}
+	return references
+}
+
+func hasInstruction(instructions []DecodedInstruction, program string, instructionType string) bool {
+	for _, ix := range instructions {
+		if ix.Program == program && ix.Type == instructionType {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
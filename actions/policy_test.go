@@ -0,0 +1,196 @@
+package actions_test
+
+import (
+	"errors"
+	"solana-actions/actions"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/memo"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+func loadTestPolicy(t *testing.T, raw string) *actions.Policy {
+	t.Helper()
+	policy, err := actions.LoadPolicy(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+	return policy
+}
+
+func newTestPolicyEngine(t *testing.T, policy *actions.Policy) *actions.PolicyEngine {
+	t.Helper()
+	engine, err := actions.NewPolicyEngine(policy, nil)
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+	return engine
+}
+
+func TestPolicyEngine(t *testing.T) {
+	user := types.NewAccount().PublicKey
+	merchant := types.NewAccount().PublicKey
+	mint := types.NewAccount().PublicKey
+
+	t.Run("allows transactions within the policy", func(t *testing.T) {
+		policy := loadTestPolicy(t, `{
+			"allowedPrograms": ["system", "spl-memo"],
+			"perAction": {"sol": "1"},
+			"requireMemo": true
+		}`)
+		tx := newTestTransaction(user,
+			system.Transfer(system.TransferParam{From: user, To: merchant, Amount: 500_000_000}),
+			memo.BuildMemo(memo.BuildMemoParam{SignerPubkeys: []common.PublicKey{user}, Memo: []byte("order-1")}),
+		)
+
+		decision, err := newTestPolicyEngine(t, policy).Evaluate(tx, user)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if !decision.Allowed {
+			t.Errorf("expected the transaction to be allowed: %v", decision.Reasons)
+		}
+		if decision.Spend.Lamports != 500_000_000 {
+			t.Errorf("got %d want %d", decision.Spend.Lamports, 500_000_000)
+		}
+	})
+
+	t.Run("denies violating transactions with every reason", func(t *testing.T) {
+		policy := loadTestPolicy(t, `{
+			"allowedPrograms": ["system"],
+			"forbiddenInstructions": ["spl-token:approve"],
+			"perAction": {"sol": "0.1"},
+			"requireMemo": true,
+			"requireReference": true
+		}`)
+		tx := newTestTransaction(user,
+			system.Transfer(system.TransferParam{From: user, To: merchant, Amount: 500_000_000}),
+			token.Approve(token.ApproveParam{From: types.NewAccount().PublicKey, To: merchant, Auth: user, Amount: 1}),
+		)
+
+		decision, err := newTestPolicyEngine(t, policy).Evaluate(tx, user)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if decision.Allowed {
+			t.Fatal("expected the transaction to be denied")
+		}
+		// program not allowed, forbidden instruction, memo, reference, per action cap
+		if len(decision.Reasons) != 5 {
+			t.Errorf("got %d reasons want 5: %v", len(decision.Reasons), decision.Reasons)
+		}
+	})
+
+	t.Run("accepts transfers carrying a reference", func(t *testing.T) {
+		policy := loadTestPolicy(t, `{"requireReference": true}`)
+		transfer := system.Transfer(system.TransferParam{From: user, To: merchant, Amount: 1})
+		transfer.Accounts = append(transfer.Accounts, types.AccountMeta{PubKey: types.NewAccount().PublicKey})
+		tx := newTestTransaction(user, transfer)
+
+		decision, err := newTestPolicyEngine(t, policy).Evaluate(tx, user)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if !decision.Allowed {
+			t.Errorf("expected the transaction to be allowed: %v", decision.Reasons)
+		}
+	})
+
+	t.Run("enforces daily caps across transactions", func(t *testing.T) {
+		policy := loadTestPolicy(t, `{"perDay": {"mints": {"`+mint.String()+`": 150}}}`)
+		engine := newTestPolicyEngine(t, policy)
+		tx := newTestTransaction(user, token.TransferChecked(token.TransferCheckedParam{
+			From:     types.NewAccount().PublicKey,
+			To:       types.NewAccount().PublicKey,
+			Mint:     mint,
+			Auth:     user,
+			Amount:   100,
+			Decimals: 6,
+		}))
+
+		// Verifying reserves the spend until the transaction is settled
+		if err := engine.VerifyTransaction(tx, user); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		err := engine.VerifyTransaction(tx, user)
+		var violation *actions.PolicyViolationError
+		if !errors.As(err, &violation) {
+			t.Fatalf("expected a PolicyViolationError, got %v", err)
+		}
+		if err := engine.ReleaseSpend(tx, user); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if err := engine.VerifyTransaction(tx, user); err != nil {
+			t.Fatalf("released spend should be available again: %s", err.Error())
+		}
+		if err := engine.CommitSpend(tx, user); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if err := engine.ReleaseSpend(tx, user); err != nil || engine.VerifyTransaction(tx, user) == nil {
+			t.Errorf("committed spend shouldn't be released: %v", err)
+		}
+	})
+
+	t.Run("reserves daily spend atomically", func(t *testing.T) {
+		engine := newTestPolicyEngine(t, loadTestPolicy(t, `{"perDay": {"sol": "1"}}`))
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		accepted := 0
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tx := newTestTransaction(user, system.Transfer(system.TransferParam{From: user, To: types.NewAccount().PublicKey, Amount: 300_000_000}))
+				if engine.VerifyTransaction(tx, user) == nil {
+					mu.Lock()
+					accepted++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if accepted != 3 {
+			t.Errorf("got %d accepted transactions want 3", accepted)
+		}
+	})
+
+	t.Run("counts unchecked token transfers", func(t *testing.T) {
+		engine := newTestPolicyEngine(t, loadTestPolicy(t, `{"perDay": {"sol": "1"}}`))
+		tx := newTestTransaction(user, token.Transfer(token.TransferParam{From: types.NewAccount().PublicKey, To: merchant, Auth: user, Amount: 42}))
+		decision, err := engine.Evaluate(tx, user)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if !decision.Allowed || decision.Spend.UncheckedTokens != 42 {
+			t.Errorf("unexpected decision %+v", decision)
+		}
+	})
+
+	t.Run("requires a policy", func(t *testing.T) {
+		var policyErr *actions.PolicyError
+		if _, err := actions.NewPolicyEngine(nil, nil); !errors.As(err, &policyErr) {
+			t.Errorf("expected a PolicyError, got %v", err)
+		}
+		tx := newTestTransaction(user, system.Transfer(system.TransferParam{From: user, To: merchant, Amount: 1}))
+		if err := (&actions.PolicyEngine{}).VerifyTransaction(tx, user); !errors.As(err, &policyErr) {
+			t.Errorf("expected a PolicyError, got %v", err)
+		}
+	})
+
+	t.Run("rejects invalid policies", func(t *testing.T) {
+		for _, raw := range []string{
+			`{"perAction": {"sol": "1e3"}}`,
+			`{"forbiddenInstructions": ["approve"]}`,
+			`{"unknownField": true}`,
+		} {
+			if _, err := actions.LoadPolicy(strings.NewReader(raw)); err == nil {
+				t.Errorf("expected an error for %s", raw)
+			}
+		}
+	})
+}
@@ -0,0 +1,394 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Thrown when a URL can't be rendered as a QR code
+type QRCodeError struct {
+	Message string
+}
+
+func (e *QRCodeError) Error() string {
+	return fmt.Sprintf("QRCodeError: %s", e.Message)
+}
+
+// Error correction level of a QR code, the share of the symbol that can be damaged
+type QRCodeLevel string
+
+const (
+	QR_LEVEL_L QRCodeLevel = "L" // 7%
+	QR_LEVEL_M QRCodeLevel = "M" // 15%
+	QR_LEVEL_Q QRCodeLevel = "Q" // 25%
+	QR_LEVEL_H QRCodeLevel = "H" // 30%
+)
+
+var qrRecoveryLevels = map[QRCodeLevel]qrcode.RecoveryLevel{
+	QR_LEVEL_L: qrcode.Low,
+	QR_LEVEL_M: qrcode.Medium,
+	QR_LEVEL_Q: qrcode.High,
+	QR_LEVEL_H: qrcode.Highest,
+}
+
+// Bytes of a version 40 QR code in byte mode, URLs with only digits or upper case letters fit more
+var QR_BYTE_CAPACITY = map[QRCodeLevel]int{
+	QR_LEVEL_L: 2953,
+	QR_LEVEL_M: 2331,
+	QR_LEVEL_Q: 1663,
+	QR_LEVEL_H: 1273,
+}
+
+const (
+	QR_DEFAULT_SIZE       = 512
+	QR_DEFAULT_QUIET_ZONE = 4
+
+	// Width of the logo relative to the symbol, it hides about 5% of the modules
+	QR_LOGO_RATIO = 0.22
+)
+
+// Options for `NewQRCode`
+type QRCodeOptions struct {
+	// Defaults to `QR_LEVEL_Q`
+	Level QRCodeLevel
+
+	// Width and height in pixels of the rendered code, defaults to `QR_DEFAULT_SIZE`
+	Size int
+
+	// Modules of blank margin, defaults to `QR_DEFAULT_QUIET_ZONE`
+	QuietZone *int
+
+	// Default to black on white
+	Foreground color.Color
+	Background color.Color
+
+	// Image centered over the code, needs level Q or H
+	Logo image.Image
+
+	// Round modules and finder patterns
+	Rounded bool
+}
+
+/*
+Options of the QR codes rendered by the Solana Pay SDK: 512px, level Q,
+black rounded modules on white.
+
+@param logo - Centered logo, may be nil.
+*/
+func NewSolanaPayQRCodeOptions(logo image.Image) *QRCodeOptions {
+	return &QRCodeOptions{Level: QR_LEVEL_Q, Size: QR_DEFAULT_SIZE, Logo: logo, Rounded: true}
+}
+
+// QR code of a URL
+type QRCode struct {
+	Content string
+	Level   QRCodeLevel
+
+	// modules[y][x] is true for dark modules, without the quiet zone
+	modules [][]bool
+
+	size       int
+	quietZone  int
+	foreground color.Color
+	background color.Color
+	logo       image.Image
+	rounded    bool
+}
+
+/*
+Check that `content` fits in a QR code of the given level.
+
+@throws {QRCodeError}
+*/
+func CheckQRCapacity(content string, level QRCodeLevel) error {
+	_, err := newQRSymbol(content, level)
+	return err
+}
+
+func newQRSymbol(content string, level QRCodeLevel) (*qrcode.QRCode, error) {
+	recovery, ok := qrRecoveryLevels[level]
+	if !ok {
+		return nil, &QRCodeError{fmt.Sprintf("invalid error correction level %q", level)}
+	}
+	symbol, err := qrcode.New(content, recovery)
+	if err != nil {
+		return nil, &QRCodeError{fmt.Sprintf("payload of %d bytes exceeds the capacity of level %s (%d bytes)", len(content), level, QR_BYTE_CAPACITY[level])}
+	}
+	symbol.DisableBorder = true
+	return symbol, nil
+}
+
+/*
+Encode a URL, e.g. returned by `EncodeUrl`, as a QR code.
+
+@param link - `solana:`, `solana-action:` or blink URL.
+
+@param options - Rendering options, may be nil.
+
+@throws {QRCodeError}
+*/
+func NewQRCode(link *url.URL, options *QRCodeOptions) (*QRCode, error) {
+	if options == nil {
+		options = &QRCodeOptions{}
+	}
+	q := &QRCode{
+		Content:    link.String(),
+		Level:      options.Level,
+		size:       options.Size,
+		quietZone:  QR_DEFAULT_QUIET_ZONE,
+		foreground: options.Foreground,
+		background: options.Background,
+		logo:       options.Logo,
+		rounded:    options.Rounded,
+	}
+	if q.Level == "" {
+		q.Level = QR_LEVEL_Q
+	}
+	if q.size == 0 {
+		q.size = QR_DEFAULT_SIZE
+	}
+	if options.QuietZone != nil {
+		q.quietZone = *options.QuietZone
+	}
+	if q.foreground == nil {
+		q.foreground = color.Black
+	}
+	if q.background == nil {
+		q.background = color.White
+	}
+	if q.size < 0 || q.quietZone < 0 {
+		return nil, &QRCodeError{"size and quiet zone can't be negative"}
+	}
+	if q.logo != nil && q.Level != QR_LEVEL_Q && q.Level != QR_LEVEL_H {
+		return nil, &QRCodeError{"a logo needs error correction level Q or H"}
+	}
+	if q.logo != nil && q.logo.Bounds().Empty() {
+		return nil, &QRCodeError{"logo is empty"}
+	}
+
+	symbol, err := newQRSymbol(q.Content, q.Level)
+	if err != nil {
+		return nil, err
+	}
+	q.modules = symbol.Bitmap()
+	if q.size < len(q.modules)+2*q.quietZone {
+		return nil, &QRCodeError{fmt.Sprintf("size must be at least %d pixels", len(q.modules)+2*q.quietZone)}
+	}
+	return q, nil
+}
+
+// Number of modules per side, without the quiet zone
+func (q *QRCode) Modules() int {
+	return len(q.modules)
+}
+
+// Logo area in modules, empty without a logo
+func (q *QRCode) logoRect() (float64, float64) {
+	if q.logo == nil {
+		return 0, 0
+	}
+	n := float64(len(q.modules))
+	width := n * QR_LOGO_RATIO
+	return (n - width) / 2, width
+}
+
+// Reports whether the point at module coordinates `(x, y)` is dark
+func (q *QRCode) darkAt(x float64, y float64) bool {
+	n := len(q.modules)
+	if x < 0 || y < 0 || x >= float64(n) || y >= float64(n) {
+		return false
+	}
+	if start, width := q.logoRect(); width > 0 && x >= start && y >= start && x < start+width && y < start+width {
+		return false
+	}
+	if !q.rounded {
+		return q.modules[int(y)][int(x)]
+	}
+	for _, origin := range q.finderOrigins() {
+		u, v := x-origin[0], y-origin[1]
+		if u >= 0 && v >= 0 && u < 7 && v < 7 {
+			return (inRoundedSquare(u, v, 0, 7, 2) && !inRoundedSquare(u, v, 1, 5, 1.2)) || inRoundedSquare(u, v, 2, 3, 0)
+		}
+	}
+	if !q.modules[int(y)][int(x)] {
+		return false
+	}
+	dx, dy := x-float64(int(x))-0.5, y-float64(int(y))-0.5
+	return dx*dx+dy*dy <= 0.45*0.45
+}
+
+// Top left corners of the three finder patterns
+func (q *QRCode) finderOrigins() [][2]float64 {
+	far := float64(len(q.modules) - 7)
+	return [][2]float64{{0, 0}, {far, 0}, {0, far}}
+}
+
+// Reports whether `(u, v)` is inside the square at `(start, start)` with rounded corners of radius `r`
+func inRoundedSquare(u float64, v float64, start float64, width float64, r float64) bool {
+	end := start + width
+	if u < start || v < start || u >= end || v >= end {
+		return false
+	}
+	cx := clamp(u, start+r, end-r)
+	cy := clamp(v, start+r, end-r)
+	return (u-cx)*(u-cx)+(v-cy)*(v-cy) <= r*r
+}
+
+func clamp(v float64, lo float64, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// Render the QR code
+func (q *QRCode) Image() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, q.size, q.size))
+	scale := float64(q.size) / float64(len(q.modules)+2*q.quietZone)
+	fg := color.RGBAModel.Convert(q.foreground).(color.RGBA)
+	bg := color.RGBAModel.Convert(q.background).(color.RGBA)
+	for py := 0; py < q.size; py++ {
+		for px := 0; px < q.size; px++ {
+			x := (float64(px)+0.5)/scale - float64(q.quietZone)
+			y := (float64(py)+0.5)/scale - float64(q.quietZone)
+			if q.darkAt(x, y) {
+				img.SetRGBA(px, py, fg)
+			} else {
+				img.SetRGBA(px, py, bg)
+			}
+		}
+	}
+	if q.logo != nil {
+		start, width := q.logoRect()
+		// The logo keeps its aspect ratio inside the logo area, with a margin of half a module
+		bounds := q.logo.Bounds()
+		box := (width - 1) * scale
+		ratio := box / float64(max(bounds.Dx(), bounds.Dy()))
+		w, h := int(float64(bounds.Dx())*ratio), int(float64(bounds.Dy())*ratio)
+		left := int((start+float64(q.quietZone))*scale + (width*scale-float64(w))/2)
+		top := int((start+float64(q.quietZone))*scale + (width*scale-float64(h))/2)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				c := q.logo.At(bounds.Min.X+int(float64(x)/ratio), bounds.Min.Y+int(float64(y)/ratio))
+				img.SetRGBA(left+x, top+y, blend(img.RGBAAt(left+x, top+y), c))
+			}
+		}
+	}
+	return img
+}
+
+// Draw `c` over `dst`
+func blend(dst color.RGBA, c color.Color) color.RGBA {
+	r, g, b, a := c.RGBA()
+	mix := func(d uint8, s uint32) uint8 {
+		return uint8((uint32(d)*0x101*(0xffff-a)/0xffff + s) >> 8)
+	}
+	return color.RGBA{mix(dst.R, r), mix(dst.G, g), mix(dst.B, b), 0xff}
+}
+
+/*
+Render the QR code as a PNG.
+
+@throws {QRCodeError}
+*/
+func (q *QRCode) PNG() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, q.Image()); err != nil {
+		return nil, &QRCodeError{err.Error()}
+	}
+	return buf.Bytes(), nil
+}
+
+/*
+Render the QR code as an SVG with one unit per module.
+
+@throws {QRCodeError}
+*/
+func (q *QRCode) WriteSVG(w io.Writer) error {
+	n := len(q.modules)
+	total := n + 2*q.quietZone
+	var path strings.Builder
+	logoStart, logoWidth := q.logoRect()
+	for y, row := range q.modules {
+		for x, dark := range row {
+			if !dark || q.rounded && q.inFinder(x, y) {
+				continue
+			}
+			if logoWidth > 0 && float64(x)+1 > logoStart && float64(y)+1 > logoStart && float64(x) < logoStart+logoWidth && float64(y) < logoStart+logoWidth {
+				continue
+			}
+			px, py := x+q.quietZone, y+q.quietZone
+			if q.rounded {
+				fmt.Fprintf(&path, "M%d.5 %d.05a.45 .45 0 1 0 .01 0z", px, py)
+			} else {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", px, py)
+			}
+		}
+	}
+	if q.rounded {
+		for _, origin := range q.finderOrigins() {
+			x, y := origin[0]+float64(q.quietZone), origin[1]+float64(q.quietZone)
+			path.WriteString(roundedSquarePath(x, y, 7, 2))
+			path.WriteString(roundedSquarePath(x+1, y+1, 5, 1.2))
+			fmt.Fprintf(&path, "M%g %gh3v3h-3z", x+2, y+2)
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`+
+		`<rect width="%d" height="%d" fill="%s"/><path fill-rule="evenodd" fill="%s" d="%s"/>`,
+		total, total, q.size, q.size, total, total, svgColor(q.background), svgColor(q.foreground), path.String()); err != nil {
+		return &QRCodeError{err.Error()}
+	}
+	if q.logo != nil {
+		var logo bytes.Buffer
+		if err := png.Encode(&logo, q.logo); err != nil {
+			return &QRCodeError{err.Error()}
+		}
+		fmt.Fprintf(w, `<image x="%g" y="%g" width="%g" height="%g" href="data:image/png;base64,%s"/>`,
+			logoStart+float64(q.quietZone)+0.5, logoStart+float64(q.quietZone)+0.5, logoWidth-1, logoWidth-1, base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+	if _, err := io.WriteString(w, "</svg>"); err != nil {
+		return &QRCodeError{err.Error()}
+	}
+	return nil
+}
+
+// Render the QR code as an SVG string
+func (q *QRCode) SVG() string {
+	var buf strings.Builder
+	q.WriteSVG(&buf)
+	return buf.String()
+}
+
+func (q *QRCode) inFinder(x int, y int) bool {
+	for _, origin := range q.finderOrigins() {
+		u, v := x-int(origin[0]), y-int(origin[1])
+		if u >= 0 && v >= 0 && u < 7 && v < 7 {
+			return true
+		}
+	}
+	return false
+}
+
+func roundedSquarePath(x float64, y float64, width float64, r float64) string {
+	side := width - 2*r
+	return fmt.Sprintf("M%g %gh%ga%g %g 0 0 1 %g %gv%ga%g %g 0 0 1 %g %gh%ga%g %g 0 0 1 %g %gv%ga%g %g 0 0 1 %g %gz",
+		x+r, y, side, r, r, r, r, side, r, r, -r, r, -side, r, r, -r, -r, -side, r, r, r, -r)
+}
+
+func svgColor(c color.Color) string {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+}
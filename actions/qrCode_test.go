@@ -0,0 +1,99 @@
+package actions_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"solana-actions/actions"
+	"strings"
+	"testing"
+)
+
+func TestQRCode(t *testing.T) {
+	link, _ := url.Parse("solana:4uhcVJyU9pJkvQyS88uRDiswHXSCkY3zQawwpjk2NsNY?amount=1&label=Coffee")
+
+	isDark := func(img image.Image, x int, y int) bool {
+		r, _, _, _ := img.At(x, y).RGBA()
+		return r < 0x8000
+	}
+
+	t.Run("renders modules and quiet zone", func(t *testing.T) {
+		for _, rounded := range []bool{false, true} {
+			quietZone := 2
+			_, err := actions.NewQRCode(link, &actions.QRCodeOptions{QuietZone: &quietZone, Rounded: rounded, Size: 1})
+			if err == nil {
+				t.Fatal("expected an error for a size smaller than the modules")
+			}
+			q, err := actions.NewQRCode(link, &actions.QRCodeOptions{QuietZone: &quietZone, Rounded: rounded, Size: 400})
+			if err != nil {
+				t.Fatalf("err should be nil: %s", err.Error())
+			}
+			size := (q.Modules() + 2*quietZone) * 10
+			q, _ = actions.NewQRCode(link, &actions.QRCodeOptions{QuietZone: &quietZone, Rounded: rounded, Size: size})
+			raw, err := q.PNG()
+			if err != nil {
+				t.Fatalf("err should be nil: %s", err.Error())
+			}
+			img, err := png.Decode(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("err should be nil: %s", err.Error())
+			}
+			if img.Bounds().Dx() != size || img.Bounds().Dy() != size {
+				t.Fatalf("got size %v want %d", img.Bounds(), size)
+			}
+			// Centre and inner ring of the top left finder pattern
+			if isDark(img, 5, 5) || !isDark(img, 55, 55) || isDark(img, 35, 35) {
+				t.Errorf("unexpected finder pattern, rounded %v", rounded)
+			}
+		}
+	})
+
+	t.Run("renders SVG", func(t *testing.T) {
+		q, err := actions.NewQRCode(link, actions.NewSolanaPayQRCodeOptions(nil))
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		svg := q.SVG()
+		if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg"`) || !strings.HasSuffix(svg, "</svg>") || !strings.Contains(svg, `width="512"`) {
+			t.Errorf("unexpected svg %s", svg)
+		}
+	})
+
+	t.Run("centers the logo", func(t *testing.T) {
+		logo := image.NewRGBA(image.Rect(0, 0, 10, 10))
+		for i := range logo.Pix {
+			logo.Pix[i] = 0xff
+		}
+		logo.Set(5, 5, color.RGBA{0xff, 0, 0, 0xff})
+		if _, err := actions.NewQRCode(link, &actions.QRCodeOptions{Level: actions.QR_LEVEL_L, Logo: logo}); err == nil {
+			t.Error("expected an error for a logo at level L")
+		}
+		q, err := actions.NewQRCode(link, actions.NewSolanaPayQRCodeOptions(logo))
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		img := q.Image()
+		if isDark(img, 256, 256) {
+			t.Error("logo area should be cleared")
+		}
+		if !strings.Contains(q.SVG(), "data:image/png;base64,") {
+			t.Error("svg should embed the logo")
+		}
+	})
+
+	t.Run("checks the capacity", func(t *testing.T) {
+		long := "solana:4uhcVJyU9pJkvQyS88uRDiswHXSCkY3zQawwpjk2NsNY?memo=" + strings.Repeat("x", 1500)
+		if err := actions.CheckQRCapacity(long, actions.QR_LEVEL_L); err != nil {
+			t.Errorf("err should be nil: %s", err.Error())
+		}
+		err := actions.CheckQRCapacity(long, actions.QR_LEVEL_H)
+		if err == nil || !strings.Contains(err.Error(), "exceeds the capacity of level H") {
+			t.Errorf("unexpected error %v", err)
+		}
+		if err := actions.CheckQRCapacity(long, "X"); err == nil {
+			t.Error("expected an error for an invalid level")
+		}
+	})
+}
@@ -0,0 +1,498 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Thrown by a `ReferenceStore`
+type ReferenceStoreError struct {
+	Message string
+}
+
+func (e *ReferenceStoreError) Error() string {
+	return fmt.Sprintf("ReferenceStoreError: %s", e.Message)
+}
+
+// Status of an order
+type OrderStatus string
+
+const (
+	ORDER_STATUS_PENDING OrderStatus = "pending"
+	ORDER_STATUS_PAID    OrderStatus = "paid"
+	ORDER_STATUS_EXPIRED OrderStatus = "expired"
+)
+
+// Order paid by a transfer carrying its reference
+type Order struct {
+	ID        string      `json:"id"`
+	Reference Reference   `json:"reference"`
+	Status    OrderStatus `json:"status"`
+
+	// Expected transfer, its `Reference` includes the order reference
+	Transfer TransferRequestURLFields `json:"transfer"`
+
+	// Signature of the transaction paying the order
+	Signature string `json:"signature,omitempty"`
+
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	PaidAt    *time.Time `json:"paidAt,omitempty"`
+}
+
+/*
+Mapping of orders to references, safe for concurrent use.
+
+Late payments of expired orders still mark them paid, the money was received.
+*/
+type ReferenceStore interface {
+	// Generate a reference for a new order, expiring after `ttl`
+	Issue(ctx context.Context, orderID string, transfer TransferRequestURLFields, ttl time.Duration) (*Order, error)
+
+	Lookup(ctx context.Context, orderID string) (*Order, error)
+
+	LookupReference(ctx context.Context, reference Reference) (*Order, error)
+
+	// Pending orders, oldest first
+	Pending(ctx context.Context) ([]Order, error)
+
+	// Expired orders, oldest first
+	Expired(ctx context.Context) ([]Order, error)
+
+	MarkPaid(ctx context.Context, orderID string, signature string) (*Order, error)
+
+	// Expire the pending orders whose deadline is before `now`
+	Expire(ctx context.Context, now time.Time) ([]Order, error)
+}
+
+// Generate a random reference
+func NewReference() Reference {
+	return Reference(types.NewAccount().PublicKey)
+}
+
+func newOrder(orderID string, transfer TransferRequestURLFields, ttl time.Duration) (*Order, error) {
+	if orderID == "" {
+		return nil, &ReferenceStoreError{"order id missing"}
+	}
+	if ttl <= 0 {
+		return nil, &ReferenceStoreError{"ttl must be positive"}
+	}
+	now := time.Now().UTC()
+	reference := NewReference()
+	transfer.Reference = append(append([]Reference{}, transfer.Reference...), reference)
+	return &Order{
+		ID:        orderID,
+		Reference: reference,
+		Status:    ORDER_STATUS_PENDING,
+		Transfer:  transfer,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, nil
+}
+
+// Update `order` for a payment by `signature`
+func markOrderPaid(order *Order, signature string) error {
+	if order.Status == ORDER_STATUS_PAID {
+		if order.Signature == signature {
+			return nil
+		}
+		return &ReferenceStoreError{fmt.Sprintf("order %s already paid by %s", order.ID, order.Signature)}
+	}
+	now := time.Now().UTC()
+	order.Status, order.Signature, order.PaidAt = ORDER_STATUS_PAID, signature, &now
+	return nil
+}
+
+// In-memory `ReferenceStore`
+type MemoryReferenceStore struct {
+	mu         sync.RWMutex
+	orders     map[string]*Order
+	references map[Reference]string
+}
+
+func NewMemoryReferenceStore() *MemoryReferenceStore {
+	return &MemoryReferenceStore{orders: map[string]*Order{}, references: map[Reference]string{}}
+}
+
+func (s *MemoryReferenceStore) Issue(ctx context.Context, orderID string, transfer TransferRequestURLFields, ttl time.Duration) (*Order, error) {
+	order, err := newOrder(orderID, transfer, ttl)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orders[orderID]; ok {
+		return nil, &ReferenceStoreError{fmt.Sprintf("order %s already issued", orderID)}
+	}
+	s.orders[orderID] = order
+	s.references[order.Reference] = orderID
+	result := *order
+	return &result, nil
+}
+
+func (s *MemoryReferenceStore) Lookup(ctx context.Context, orderID string) (*Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	order, ok := s.orders[orderID]
+	if !ok {
+		return nil, &ReferenceStoreError{fmt.Sprintf("order %s not found", orderID)}
+	}
+	result := *order
+	return &result, nil
+}
+
+func (s *MemoryReferenceStore) LookupReference(ctx context.Context, reference Reference) (*Order, error) {
+	s.mu.RLock()
+	orderID, ok := s.references[reference]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, &ReferenceStoreError{fmt.Sprintf("reference %s not found", reference)}
+	}
+	return s.Lookup(ctx, orderID)
+}
+
+func (s *MemoryReferenceStore) Pending(ctx context.Context) ([]Order, error) {
+	return s.withStatus(ORDER_STATUS_PENDING), nil
+}
+
+func (s *MemoryReferenceStore) Expired(ctx context.Context) ([]Order, error) {
+	return s.withStatus(ORDER_STATUS_EXPIRED), nil
+}
+
+func (s *MemoryReferenceStore) withStatus(status OrderStatus) []Order {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var orders []Order
+	for _, order := range s.orders {
+		if order.Status == status {
+			orders = append(orders, *order)
+		}
+	}
+	sortOrders(orders)
+	return orders
+}
+
+func (s *MemoryReferenceStore) MarkPaid(ctx context.Context, orderID string, signature string) (*Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[orderID]
+	if !ok {
+		return nil, &ReferenceStoreError{fmt.Sprintf("order %s not found", orderID)}
+	}
+	if err := markOrderPaid(order, signature); err != nil {
+		return nil, err
+	}
+	result := *order
+	return &result, nil
+}
+
+func (s *MemoryReferenceStore) Expire(ctx context.Context, now time.Time) ([]Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var expired []Order
+	for _, order := range s.orders {
+		if order.Status == ORDER_STATUS_PENDING && !order.ExpiresAt.After(now) {
+			order.Status = ORDER_STATUS_EXPIRED
+			expired = append(expired, *order)
+		}
+	}
+	sortOrders(expired)
+	return expired, nil
+}
+
+func sortOrders(orders []Order) {
+	sort.Slice(orders, func(i, j int) bool {
+		if orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].ID < orders[j].ID
+		}
+		return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+	})
+}
+
+// Options for `NewReconciler`
+type ReconcilerOptions struct {
+	// Commitment of the signatures and transactions, defaults to confirmed
+	Commitment rpc.Commitment
+
+	// Delay between two passes of `Run`, defaults to 5s
+	Interval time.Duration
+
+	// Pending orders searched in parallel, see `FindReferencesOptions`
+	Concurrency int
+
+	// Global limit of `getSignaturesForAddress` requests, unlimited when 0
+	RequestsPerSecond float64
+
+	// How long expired orders are still searched for late payments, defaults to 24h
+	LatePayments time.Duration
+
+	// Called for transactions referencing an order without fulfilling its transfer
+	OnInvalidTransfer func(order Order, signature string, err error)
+
+	// Publishes `payment.confirmed`, `payment.failed` and `reference.expired` events when set
+	Webhooks *WebhookDispatcher
+}
+
+// Moves the pending orders of a `ReferenceStore` to paid once their transfer is confirmed
+type Reconciler struct {
+	store   ReferenceStore
+	conn    RPCClient
+	options ReconcilerOptions
+
+	mu sync.Mutex
+
+	// Events already published
+	published map[publishedEvent]bool
+
+	// Newest signature of each reference already rejected, the `Until` cursor of the next pass
+	cursors map[Reference]string
+}
+
+/*
+Create a reconciler of the orders of `store`.
+
+@param options - Reconciler options, may be nil.
+*/
+func NewReconciler(store ReferenceStore, conn RPCClient, options *ReconcilerOptions) *Reconciler {
+	r := &Reconciler{store: store, conn: conn, published: map[publishedEvent]bool{}, cursors: map[Reference]string{}}
+	if options != nil {
+		r.options = *options
+	}
+	if r.options.Commitment == "" {
+		r.options.Commitment = rpc.CommitmentConfirmed
+	}
+	if r.options.Interval == 0 {
+		r.options.Interval = 5 * time.Second
+	}
+	if r.options.LatePayments == 0 {
+		r.options.LatePayments = 24 * time.Hour
+	}
+	return r
+}
+
+/*
+Look up the reference of every pending order, and of the orders expired within
+`LatePayments`, and validate its transfers, then expire the overdue orders.
+
+The signatures of a reference are validated newest first until one fulfills
+the transfer, those already rejected aren't searched again.
+
+Errors of single orders don't stop the pass, they are returned joined. The
+payment events of a transaction are published once by a reconciler, a payment
+is queued before the order is marked paid so the event isn't lost.
+
+@return The orders marked paid.
+*/
+func (r *Reconciler) ReconcileOnce(ctx context.Context) ([]Order, error) {
+	now := time.Now()
+	pending, err := r.store.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	expired, err := r.store.Expired(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make(map[Reference]Order, len(pending)+len(expired))
+	references := make([]Reference, 0, len(pending)+len(expired))
+	for _, order := range append(pending, expired...) {
+		if order.Status == ORDER_STATUS_EXPIRED && order.ExpiresAt.Add(r.options.LatePayments).Before(now) {
+			continue
+		}
+		orders[order.Reference] = order
+		references = append(references, order.Reference)
+	}
+	watched := make(map[string]bool, len(orders))
+	for _, order := range orders {
+		watched[order.ID] = true
+	}
+	r.mu.Lock()
+	for reference := range r.cursors {
+		if _, ok := orders[reference]; !ok {
+			delete(r.cursors, reference)
+		}
+	}
+	for event := range r.published {
+		if !watched[event.orderID] {
+			delete(r.published, event)
+		}
+	}
+	r.mu.Unlock()
+
+	var mu sync.Mutex
+	var paid []Order
+	var errs []error
+	limiter := newRateLimiter(r.options.RequestsPerSecond)
+	forEachReference(references, r.options.Concurrency, func(reference Reference) {
+		if ctx.Err() != nil {
+			return
+		}
+		order, err := r.reconcile(ctx, orders[reference], limiter.wait)
+		mu.Lock()
+		defer mu.Unlock()
+		if order != nil {
+			paid = append(paid, *order)
+		}
+		if err != nil && ctx.Err() == nil {
+			errs = append(errs, err)
+		}
+	})
+	sortOrders(paid)
+	if err := ctx.Err(); err != nil {
+		return paid, errors.Join(append(errs, err)...)
+	}
+
+	// Orders are searched once more before they expire
+	overdue, err := r.store.Expire(ctx, now)
+	if err != nil {
+		return paid, errors.Join(append(errs, err)...)
+	}
+	for _, order := range overdue {
+		errs = append(errs, r.publish(ctx, WEBHOOK_REFERENCE_EXPIRED, order, "", ""))
+	}
+	return paid, errors.Join(errs...)
+}
+
+/*
+Validate the signatures of the reference of `order`, newest first, and mark it
+paid by the first one fulfilling its transfer.
+
+@return The order marked paid, nil when no signature fulfills the transfer.
+*/
+func (r *Reconciler) reconcile(ctx context.Context, order Order, wait func(ctx context.Context) error) (*Order, error) {
+	r.mu.Lock()
+	cursor := r.cursors[order.Reference]
+	r.mu.Unlock()
+
+	var newest string
+	var payment string
+	var errs []error
+	config := &client.GetSignaturesForAddressConfig{Until: cursor, Commitment: r.options.Commitment}
+	err := pageSignatures(ctx, r.conn, order.Reference, config, wait, func(page []rpc.SignatureWithStatus) bool {
+		if newest == "" {
+			newest = page[0].Signature
+		}
+		for _, signature := range page {
+			if signature.Err != nil {
+				errs = append(errs, r.publishOnce(ctx, WEBHOOK_PAYMENT_FAILED, order, signature.Signature, fmt.Sprintf("transaction failed: %v", signature.Err)))
+				continue
+			}
+			_, err := ValidateTransferWithContext(ctx, r.conn, signature.Signature, &order.Transfer, &client.GetTransactionConfig{Commitment: r.options.Commitment})
+			if err == nil {
+				payment = signature.Signature
+				return false
+			}
+			var invalid *ValidateTransferError
+			if !errors.As(err, &invalid) {
+				// The remaining signatures are searched again by the next pass
+				newest = ""
+				errs = append(errs, fmt.Errorf("order %s: %w", order.ID, err))
+				return false
+			}
+			if r.options.OnInvalidTransfer != nil {
+				r.options.OnInvalidTransfer(order, signature.Signature, err)
+			}
+			errs = append(errs, r.publishOnce(ctx, WEBHOOK_PAYMENT_FAILED, order, signature.Signature, invalid.Message))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Join(append(errs, fmt.Errorf("order %s: %w", order.ID, err))...)
+	}
+	if payment == "" {
+		// Signatures whose failure wasn't published are searched again
+		if newest != "" && errors.Join(errs...) == nil {
+			r.mu.Lock()
+			r.cursors[order.Reference] = newest
+			r.mu.Unlock()
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	// The order is searched again by the next pass until both succeed
+	confirmed := order
+	if err := markOrderPaid(&confirmed, payment); err != nil {
+		return nil, errors.Join(append(errs, err)...)
+	}
+	if err := r.publishOnce(ctx, WEBHOOK_PAYMENT_CONFIRMED, confirmed, payment, ""); err != nil {
+		return nil, errors.Join(append(errs, err)...)
+	}
+	updated, err := r.store.MarkPaid(ctx, order.ID, payment)
+	if err != nil {
+		return nil, errors.Join(append(errs, err)...)
+	}
+	return updated, errors.Join(errs...)
+}
+
+func (r *Reconciler) publish(ctx context.Context, eventType WebhookEventType, order Order, signature string, reason string) error {
+	if r.options.Webhooks == nil {
+		return nil
+	}
+	if _, err := r.options.Webhooks.Publish(ctx, eventType, order, signature, reason); err != nil {
+		return fmt.Errorf("order %s: %w", order.ID, err)
+	}
+	return nil
+}
+
+// Key of an event published by a reconciler
+type publishedEvent struct {
+	eventType WebhookEventType
+	orderID   string
+	signature string
+}
+
+// Publish an event about a transaction unless it was already published
+func (r *Reconciler) publishOnce(ctx context.Context, eventType WebhookEventType, order Order, signature string, reason string) error {
+	key := publishedEvent{eventType, order.ID, signature}
+	r.mu.Lock()
+	published := r.published[key]
+	r.published[key] = true
+	r.mu.Unlock()
+	if published {
+		return nil
+	}
+	if err := r.publish(ctx, eventType, order, signature, reason); err != nil {
+		r.mu.Lock()
+		delete(r.published, key)
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+/*
+Reconcile the orders every `Interval` until `ctx` is done.
+
+@param onPaid - Called with the orders marked paid by each pass, may be nil.
+
+@param onError - Called with the errors of each pass, may be nil.
+*/
+func (r *Reconciler) Run(ctx context.Context, onPaid func(Order), onError func(error)) error {
+	ticker := time.NewTicker(r.options.Interval)
+	defer ticker.Stop()
+	for {
+		paid, err := r.ReconcileOnce(ctx)
+		if onPaid != nil {
+			for _, order := range paid {
+				onPaid(order)
+			}
+		}
+		if err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
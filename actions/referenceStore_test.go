@@ -0,0 +1,277 @@
+package actions_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"solana-actions/actions"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+func TestReferenceStore(t *testing.T) {
+	ctx := context.Background()
+	merchant := types.NewAccount().PublicKey
+	amount, _ := actions.ParseAmount("1.5")
+	transfer := actions.TransferRequestURLFields{Recipient: merchant, Amount: &amount}
+
+	stores := map[string]func(t *testing.T) actions.ReferenceStore{
+		"memory": func(t *testing.T) actions.ReferenceStore {
+			return actions.NewMemoryReferenceStore()
+		},
+		"bolt": func(t *testing.T) actions.ReferenceStore {
+			store, err := actions.OpenBoltReferenceStore(filepath.Join(t.TempDir(), "orders.db"))
+			if err != nil {
+				t.Fatalf("err should be nil: %s", err.Error())
+			}
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Run("issues and looks up orders", func(t *testing.T) {
+				store := newStore(t)
+				order, err := store.Issue(ctx, "order-1", transfer, time.Hour)
+				if err != nil {
+					t.Fatalf("err should be nil: %s", err.Error())
+				}
+				if order.Status != actions.ORDER_STATUS_PENDING || len(order.Transfer.Reference) != 1 || order.Transfer.Reference[0] != order.Reference {
+					t.Errorf("unexpected order %+v", order)
+				}
+				if _, err := store.Issue(ctx, "order-1", transfer, time.Hour); err == nil {
+					t.Error("expected an error for a duplicate order")
+				}
+
+				byReference, err := store.LookupReference(ctx, order.Reference)
+				if err != nil {
+					t.Fatalf("err should be nil: %s", err.Error())
+				}
+				if byReference.ID != "order-1" || byReference.Transfer.Amount.String() != "1.5" || byReference.Transfer.Recipient != merchant {
+					t.Errorf("unexpected order %+v", byReference)
+				}
+				if _, err := store.Lookup(ctx, "order-2"); err == nil {
+					t.Error("expected an error for an unknown order")
+				}
+			})
+
+			t.Run("marks orders paid once", func(t *testing.T) {
+				store := newStore(t)
+				store.Issue(ctx, "order-1", transfer, time.Hour)
+				order, err := store.MarkPaid(ctx, "order-1", "sig")
+				if err != nil {
+					t.Fatalf("err should be nil: %s", err.Error())
+				}
+				if order.Status != actions.ORDER_STATUS_PAID || order.Signature != "sig" || order.PaidAt == nil {
+					t.Errorf("unexpected order %+v", order)
+				}
+				if _, err := store.MarkPaid(ctx, "order-1", "sig"); err != nil {
+					t.Errorf("marking paid again should be idempotent: %s", err.Error())
+				}
+				if _, err := store.MarkPaid(ctx, "order-1", "other"); err == nil {
+					t.Error("expected an error for a second payment")
+				}
+				if pending, _ := store.Pending(ctx); len(pending) != 0 {
+					t.Errorf("unexpected pending orders %+v", pending)
+				}
+			})
+
+			t.Run("expires overdue orders", func(t *testing.T) {
+				store := newStore(t)
+				store.Issue(ctx, "short", transfer, time.Minute)
+				store.Issue(ctx, "long", transfer, time.Hour)
+				expired, err := store.Expire(ctx, time.Now().Add(10*time.Minute))
+				if err != nil {
+					t.Fatalf("err should be nil: %s", err.Error())
+				}
+				if len(expired) != 1 || expired[0].ID != "short" {
+					t.Errorf("unexpected expired orders %+v", expired)
+				}
+				order, _ := store.Lookup(ctx, "short")
+				pending, _ := store.Pending(ctx)
+				if order.Status != actions.ORDER_STATUS_EXPIRED || len(pending) != 1 || pending[0].ID != "long" {
+					t.Errorf("unexpected orders %+v %+v", order, pending)
+				}
+				if listed, _ := store.Expired(ctx); len(listed) != 1 || listed[0].ID != "short" {
+					t.Errorf("unexpected expired orders %+v", listed)
+				}
+				if order, err := store.MarkPaid(ctx, "short", "late"); err != nil || order.Status != actions.ORDER_STATUS_PAID {
+					t.Errorf("late payments should mark the order paid: %v", err)
+				}
+			})
+
+			t.Run("is safe for concurrent use", func(t *testing.T) {
+				store := newStore(t)
+				var wg sync.WaitGroup
+				for i := 0; i < 20; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						id := fmt.Sprintf("order-%d", i)
+						order, err := store.Issue(ctx, id, transfer, time.Hour)
+						if err != nil {
+							t.Errorf("err should be nil: %s", err.Error())
+							return
+						}
+						store.LookupReference(ctx, order.Reference)
+						store.Pending(ctx)
+						if i%2 == 0 {
+							store.MarkPaid(ctx, id, "sig-"+id)
+						}
+					}(i)
+				}
+				wg.Wait()
+				if pending, _ := store.Pending(ctx); len(pending) != 10 {
+					t.Errorf("got %d pending orders want 10", len(pending))
+				}
+			})
+		})
+	}
+
+	t.Run("bolt store persists orders", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "orders.db")
+		store, _ := actions.OpenBoltReferenceStore(path)
+		order, _ := store.Issue(ctx, "order-1", transfer, time.Hour)
+		store.Close()
+
+		store, err := actions.OpenBoltReferenceStore(path)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		defer store.Close()
+		reopened, err := store.LookupReference(ctx, order.Reference)
+		if err != nil || reopened.ID != "order-1" || !reopened.ExpiresAt.Equal(order.ExpiresAt) {
+			t.Errorf("unexpected order %+v %v", reopened, err)
+		}
+	})
+}
+
+func TestReconciler(t *testing.T) {
+	ctx := context.Background()
+	user := types.NewAccount().PublicKey
+	merchant := types.NewAccount().PublicKey
+	store := actions.NewMemoryReferenceStore()
+	amount, _ := actions.ParseAmount("1")
+	transfer := actions.TransferRequestURLFields{Recipient: merchant, Amount: &amount}
+	paidOrder, _ := store.Issue(ctx, "paid", transfer, time.Hour)
+	underpaidOrder, _ := store.Issue(ctx, "underpaid", transfer, time.Hour)
+	store.Issue(ctx, "unpaid", transfer, time.Hour)
+
+	payment := func(reference actions.Reference) *types.Transaction {
+		instructions, _ := actions.AddReferences([]types.Instruction{system.Transfer(system.TransferParam{From: user, To: merchant, Amount: 1})}, reference)
+		return newTestTransaction(user, instructions...)
+	}
+	fake := newFakeRPC(t, map[string]rpcHandler{
+		"getSignaturesForAddress": signaturesHandler(map[string][]rpc.SignatureWithStatus{
+			paidOrder.Reference.String():      {{Signature: "paid-sig", Slot: 2}},
+			underpaidOrder.Reference.String(): {{Signature: "underpaid-sig", Slot: 3}},
+		}),
+		"getTransaction": transactionHandler(t, map[string]confirmedTransaction{
+			"paid-sig":      {tx: payment(paidOrder.Reference), preBalances: []int64{3e9, 0, 0, 1}, postBalances: []int64{2e9, 1e9, 0, 1}},
+			"underpaid-sig": {tx: payment(underpaidOrder.Reference), preBalances: []int64{3e9, 0, 0, 1}, postBalances: []int64{2.9e9, 1e8, 0, 1}},
+		}),
+	})
+
+	var invalid []string
+	reconciler := actions.NewReconciler(store, fake.client(), &actions.ReconcilerOptions{
+		OnInvalidTransfer: func(order actions.Order, signature string, err error) {
+			invalid = append(invalid, order.ID+" "+signature)
+		},
+	})
+	paid, err := reconciler.ReconcileOnce(ctx)
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+	if len(paid) != 1 || paid[0].ID != "paid" || paid[0].Signature != "paid-sig" {
+		t.Errorf("unexpected paid orders %+v", paid)
+	}
+	if len(invalid) != 1 || invalid[0] != "underpaid underpaid-sig" {
+		t.Errorf("unexpected invalid transfers %v", invalid)
+	}
+	pending, _ := store.Pending(ctx)
+	if len(pending) != 2 {
+		t.Errorf("unexpected pending orders %+v", pending)
+	}
+
+	t.Run("pays orders retried after a failed attempt", func(t *testing.T) {
+		store := actions.NewMemoryReferenceStore()
+		order, _ := store.Issue(ctx, "retried", transfer, time.Hour)
+		signatures := map[string][]rpc.SignatureWithStatus{order.Reference.String(): {
+			{Signature: "underpaid-sig", Slot: 5},
+			{Signature: "failed-sig", Slot: 4, Err: map[string]any{"InstructionError": []any{0, "Custom"}}},
+		}}
+		fake := newFakeRPC(t, map[string]rpcHandler{
+			"getSignaturesForAddress": signaturesHandler(signatures),
+			"getTransaction": transactionHandler(t, map[string]confirmedTransaction{
+				"underpaid-sig": {tx: payment(order.Reference), preBalances: []int64{3e9, 0, 0, 1}, postBalances: []int64{2.9e9, 1e8, 0, 1}},
+				"retry-sig":     {tx: payment(order.Reference), preBalances: []int64{3e9, 0, 0, 1}, postBalances: []int64{2e9, 1e9, 0, 1}},
+			}),
+		})
+		reconciler := actions.NewReconciler(store, fake.client(), nil)
+		for i := 0; i < 2; i++ {
+			if paid, err := reconciler.ReconcileOnce(ctx); err != nil || len(paid) != 0 {
+				t.Fatalf("got %+v, %v want no paid orders", paid, err)
+			}
+		}
+		if fake.count("getTransaction") != 1 {
+			t.Errorf("rejected signatures should be validated once, got %d calls", fake.count("getTransaction"))
+		}
+
+		signatures[order.Reference.String()] = append([]rpc.SignatureWithStatus{{Signature: "retry-sig", Slot: 6}}, signatures[order.Reference.String()]...)
+		paid, err := reconciler.ReconcileOnce(ctx)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(paid) != 1 || paid[0].Signature != "retry-sig" || fake.count("getTransaction") != 2 {
+			t.Errorf("unexpected paid orders %+v", paid)
+		}
+	})
+
+	t.Run("pays orders paid late", func(t *testing.T) {
+		store := actions.NewMemoryReferenceStore()
+		overdue, _ := store.Issue(ctx, "overdue", transfer, time.Nanosecond)
+		late, _ := store.Issue(ctx, "late", transfer, time.Nanosecond)
+		signatures := map[string][]rpc.SignatureWithStatus{overdue.Reference.String(): {{Signature: "overdue-sig", Slot: 2}}}
+		fake := newFakeRPC(t, map[string]rpcHandler{
+			"getSignaturesForAddress": signaturesHandler(signatures),
+			"getTransaction": transactionHandler(t, map[string]confirmedTransaction{
+				"overdue-sig": {tx: payment(overdue.Reference), preBalances: []int64{3e9, 0, 0, 1}, postBalances: []int64{2e9, 1e9, 0, 1}},
+				"late-sig":    {tx: payment(late.Reference), preBalances: []int64{3e9, 0, 0, 1}, postBalances: []int64{2e9, 1e9, 0, 1}},
+			}),
+		})
+		reconciler := actions.NewReconciler(store, fake.client(), nil)
+		paid, err := reconciler.ReconcileOnce(ctx)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(paid) != 1 || paid[0].ID != "overdue" {
+			t.Errorf("overdue orders should be searched before expiring %+v", paid)
+		}
+		if expired, _ := store.Expired(ctx); len(expired) != 1 || expired[0].ID != "late" {
+			t.Fatalf("unexpected expired orders %+v", expired)
+		}
+
+		signatures[late.Reference.String()] = []rpc.SignatureWithStatus{{Signature: "late-sig", Slot: 3}}
+		paid, err = reconciler.ReconcileOnce(ctx)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(paid) != 1 || paid[0].ID != "late" || paid[0].Status != actions.ORDER_STATUS_PAID {
+			t.Errorf("late payments should mark expired orders paid %+v", paid)
+		}
+	})
+
+	t.Run("reports RPC errors", func(t *testing.T) {
+		broken := newFakeRPC(t, map[string]rpcHandler{})
+		_, err := actions.NewReconciler(store, broken.client(), nil).ReconcileOnce(ctx)
+		if err == nil || !strings.Contains(err.Error(), "order underpaid") {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Thrown when references can't be attached to a transaction
+type ReferenceError struct {
+	Message string
+}
+
+func (e *ReferenceError) Error() string {
+	return fmt.Sprintf("ReferenceError: %s", e.Message)
+}
+
+// How `FindSignaturesForReferences` combines the signatures of several references
+type ReferenceMatchMode string
+
+const (
+	// Signatures of transactions carrying every reference
+	REFERENCE_MATCH_ALL ReferenceMatchMode = "all"
+
+	// Signatures of transactions carrying at least one reference
+	REFERENCE_MATCH_ANY ReferenceMatchMode = "any"
+)
+
+/*
+Attach references to the instructions of a transaction, e.g. one for the order
+and one for the merchant.
+
+The references are added as read-only, non-signer keys of the last non-memo
+instruction, after the accounts it already has, as Solana Pay wallets do.
+
+@param instructions - Instructions of the transaction, at least one must not be a memo.
+
+@param references - `reference` in the Solana Pay spec, duplicates are attached once.
+
+@throws {ReferenceError}
+*/
+func AddReferences(instructions []types.Instruction, references ...Reference) ([]types.Instruction, error) {
+	target := lastNonMemoInstruction(instructions)
+	if target < 0 {
+		return nil, &ReferenceError{"no instruction to attach the references to"}
+	}
+	result := make([]types.Instruction, len(instructions))
+	copy(result, instructions)
+	ix := result[target]
+	ix.Accounts = append([]types.AccountMeta{}, ix.Accounts...)
+	for _, reference := range references {
+		attached := false
+		for _, meta := range ix.Accounts {
+			attached = attached || meta.PubKey == common.PublicKey(reference)
+		}
+		if !attached {
+			ix.Accounts = append(ix.Accounts, types.AccountMeta{PubKey: common.PublicKey(reference)})
+		}
+	}
+	result[target] = ix
+	return result, nil
+}
+
+// Index of the last instruction that isn't a memo, -1 if there is none
+func lastNonMemoInstruction(instructions []types.Instruction) int {
+	memoProgramID := common.PublicKeyFromString(MEMO_PROGRAM_ID)
+	for i := len(instructions) - 1; i >= 0; i-- {
+		if instructions[i].ProgramID != memoProgramID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Signatures scanned per reference by `FindSignaturesForReferences` when no maximum is given
+const MAX_REFERENCE_SIGNATURES = 10 * FIND_REFERENCE_LIMIT
+
+// Options for `FindSignaturesForReferences`
+type FindSignaturesForReferencesOptions struct {
+	// Options for `getSignaturesForAddress`, `Before` and `Until` are the cursors of the scan
+	client.GetSignaturesForAddressConfig
+
+	// Signatures scanned per reference at most, defaults to `MAX_REFERENCE_SIGNATURES`
+	MaxSignatures int
+}
+
+/*
+Find the signatures of transactions referencing a set of public keys, newest first.
+
+The signatures of each reference are paged with `Before` from `options.Before`
+back to `options.Until`, or to the first signature when it's empty, and the
+scan of a reference stops after `options.MaxSignatures`. Older signatures are
+found by scanning again with `Before` set to the oldest signature returned.
+
+@param ctx - Context of the requests.
+
+@param connection - A connection to the cluster.
+
+@param references - `reference` in the Solana Pay spec.
+
+@param mode - Whether transactions must carry all the references or any of them.
+
+@param options - Search options, may be nil.
+
+@throws {FindReferenceError}
+*/
+func FindSignaturesForReferences(ctx context.Context, connection RPCClient, references []Reference, mode ReferenceMatchMode, options *FindSignaturesForReferencesOptions) ([]rpc.SignatureWithStatus, error) {
+	if options == nil {
+		options = &FindSignaturesForReferencesOptions{}
+	}
+	maxSignatures := options.MaxSignatures
+	if maxSignatures <= 0 {
+		maxSignatures = MAX_REFERENCE_SIGNATURES
+	}
+	if len(references) == 0 {
+		return nil, &FindReferenceError{"no references"}
+	}
+	if mode != REFERENCE_MATCH_ALL && mode != REFERENCE_MATCH_ANY {
+		return nil, &FindReferenceError{fmt.Sprintf("invalid match mode %q", mode)}
+	}
+	unique := map[Reference]bool{}
+	counts := map[string]int{}
+	var signatures []rpc.SignatureWithStatus
+	for _, reference := range references {
+		if unique[reference] {
+			continue
+		}
+		unique[reference] = true
+		scanned := 0
+		err := pageSignatures(ctx, connection, reference, &options.GetSignaturesForAddressConfig, nil, func(page []rpc.SignatureWithStatus) bool {
+			for _, signature := range page {
+				if scanned == maxSignatures {
+					return false
+				}
+				scanned++
+				if counts[signature.Signature] == 0 {
+					signatures = append(signatures, signature)
+				}
+				counts[signature.Signature]++
+			}
+			return scanned < maxSignatures
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	matched := signatures[:0]
+	for _, signature := range signatures {
+		if mode == REFERENCE_MATCH_ANY || counts[signature.Signature] == len(unique) {
+			matched = append(matched, signature)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, &FindReferenceError{"not found"}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Slot > matched[j].Slot })
+	return matched, nil
+}
@@ -0,0 +1,158 @@
+package actions_test
+
+import (
+	"context"
+	"encoding/json"
+	"solana-actions/actions"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/memo"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Fake `getSignaturesForAddress` returning the signatures of each address newer than `until`, with slots
+func signaturesHandler(signatures map[string][]rpc.SignatureWithStatus) rpcHandler {
+	return func(params json.RawMessage) (any, *rpc.JsonRpcError) {
+		var args []json.RawMessage
+		var address string
+		var config struct {
+			Until string `json:"until"`
+		}
+		json.Unmarshal(params, &args)
+		json.Unmarshal(args[0], &address)
+		if len(args) > 1 {
+			json.Unmarshal(args[1], &config)
+		}
+		page := []rpc.SignatureWithStatus{}
+		for _, signature := range signatures[address] {
+			if signature.Signature == config.Until {
+				break
+			}
+			page = append(page, signature)
+		}
+		return page, nil
+	}
+}
+
+func TestReferences(t *testing.T) {
+	user := types.NewAccount().PublicKey
+	order := actions.Reference(types.NewAccount().PublicKey)
+	merchant := actions.Reference(types.NewAccount().PublicKey)
+
+	t.Run("attaches references to the last non-memo instruction", func(t *testing.T) {
+		transfer := system.Transfer(system.TransferParam{From: user, To: types.NewAccount().PublicKey, Amount: 1})
+		note := memo.BuildMemo(memo.BuildMemoParam{Memo: []byte("order 1")})
+		instructions, err := actions.AddReferences([]types.Instruction{transfer, note}, order, merchant, order)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		accounts := instructions[0].Accounts
+		if len(accounts) != 4 || accounts[2].PubKey != common.PublicKey(order) || accounts[3].PubKey != common.PublicKey(merchant) {
+			t.Fatalf("unexpected accounts %+v", accounts)
+		}
+		if accounts[3].IsSigner || accounts[3].IsWritable || len(transfer.Accounts) != 2 || len(instructions[1].Accounts) != 0 {
+			t.Errorf("references should be read-only keys added to a copy %+v", instructions)
+		}
+		if _, err := actions.AddReferences([]types.Instruction{note}, order); err == nil {
+			t.Error("expected an error for memo only instructions")
+		}
+	})
+
+	t.Run("finds signatures carrying all or any references", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{"getSignaturesForAddress": signaturesHandler(map[string][]rpc.SignatureWithStatus{
+			order.String():    {{Signature: "both", Slot: 30}, {Signature: "order", Slot: 20}},
+			merchant.String(): {{Signature: "merchant", Slot: 40}, {Signature: "both", Slot: 30}, {Signature: "old", Slot: 10}},
+		})})
+
+		all, err := actions.FindSignaturesForReferences(context.Background(), fake.client(), []actions.Reference{order, merchant}, actions.REFERENCE_MATCH_ALL, nil)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(all) != 1 || all[0].Signature != "both" {
+			t.Errorf("unexpected signatures %+v", all)
+		}
+
+		anyOf, err := actions.FindSignaturesForReferences(context.Background(), fake.client(), []actions.Reference{order, merchant, order}, actions.REFERENCE_MATCH_ANY, nil)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		var got []string
+		for _, signature := range anyOf {
+			got = append(got, signature.Signature)
+		}
+		if len(got) != 4 || got[0] != "merchant" || got[1] != "both" || got[2] != "order" || got[3] != "old" {
+			t.Errorf("unexpected signatures %v", got)
+		}
+		if fake.count("getSignaturesForAddress") != 4 {
+			t.Errorf("duplicate references should be queried once, got %d calls", fake.count("getSignaturesForAddress"))
+		}
+	})
+
+	t.Run("pages back to until", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{"getSignaturesForAddress": pagedSignaturesHandler(map[string][]string{
+			order.String():    {"s0", "s1", "s2", "s3", "s4", "s5"},
+			merchant.String(): {"s1", "s4", "s5"},
+		})})
+		options := &actions.FindSignaturesForReferencesOptions{GetSignaturesForAddressConfig: client.GetSignaturesForAddressConfig{Limit: 2}}
+		all, err := actions.FindSignaturesForReferences(context.Background(), fake.client(), []actions.Reference{order, merchant}, actions.REFERENCE_MATCH_ALL, options)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(all) != 3 || all[2].Signature != "s5" {
+			t.Errorf("signatures of later pages should match %+v", all)
+		}
+
+		options.Until = "s4"
+		anyOf, err := actions.FindSignaturesForReferences(context.Background(), fake.client(), []actions.Reference{order, merchant}, actions.REFERENCE_MATCH_ANY, options)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(anyOf) != 4 || anyOf[3].Signature != "s3" {
+			t.Errorf("signatures should stop at until %+v", anyOf)
+		}
+	})
+
+	t.Run("stops after the maximum signatures", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{"getSignaturesForAddress": pagedSignaturesHandler(map[string][]string{
+			order.String():    {"s0", "s1", "s2", "s3", "s4", "s5"},
+			merchant.String(): {"s1", "s4", "s5"},
+		})})
+		options := &actions.FindSignaturesForReferencesOptions{GetSignaturesForAddressConfig: client.GetSignaturesForAddressConfig{Limit: 2}, MaxSignatures: 3}
+		anyOf, err := actions.FindSignaturesForReferences(context.Background(), fake.client(), []actions.Reference{order, merchant}, actions.REFERENCE_MATCH_ANY, options)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		var got []string
+		for _, signature := range anyOf {
+			got = append(got, signature.Signature)
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != "s0,s1,s2,s4,s5" {
+			t.Errorf("got %v want the 3 newest signatures of each reference", got)
+		}
+		if fake.count("getSignaturesForAddress") != 4 {
+			t.Errorf("got %d calls want 4", fake.count("getSignaturesForAddress"))
+		}
+	})
+
+	t.Run("reports missing signatures and invalid arguments", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{"getSignaturesForAddress": signaturesHandler(map[string][]rpc.SignatureWithStatus{
+			order.String(): {{Signature: "order", Slot: 20}},
+		})})
+		for _, mode := range []actions.ReferenceMatchMode{actions.REFERENCE_MATCH_ALL, "some"} {
+			_, err := actions.FindSignaturesForReferences(context.Background(), fake.client(), []actions.Reference{order, merchant}, mode, nil)
+			if _, ok := err.(*actions.FindReferenceError); !ok {
+				t.Errorf("expected a FindReferenceError, got %v", err)
+			}
+		}
+		if _, err := actions.FindSignaturesForReferences(context.Background(), fake.client(), nil, actions.REFERENCE_MATCH_ANY, nil); err == nil {
+			t.Error("expected an error without references")
+		}
+	})
+}
@@ -0,0 +1,306 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Thrown when the registry can't be loaded or refreshed
+type RegistryError struct {
+	Message string
+}
+
+func (e *RegistryError) Error() string {
+	return fmt.Sprintf("RegistryError: %s", e.Message)
+}
+
+// Thrown when an action is blocked by the registry
+type RegistryBlockedError struct {
+	Host string
+}
+
+func (e *RegistryBlockedError) Error() string {
+	return fmt.Sprintf("RegistryBlockedError: %s is registered as malicious", e.Host)
+}
+
+// Registry state of a host
+type RegistryStatus string
+
+const (
+	REGISTRY_STATUS_TRUSTED RegistryStatus = "trusted"
+
+	REGISTRY_STATUS_MALICIOUS RegistryStatus = "malicious"
+
+	// Hosts missing from the registry
+	REGISTRY_STATUS_UNKNOWN RegistryStatus = "unknown"
+)
+
+// Host of an action API or of a website rendering blinks
+type RegistryEntry struct {
+	Host  string         `json:"host"`
+	State RegistryStatus `json:"state"`
+}
+
+// Content of a registry file
+type RegistryData struct {
+	// Hosts serving Action APIs
+	Actions []RegistryEntry `json:"actions"`
+
+	// Hosts of websites linking to actions
+	Websites []RegistryEntry `json:"websites"`
+}
+
+// Provides the registry content, e.g. a local file or a remote registry
+type RegistrySource interface {
+	FetchRegistry(ctx context.Context) (*RegistryData, error)
+}
+
+type fileRegistrySource struct {
+	path string
+}
+
+// Creates a `RegistrySource` reading a JSON registry file on every refresh
+func NewFileRegistrySource(path string) RegistrySource {
+	return &fileRegistrySource{path}
+}
+
+func (s *fileRegistrySource) FetchRegistry(ctx context.Context) (*RegistryData, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, &RegistryError{err.Error()}
+	}
+	defer file.Close()
+	return readRegistryData(file)
+}
+
+/*
+Classifies action and website hosts as trusted, malicious or unknown.
+
+A `Registry` is an `ActionURLChecker`: add it to the fetch options to refuse
+actions served by malicious hosts.
+*/
+type Registry struct {
+	source RegistrySource
+
+	mu       sync.RWMutex
+	actions  map[string]RegistryStatus
+	websites map[string]RegistryStatus
+}
+
+/*
+Create a registry from `source` and load it.
+
+@throws {RegistryError}
+*/
+func NewRegistry(ctx context.Context, source RegistrySource) (*Registry, error) {
+	r := &Registry{source: source}
+	if err := r.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+/*
+Load a registry from a JSON file.
+
+@throws {RegistryError}
+*/
+func LoadRegistryFile(path string) (*Registry, error) {
+	return NewRegistry(context.Background(), NewFileRegistrySource(path))
+}
+
+/*
+Load a registry from JSON, it can't be refreshed.
+
+@throws {RegistryError}
+*/
+func LoadRegistry(r io.Reader) (*Registry, error) {
+	data, err := readRegistryData(r)
+	if err != nil {
+		return nil, err
+	}
+	registry := &Registry{}
+	registry.set(data)
+	return registry, nil
+}
+
+/*
+Reload the registry from its source, the current entries are kept on failure.
+
+@throws {RegistryError}
+*/
+func (r *Registry) Refresh(ctx context.Context) error {
+	if r.source == nil {
+		return &RegistryError{"registry has no source"}
+	}
+	data, err := r.source.FetchRegistry(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validateRegistryData(data); err != nil {
+		return err
+	}
+	r.set(data)
+	return nil
+}
+
+func (r *Registry) set(data *RegistryData) {
+	actions := make(map[string]RegistryStatus, len(data.Actions))
+	for _, entry := range data.Actions {
+		actions[normalizeHost(entry.Host)] = entry.State
+	}
+	websites := make(map[string]RegistryStatus, len(data.Websites))
+	for _, entry := range data.Websites {
+		websites[normalizeHost(entry.Host)] = entry.State
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.actions = actions
+	r.websites = websites
+}
+
+// Registry state of the host serving an Action API
+func (r *Registry) ActionStatus(link *url.URL) RegistryStatus {
+	return r.lookup(false, link)
+}
+
+// Registry state of the host of a website
+func (r *Registry) WebsiteStatus(link *url.URL) RegistryStatus {
+	return r.lookup(true, link)
+}
+
+func (r *Registry) lookup(website bool, link *url.URL) RegistryStatus {
+	if link == nil {
+		return REGISTRY_STATUS_UNKNOWN
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := r.actions
+	if website {
+		entries = r.websites
+	}
+	if status, ok := entries[normalizeHost(link.Host)]; ok {
+		return status
+	}
+	return REGISTRY_STATUS_UNKNOWN
+}
+
+/*
+Classify the fields returned by `ParseURL`.
+
+A blink is malicious when either its website or its action is malicious, and
+trusted when its action is trusted.
+
+@param fields - `*ActionRequestURLFields` or `*BlinkURLFields`.
+
+@throws {RegistryError}
+*/
+func (r *Registry) Classify(fields any) (RegistryStatus, error) {
+	switch f := fields.(type) {
+	case *ActionRequestURLFields:
+		return r.ActionStatus(f.Link), nil
+	case *BlinkURLFields:
+		return combineRegistryStatus(r.WebsiteStatus(f.Blink), r.ActionStatus(f.Action.Link)), nil
+	}
+	return REGISTRY_STATUS_UNKNOWN, &RegistryError{"invalid fields"}
+}
+
+/*
+Classify a website URL mapped to its Action API by the website's actions.json.
+
+Returns the resolved Action API URL, nil when no rule matches.
+*/
+func (r *Registry) ClassifyWebsite(link *url.URL, actionsJson *ActionsJson) (RegistryStatus, *url.URL) {
+	apiURL, ok := actionsJson.ResolveActionURL(link)
+	if !ok {
+		return r.WebsiteStatus(link), nil
+	}
+	return combineRegistryStatus(r.WebsiteStatus(link), r.ActionStatus(apiURL)), apiURL
+}
+
+/*
+Parse a Solana Action or blink URL, refusing malicious entries.
+
+@throws {ParseUrlError}
+
+@throws {RegistryBlockedError}
+*/
+func (r *Registry) ParseURL(link *url.URL) (any, error) {
+	fields, err := ParseURL(link)
+	if err != nil {
+		return nil, err
+	}
+	switch f := fields.(type) {
+	case *ActionRequestURLFields:
+		if err := r.CheckActionURL(f.Link); err != nil {
+			return nil, err
+		}
+	case *BlinkURLFields:
+		if r.WebsiteStatus(f.Blink) == REGISTRY_STATUS_MALICIOUS {
+			return nil, &RegistryBlockedError{f.Blink.Host}
+		}
+		if err := r.CheckActionURL(f.Action.Link); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// Refuses links to Action APIs registered as malicious
+func (r *Registry) CheckActionURL(link *url.URL) error {
+	if r.ActionStatus(link) == REGISTRY_STATUS_MALICIOUS {
+		return &RegistryBlockedError{link.Host}
+	}
+	return nil
+}
+
+func combineRegistryStatus(website RegistryStatus, action RegistryStatus) RegistryStatus {
+	if website == REGISTRY_STATUS_MALICIOUS || action == REGISTRY_STATUS_MALICIOUS {
+		return REGISTRY_STATUS_MALICIOUS
+	}
+	return action
+}
+
+func readRegistryData(r io.Reader) (*RegistryData, error) {
+	var data RegistryData
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, &RegistryError{err.Error()}
+	}
+	if err := validateRegistryData(&data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func validateRegistryData(data *RegistryData) error {
+	for _, entry := range append(append([]RegistryEntry{}, data.Actions...), data.Websites...) {
+		if entry.Host == "" {
+			return &RegistryError{"entry missing host"}
+		}
+		switch entry.State {
+		case REGISTRY_STATUS_TRUSTED, REGISTRY_STATUS_MALICIOUS, REGISTRY_STATUS_UNKNOWN:
+		default:
+			return &RegistryError{fmt.Sprintf("invalid state %q for %s", entry.State, entry.Host)}
+		}
+	}
+	return nil
+}
+
+// Entries and links are matched by hostname, with or without a port
+func normalizeHost(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
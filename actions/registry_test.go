@@ -0,0 +1,172 @@
+package actions_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"solana-actions/actions"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	registry, err := actions.LoadRegistryFile(filepath.Join("testdata", "registry.json"))
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+
+	classify := func(t *testing.T, raw string) actions.RegistryStatus {
+		t.Helper()
+		link, _ := url.Parse(raw)
+		fields, err := actions.ParseURL(link)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		status, err := registry.Classify(fields)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		return status
+	}
+
+	t.Run("classifies action URLs by host", func(t *testing.T) {
+		cases := map[string]actions.RegistryStatus{
+			"solana-action:https://actions.dialect.to/api/donate":     actions.REGISTRY_STATUS_TRUSTED,
+			"solana-action:https://API.drainer.xyz/api/claim":         actions.REGISTRY_STATUS_MALICIOUS,
+			"solana-action:https://actions.example.com/api/donate":    actions.REGISTRY_STATUS_UNKNOWN,
+			"solana-action:https://actions.dialect.to:443/api/donate": actions.REGISTRY_STATUS_TRUSTED,
+		}
+		for raw, want := range cases {
+			if got := classify(t, raw); got != want {
+				t.Errorf("%s: got %s want %s", raw, got, want)
+			}
+		}
+	})
+
+	t.Run("blinks are malicious when their website or action is", func(t *testing.T) {
+		cases := map[string]actions.RegistryStatus{
+			"https://dial.to/?action=solana-action:https://actions.dialect.to/api/donate":       actions.REGISTRY_STATUS_TRUSTED,
+			"https://phish.example/?action=solana-action:https://actions.dialect.to/api/donate": actions.REGISTRY_STATUS_MALICIOUS,
+			"https://dial.to/?action=solana-action:https://api.drainer.xyz/api/claim":           actions.REGISTRY_STATUS_MALICIOUS,
+		}
+		for raw, want := range cases {
+			if got := classify(t, raw); got != want {
+				t.Errorf("%s: got %s want %s", raw, got, want)
+			}
+		}
+	})
+
+	t.Run("classifies websites through actions.json rules", func(t *testing.T) {
+		actionsJson := &actions.ActionsJson{Rules: []actions.ActionRuleObject{
+			{PathPattern: "/claim/*", ApiPath: "https://api.drainer.xyz/api/claim/*"},
+			{PathPattern: "/donate/**", ApiPath: "https://actions.dialect.to/api/donate/**"},
+		}}
+		link, _ := url.Parse("https://dial.to/donate/alice/sol?amount=1")
+		status, apiURL := registry.ClassifyWebsite(link, actionsJson)
+		if status != actions.REGISTRY_STATUS_TRUSTED || apiURL.String() != "https://actions.dialect.to/api/donate/alice/sol?amount=1" {
+			t.Errorf("got %s %v want %s %s", status, apiURL, actions.REGISTRY_STATUS_TRUSTED, "https://actions.dialect.to/api/donate/alice/sol?amount=1")
+		}
+
+		link, _ = url.Parse("https://dial.to/claim/nft")
+		if status, _ := registry.ClassifyWebsite(link, actionsJson); status != actions.REGISTRY_STATUS_MALICIOUS {
+			t.Errorf("got %s want %s", status, actions.REGISTRY_STATUS_MALICIOUS)
+		}
+	})
+
+	t.Run("refuses to parse malicious entries", func(t *testing.T) {
+		link, _ := url.Parse("https://phish.example/?action=solana-action:https://actions.dialect.to/api/donate")
+		var blocked *actions.RegistryBlockedError
+		if _, err := registry.ParseURL(link); !errors.As(err, &blocked) || blocked.Host != "phish.example" {
+			t.Errorf("expected a RegistryBlockedError for phish.example, got %v", err)
+		}
+	})
+
+	t.Run("blocks malicious actions in the fetch pipeline", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("expected the action not to be requested")
+		}))
+		defer server.Close()
+
+		link, _ := url.Parse(server.URL + "/api/claim")
+		var blocked *actions.RegistryBlockedError
+		_, err := actions.FetchActionWithOptions(context.Background(), link, &actions.FetchActionOptions{URLCheckers: []actions.ActionURLChecker{registry}})
+		if !errors.As(err, &blocked) {
+			t.Errorf("expected a RegistryBlockedError, got %v", err)
+		}
+		fake := newFakeRPC(t, map[string]rpcHandler{})
+		_, err = actions.FetchTransactionWithOptions(context.Background(), fake.client(), link, actions.ActionPostRequest{}, &actions.FetchTransactionOptions{URLCheckers: []actions.ActionURLChecker{registry}})
+		if !errors.As(err, &blocked) {
+			t.Errorf("expected a RegistryBlockedError, got %v", err)
+		}
+	})
+
+	t.Run("checks every redirect in the fetch pipeline", func(t *testing.T) {
+		blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("expected the redirect not to be followed")
+		}))
+		defer blocked.Close()
+		allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, blocked.URL+r.URL.Path, http.StatusTemporaryRedirect)
+		}))
+		defer allowed.Close()
+
+		// Registered with its port, the allowed server is reached through localhost
+		blockedHost := strings.TrimPrefix(blocked.URL, "http://")
+		redirects, err := actions.LoadRegistry(strings.NewReader(`{"actions":[{"host":"` + blockedHost + `","state":"malicious"}],"websites":[]}`))
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		link, _ := url.Parse(strings.Replace(allowed.URL, "127.0.0.1", "localhost", 1) + "/api/claim")
+		checkers := []actions.ActionURLChecker{redirects}
+
+		var blockedErr *actions.RegistryBlockedError
+		_, err = actions.FetchActionWithOptions(context.Background(), link, &actions.FetchActionOptions{URLCheckers: checkers})
+		if !errors.As(err, &blockedErr) || blockedErr.Host != blockedHost {
+			t.Errorf("expected a RegistryBlockedError for %s, got %v", blockedHost, err)
+		}
+		fake := newFakeRPC(t, map[string]rpcHandler{})
+		_, err = actions.FetchTransactionWithOptions(context.Background(), fake.client(), link, actions.ActionPostRequest{}, &actions.FetchTransactionOptions{URLCheckers: checkers})
+		if !errors.As(err, &blockedErr) {
+			t.Errorf("expected a RegistryBlockedError, got %v", err)
+		}
+	})
+
+	t.Run("refreshes from its source", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "registry.json")
+		os.WriteFile(path, []byte(`{"actions": [{"host": "actions.example.com", "state": "trusted"}]}`), 0o600)
+		refreshable, err := actions.NewRegistry(context.Background(), actions.NewFileRegistrySource(path))
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		link, _ := url.Parse("https://actions.example.com/api")
+		if refreshable.ActionStatus(link) != actions.REGISTRY_STATUS_TRUSTED {
+			t.Fatalf("got %s want %s", refreshable.ActionStatus(link), actions.REGISTRY_STATUS_TRUSTED)
+		}
+
+		os.WriteFile(path, []byte(`{"actions": [{"host": "actions.example.com", "state": "malicious"}]}`), 0o600)
+		if err := refreshable.Refresh(context.Background()); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if refreshable.ActionStatus(link) != actions.REGISTRY_STATUS_MALICIOUS {
+			t.Errorf("got %s want %s", refreshable.ActionStatus(link), actions.REGISTRY_STATUS_MALICIOUS)
+		}
+
+		os.WriteFile(path, []byte(`{"actions": [{"host": "actions.example.com", "state": "evil"}]}`), 0o600)
+		if err := refreshable.Refresh(context.Background()); err == nil {
+			t.Error("expected an error for an invalid state")
+		}
+		if refreshable.ActionStatus(link) != actions.REGISTRY_STATUS_MALICIOUS {
+			t.Error("expected the entries to be kept on failure")
+		}
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		if _, err := actions.LoadRegistry(strings.NewReader(`{"hosts": []}`)); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
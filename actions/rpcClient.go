@@ -0,0 +1,51 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+/*
+The RPC methods used by the package, implemented by `*client.Client` and by
+`*RPCPool` to spread the calls across several endpoints.
+*/
+type RPCClient interface {
+	GetLatestBlockhashWithConfig(ctx context.Context, cfg client.GetLatestBlockhashConfig) (rpc.GetLatestBlockhashValue, error)
+	IsBlockhashValid(ctx context.Context, blockhash string) (bool, error)
+	IsBlockhashValidWithConfig(ctx context.Context, blockhash string, cfg client.IsBlockhashValidConfig) (bool, error)
+	GetSignaturesForAddressWithConfig(ctx context.Context, addr string, cfg client.GetSignaturesForAddressConfig) (rpc.GetSignaturesForAddress, error)
+	GetSignatureStatus(ctx context.Context, signature string) (*rpc.SignatureStatus, error)
+	GetSignatureStatusesWithConfig(ctx context.Context, signatures []string, cfg client.GetSignatureStatusesConfig) (rpc.SignatureStatuses, error)
+	GetTransactionWithConfig(ctx context.Context, txhash string, cfg client.GetTransactionConfig) (*client.Transaction, error)
+	SendTransactionWithConfig(ctx context.Context, tx types.Transaction, cfg client.SendTransactionConfig) (string, error)
+	GetNonceAccount(ctx context.Context, base58Addr string) (system.NonceAccount, error)
+	GetNonceFromNonceAccount(ctx context.Context, base58Addr string) (string, error)
+	GetGenesisHash(ctx context.Context) (string, error)
+}
+
+// Implemented by the `RPCClient`s able to fetch the block height, `*client.Client` goes through its `RpcClient`
+type blockHeightClient interface {
+	GetBlockHeightWithConfig(ctx context.Context, cfg rpc.GetBlockHeightConfig) (uint64, error)
+}
+
+func getBlockHeight(ctx context.Context, conn RPCClient, commitment rpc.Commitment) (uint64, error) {
+	switch c := conn.(type) {
+	case *client.Client:
+		res, err := c.RpcClient.GetBlockHeightWithConfig(ctx, rpc.GetBlockHeightConfig{Commitment: commitment})
+		if err != nil {
+			return 0, err
+		}
+		if res.Error != nil {
+			return 0, res.Error
+		}
+		return res.Result, nil
+	case blockHeightClient:
+		return c.GetBlockHeightWithConfig(ctx, rpc.GetBlockHeightConfig{Commitment: commitment})
+	}
+	return 0, fmt.Errorf("%T can't fetch the block height", conn)
+}
@@ -0,0 +1,448 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Thrown when an `RPCPool` is misconfigured or every endpoint failed a call
+type RPCPoolError struct {
+	Message string
+}
+
+func (e *RPCPoolError) Error() string {
+	return fmt.Sprintf("RPCPoolError: %s", e.Message)
+}
+
+// Options for `NewRPCPool`
+type RPCPoolOptions struct {
+	// Timeout of a call to an endpoint before failing over, defaults to 10s
+	Timeout time.Duration
+
+	// Endpoints tried per call, defaults to all of them
+	MaxAttempts int
+
+	// Delay before retrying a failing endpoint, doubled after each consecutive failure, defaults to 1s
+	InitialBackoff time.Duration
+
+	// Defaults to 1m
+	MaxBackoff time.Duration
+
+	// Delay between two health checks of `Run`, defaults to 30s
+	HealthCheckInterval time.Duration
+
+	// Client of the requests, `http.DefaultClient` when nil
+	HTTPClient *http.Client
+}
+
+// Health of an endpoint of an `RPCPool`
+type RPCEndpointHealth struct {
+	Endpoint string `json:"endpoint"`
+
+	// False while the endpoint backs off after a failure
+	Healthy bool `json:"healthy"`
+
+	// Consecutive failures
+	Failures     int        `json:"failures"`
+	BackoffUntil *time.Time `json:"backoffUntil,omitempty"`
+	LastError    string     `json:"lastError,omitempty"`
+}
+
+type rpcEndpoint struct {
+	url    string
+	client *client.Client
+
+	mu           sync.Mutex
+	failures     int
+	backoffUntil time.Time
+	lastError    string
+}
+
+// The endpoint a pinned `RPCPool` sends its calls to
+type rpcPin struct {
+	mu       sync.Mutex
+	endpoint *rpcEndpoint
+}
+
+/*
+`RPCClient` spreading the calls across several endpoints.
+
+Calls go round-robin to the healthy endpoints. HTTP 429 and 5xx responses,
+timeouts and network errors back the endpoint off and fail the call over to
+the next one, JSON-RPC errors are returned as is.
+*/
+type RPCPool struct {
+	endpoints []*rpcEndpoint
+	options   RPCPoolOptions
+	next      *atomic.Uint64
+	pin       *rpcPin
+}
+
+/*
+Create a pool of RPC endpoints.
+
+@param endpoints - URLs of the endpoints, at least one.
+
+@param options - Pool options, may be nil.
+
+@throws {RPCPoolError}
+*/
+func NewRPCPool(endpoints []string, options *RPCPoolOptions) (*RPCPool, error) {
+	if len(endpoints) == 0 {
+		return nil, &RPCPoolError{"no endpoints"}
+	}
+	p := &RPCPool{next: &atomic.Uint64{}}
+	if options != nil {
+		p.options = *options
+	}
+	if p.options.Timeout == 0 {
+		p.options.Timeout = 10 * time.Second
+	}
+	if p.options.MaxAttempts <= 0 || p.options.MaxAttempts > len(endpoints) {
+		p.options.MaxAttempts = len(endpoints)
+	}
+	if p.options.InitialBackoff == 0 {
+		p.options.InitialBackoff = time.Second
+	}
+	if p.options.MaxBackoff == 0 {
+		p.options.MaxBackoff = time.Minute
+	}
+	if p.options.HealthCheckInterval == 0 {
+		p.options.HealthCheckInterval = 30 * time.Second
+	}
+	base := http.DefaultClient
+	if p.options.HTTPClient != nil {
+		base = p.options.HTTPClient
+	}
+	httpClient := *base
+	httpClient.Transport = &rpcOutcomeTransport{base.Transport}
+
+	for _, endpoint := range endpoints {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, &RPCPoolError{fmt.Sprintf("invalid endpoint %q", endpoint)}
+		}
+		p.endpoints = append(p.endpoints, &rpcEndpoint{
+			url:    endpoint,
+			client: client.New(rpc.WithEndpoint(endpoint), rpc.WithHTTPClient(&httpClient)),
+		})
+	}
+	return p, nil
+}
+
+/*
+A view of the pool sending every call to the same endpoint, for calls that
+must agree with each other like fetching a blockhash then sending and
+confirming a transaction using it.
+
+The view fails over, and stays on the new endpoint, only when its endpoint fails.
+*/
+func (p *RPCPool) Pin() *RPCPool {
+	if p.pin != nil {
+		return p
+	}
+	pinned := *p
+	pinned.pin = &rpcPin{}
+	return &pinned
+}
+
+/*
+Pin the calls of a pool, see `RPCPool.Pin`. Other clients are returned as is.
+
+Pass the pinned client to `FetchTransactionWithOptions` and then to
+`SendAndConfirm`, so the blockhash is fetched from the endpoint the
+transaction is sent to and confirmed on.
+*/
+func PinRPC(conn RPCClient) RPCClient {
+	if pool, ok := conn.(*RPCPool); ok {
+		return pool.Pin()
+	}
+	return conn
+}
+
+// Health of the endpoints, in the order given to `NewRPCPool`
+func (p *RPCPool) Health() []RPCEndpointHealth {
+	now := time.Now()
+	health := make([]RPCEndpointHealth, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		e.mu.Lock()
+		endpoint := RPCEndpointHealth{Endpoint: e.url, Healthy: true, Failures: e.failures, LastError: e.lastError}
+		if e.backoffUntil.After(now) {
+			backoffUntil := e.backoffUntil
+			endpoint.Healthy, endpoint.BackoffUntil = false, &backoffUntil
+		}
+		e.mu.Unlock()
+		health = append(health, endpoint)
+	}
+	return health
+}
+
+/*
+Call `getHealth` on every endpoint, backing off the unhealthy ones and
+restoring the others.
+
+@return The errors of the unhealthy endpoints, joined.
+*/
+func (p *RPCPool) CheckHealth(ctx context.Context) error {
+	errs := make([]error, len(p.endpoints))
+	var wg sync.WaitGroup
+	for i, e := range p.endpoints {
+		wg.Add(1)
+		go func(i int, e *rpcEndpoint) {
+			defer wg.Done()
+			callCtx, cancel := context.WithTimeout(ctx, p.options.Timeout)
+			defer cancel()
+			outcome := &rpcOutcome{}
+			_, err := e.client.GetHealth(context.WithValue(callCtx, rpcOutcomeKey{}, outcome))
+			if err != nil {
+				p.failed(e, outcome, err)
+				errs[i] = fmt.Errorf("%s: %w", e.url, err)
+				return
+			}
+			p.succeeded(e)
+		}(i, e)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+/*
+Check the health of the endpoints every `HealthCheckInterval` until `ctx` is done.
+
+@param onError - Called with the errors of each check, may be nil.
+*/
+func (p *RPCPool) Run(ctx context.Context, onError func(error)) error {
+	ticker := time.NewTicker(p.options.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := p.CheckHealth(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Endpoints in the order of the attempts: the pinned one, the healthy ones round-robin, then the soonest back
+func (p *RPCPool) candidates() []*rpcEndpoint {
+	now := time.Now()
+	start := int(p.next.Add(1) - 1)
+	var pinned *rpcEndpoint
+	if p.pin != nil {
+		p.pin.mu.Lock()
+		pinned = p.pin.endpoint
+		p.pin.mu.Unlock()
+	}
+
+	var healthy, backingOff []*rpcEndpoint
+	for i := range p.endpoints {
+		e := p.endpoints[(start+i)%len(p.endpoints)]
+		if e == pinned {
+			continue
+		}
+		e.mu.Lock()
+		available := !e.backoffUntil.After(now)
+		e.mu.Unlock()
+		if available {
+			healthy = append(healthy, e)
+		} else {
+			backingOff = append(backingOff, e)
+		}
+	}
+	sort.SliceStable(backingOff, func(i, j int) bool {
+		return backingOff[i].backoffDeadline().Before(backingOff[j].backoffDeadline())
+	})
+	candidates := append(healthy, backingOff...)
+	if pinned != nil {
+		candidates = append([]*rpcEndpoint{pinned}, candidates...)
+	}
+	return candidates[:p.options.MaxAttempts]
+}
+
+func (e *rpcEndpoint) backoffDeadline() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.backoffUntil
+}
+
+func (p *RPCPool) succeeded(e *rpcEndpoint) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures, e.backoffUntil, e.lastError = 0, time.Time{}, ""
+}
+
+func (p *RPCPool) failed(e *rpcEndpoint, outcome *rpcOutcome, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	delay := p.options.InitialBackoff
+	for i := 1; i < e.failures && delay < p.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if outcome.retryAfter > 0 {
+		delay = outcome.retryAfter
+	}
+	e.backoffUntil = time.Now().Add(min(delay, p.options.MaxBackoff))
+	e.lastError = err.Error()
+}
+
+/*
+Run a call on the candidate endpoints until one answers.
+
+@throws {RPCPoolError} When every attempted endpoint failed.
+*/
+func poolCall[T any](ctx context.Context, p *RPCPool, call func(ctx context.Context, c *client.Client) (T, error)) (T, error) {
+	var zero T
+	var lastErr error
+	for _, e := range p.candidates() {
+		outcome := &rpcOutcome{}
+		callCtx, cancel := context.WithTimeout(context.WithValue(ctx, rpcOutcomeKey{}, outcome), p.options.Timeout)
+		result, err := call(callCtx, e.client)
+		// The timeout may also expire reading the body
+		if outcome.err == nil && callCtx.Err() != nil {
+			outcome.err = callCtx.Err()
+		}
+		cancel()
+		if err := ctx.Err(); err != nil {
+			return zero, err
+		}
+		if !outcome.retryable() {
+			p.succeeded(e)
+			if p.pin != nil {
+				p.pin.mu.Lock()
+				p.pin.endpoint = e
+				p.pin.mu.Unlock()
+			}
+			return result, err
+		}
+		p.failed(e, outcome, err)
+		lastErr = fmt.Errorf("%s: %w", e.url, err)
+	}
+	return zero, &RPCPoolError{fmt.Sprintf("every endpoint failed, last: %s", lastErr)}
+}
+
+type rpcOutcomeKey struct{}
+
+// HTTP outcome of a call, recorded by `rpcOutcomeTransport`
+type rpcOutcome struct {
+	status     int
+	retryAfter time.Duration
+	err        error
+}
+
+// Rate limits, server errors, timeouts and network errors are worth another endpoint
+func (o *rpcOutcome) retryable() bool {
+	return o.err != nil || o.status == http.StatusTooManyRequests || o.status >= 500
+}
+
+// Records the outcome of the requests in the `rpcOutcome` of their context
+type rpcOutcomeTransport struct {
+	base http.RoundTripper
+}
+
+func (t *rpcOutcomeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	res, err := base.RoundTrip(req)
+	if outcome, ok := req.Context().Value(rpcOutcomeKey{}).(*rpcOutcome); ok {
+		if err != nil {
+			outcome.err = err
+		} else {
+			outcome.status = res.StatusCode
+			if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds > 0 {
+				outcome.retryAfter = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return res, err
+}
+
+func (p *RPCPool) GetLatestBlockhashWithConfig(ctx context.Context, cfg client.GetLatestBlockhashConfig) (rpc.GetLatestBlockhashValue, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *client.Client) (rpc.GetLatestBlockhashValue, error) {
+		return c.GetLatestBlockhashWithConfig(ctx, cfg)
+	})
+}
+
+func (p *RPCPool) IsBlockhashValid(ctx context.Context, blockhash string) (bool, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *client.Client) (bool, error) {
+		return c.IsBlockhashValid(ctx, blockhash)
+	})
+}
+
+func (p *RPCPool) IsBlockhashValidWithConfig(ctx context.Context, blockhash string, cfg client.IsBlockhashValidConfig) (bool, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *client.Client) (bool, error) {
+		return c.IsBlockhashValidWithConfig(ctx, blockhash, cfg)
+	})
+}
+
+func (p *RPCPool) GetSignaturesForAddressWithConfig(ctx context.Context, addr string, cfg client.GetSignaturesForAddressConfig) (rpc.GetSignaturesForAddress, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *client.Client) (rpc.GetSignaturesForAddress, error) {
+		return c.GetSignaturesForAddressWithConfig(ctx, addr, cfg)
+	})
+}
+
+func (p *RPCPool) GetSignatureStatus(ctx context.Context, signature string) (*rpc.SignatureStatus, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *client.Client) (*rpc.SignatureStatus, error) {
+		return c.GetSignatureStatus(ctx, signature)
+	})
+}
+
+func (p *RPCPool) GetSignatureStatusesWithConfig(ctx context.Context, signatures []string, cfg client.GetSignatureStatusesConfig) (rpc.SignatureStatuses, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *client.Client) (rpc.SignatureStatuses, error) {
+		return c.GetSignatureStatusesWithConfig(ctx, signatures, cfg)
+	})
+}
+
+func (p *RPCPool) GetTransactionWithConfig(ctx context.Context, txhash string, cfg client.GetTransactionConfig) (*client.Transaction, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *client.Client) (*client.Transaction, error) {
+		return c.GetTransactionWithConfig(ctx, txhash, cfg)
+	})
+}
+
+// Resending a signed transaction to another endpoint is safe, the cluster processes it once
+func (p *RPCPool) SendTransactionWithConfig(ctx context.Context, tx types.Transaction, cfg client.SendTransactionConfig) (string, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *client.Client) (string, error) {
+		return c.SendTransactionWithConfig(ctx, tx, cfg)
+	})
+}
+
+func (p *RPCPool) GetNonceAccount(ctx context.Context, base58Addr string) (system.NonceAccount, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *client.Client) (system.NonceAccount, error) {
+		return c.GetNonceAccount(ctx, base58Addr)
+	})
+}
+
+func (p *RPCPool) GetNonceFromNonceAccount(ctx context.Context, base58Addr string) (string, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *client.Client) (string, error) {
+		return c.GetNonceFromNonceAccount(ctx, base58Addr)
+	})
+}
+
+func (p *RPCPool) GetGenesisHash(ctx context.Context) (string, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *client.Client) (string, error) {
+		return c.GetGenesisHash(ctx)
+	})
+}
+
+func (p *RPCPool) GetBlockHeightWithConfig(ctx context.Context, cfg rpc.GetBlockHeightConfig) (uint64, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *client.Client) (uint64, error) {
+		return getBlockHeight(ctx, c, cfg.Commitment)
+	})
+}
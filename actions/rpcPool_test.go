@@ -0,0 +1,259 @@
+package actions_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"solana-actions/actions"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Fake RPC server that can be made to answer an HTTP error status or to hang
+type unstableRPC struct {
+	*fakeRPC
+	server     *httptest.Server
+	status     atomic.Int64
+	retryAfter atomic.Int64
+	delay      atomic.Int64
+	attempts   atomic.Int64
+}
+
+func newUnstableRPC(t *testing.T, handlers map[string]rpcHandler) *unstableRPC {
+	u := &unstableRPC{fakeRPC: newFakeRPC(t, handlers)}
+	u.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.attempts.Add(1)
+		if delay := time.Duration(u.delay.Load()); delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if status := int(u.status.Load()); status != 0 {
+			if retryAfter := u.retryAfter.Load(); retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		u.fakeRPC.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(u.server.Close)
+	return u
+}
+
+func newTestPool(t *testing.T, options *actions.RPCPoolOptions, servers ...*unstableRPC) *actions.RPCPool {
+	var endpoints []string
+	for _, server := range servers {
+		endpoints = append(endpoints, server.server.URL)
+	}
+	pool, err := actions.NewRPCPool(endpoints, options)
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+	return pool
+}
+
+func getBlockhash(pool *actions.RPCPool) error {
+	_, err := pool.GetLatestBlockhashWithConfig(context.Background(), client.GetLatestBlockhashConfig{})
+	return err
+}
+
+func TestRPCPool(t *testing.T) {
+	handlers := map[string]rpcHandler{
+		"getLatestBlockhash": latestBlockhashHandler(500),
+		"getHealth": func(params json.RawMessage) (any, *rpc.JsonRpcError) {
+			return "ok", nil
+		},
+	}
+
+	t.Run("spreads calls across the endpoints", func(t *testing.T) {
+		a, b := newUnstableRPC(t, handlers), newUnstableRPC(t, handlers)
+		pool := newTestPool(t, nil, a, b)
+		for i := 0; i < 4; i++ {
+			if err := getBlockhash(pool); err != nil {
+				t.Fatalf("err should be nil: %s", err.Error())
+			}
+		}
+		if a.count("getLatestBlockhash") != 2 || b.count("getLatestBlockhash") != 2 {
+			t.Errorf("got %d and %d calls want 2 each", a.count("getLatestBlockhash"), b.count("getLatestBlockhash"))
+		}
+	})
+
+	for name, setup := range map[string]func(u *unstableRPC){
+		"fails over on rate limits":   func(u *unstableRPC) { u.status.Store(http.StatusTooManyRequests) },
+		"fails over on server errors": func(u *unstableRPC) { u.status.Store(http.StatusBadGateway) },
+		"fails over on timeouts":      func(u *unstableRPC) { u.delay.Store(int64(time.Second)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			failing, healthy := newUnstableRPC(t, handlers), newUnstableRPC(t, handlers)
+			setup(failing)
+			pool := newTestPool(t, &actions.RPCPoolOptions{Timeout: 50 * time.Millisecond, InitialBackoff: time.Minute}, failing, healthy)
+			for i := 0; i < 4; i++ {
+				if err := getBlockhash(pool); err != nil {
+					t.Fatalf("err should be nil: %s", err.Error())
+				}
+			}
+			if failing.attempts.Load() != 1 || healthy.count("getLatestBlockhash") != 4 {
+				t.Errorf("got %d attempts on the failing endpoint and %d calls on the healthy one", failing.attempts.Load(), healthy.count("getLatestBlockhash"))
+			}
+			health := pool.Health()
+			if health[0].Healthy || health[0].Failures != 1 || health[0].LastError == "" || !health[1].Healthy {
+				t.Errorf("unexpected health %+v", health)
+			}
+		})
+	}
+
+	t.Run("honors Retry-After", func(t *testing.T) {
+		limited, healthy := newUnstableRPC(t, handlers), newUnstableRPC(t, handlers)
+		limited.status.Store(http.StatusTooManyRequests)
+		limited.retryAfter.Store(30)
+		pool := newTestPool(t, &actions.RPCPoolOptions{InitialBackoff: time.Millisecond}, limited, healthy)
+		getBlockhash(pool)
+		health := pool.Health()[0]
+		if health.BackoffUntil == nil || time.Until(*health.BackoffUntil) < 20*time.Second {
+			t.Errorf("unexpected health %+v", health)
+		}
+	})
+
+	t.Run("returns JSON-RPC errors without failing over", func(t *testing.T) {
+		a, b := newUnstableRPC(t, nil), newUnstableRPC(t, nil)
+		pool := newTestPool(t, nil, a, b)
+		var rpcErr *rpc.JsonRpcError
+		if err := getBlockhash(pool); !errors.As(err, &rpcErr) {
+			t.Errorf("unexpected error %v", err)
+		}
+		if attempts := a.attempts.Load() + b.attempts.Load(); attempts != 1 {
+			t.Errorf("got %d attempts want 1", attempts)
+		}
+	})
+
+	t.Run("fails when every endpoint fails", func(t *testing.T) {
+		a, b := newUnstableRPC(t, handlers), newUnstableRPC(t, handlers)
+		a.status.Store(http.StatusServiceUnavailable)
+		b.status.Store(http.StatusInternalServerError)
+		pool := newTestPool(t, nil, a, b)
+		var poolErr *actions.RPCPoolError
+		if err := getBlockhash(pool); !errors.As(err, &poolErr) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("pins calls to an endpoint", func(t *testing.T) {
+		a, b := newUnstableRPC(t, handlers), newUnstableRPC(t, handlers)
+		pinned := newTestPool(t, nil, a, b).Pin()
+		for i := 0; i < 4; i++ {
+			getBlockhash(pinned)
+		}
+		first, second := a, b
+		if b.count("getLatestBlockhash") > 0 {
+			first, second = b, a
+		}
+		if first.count("getLatestBlockhash") != 4 || second.count("getLatestBlockhash") != 0 {
+			t.Fatalf("got %d and %d calls, want all on one endpoint", first.count("getLatestBlockhash"), second.count("getLatestBlockhash"))
+		}
+
+		first.status.Store(http.StatusServiceUnavailable)
+		for i := 0; i < 3; i++ {
+			if err := getBlockhash(pinned); err != nil {
+				t.Fatalf("err should be nil: %s", err.Error())
+			}
+		}
+		first.status.Store(0)
+		getBlockhash(pinned)
+		if second.count("getLatestBlockhash") != 4 {
+			t.Errorf("got %d calls want the pin to move to the other endpoint", second.count("getLatestBlockhash"))
+		}
+	})
+
+	t.Run("pinned clients fetch, send and confirm on one endpoint", func(t *testing.T) {
+		user := types.NewAccount()
+		tx := newTestTransaction(user.PublicKey, system.Transfer(system.TransferParam{From: user.PublicKey, To: types.NewAccount().PublicKey, Amount: 1}))
+		action := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(actions.ActionPostResponse{Transaction: encodeTestTransaction(t, tx)})
+		}))
+		defer action.Close()
+		link, _ := url.Parse(action.URL + "/api/transfer")
+
+		var statusCalls atomic.Int64
+		sendHandlers := map[string]rpcHandler{
+			"getLatestBlockhash": latestBlockhashHandler(100),
+			"sendTransaction":    func(json.RawMessage) (any, *rpc.JsonRpcError) { return "sig", nil },
+			"getSignatureStatuses": func(json.RawMessage) (any, *rpc.JsonRpcError) {
+				if statusCalls.Add(1) < 2 {
+					return withContext([]any{nil}), nil
+				}
+				return withContext([]any{map[string]any{"slot": 42, "confirmationStatus": "confirmed"}}), nil
+			},
+			"getBlockHeight": func(json.RawMessage) (any, *rpc.JsonRpcError) { return 90, nil },
+		}
+		a, b := newUnstableRPC(t, sendHandlers), newUnstableRPC(t, sendHandlers)
+		conn := actions.PinRPC(newTestPool(t, nil, a, b))
+
+		resp, err := actions.FetchTransactionWithOptions(context.Background(), conn, link, actions.ActionPostRequest{Account: user.PublicKey.String()}, nil)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		result, err := actions.SendAndConfirm(context.Background(), conn, &resp.Transaction, actions.NewKeypairSigner(user), &actions.SendAndConfirmOptions{
+			LastValidBlockHeight: resp.LastValidBlockHeight,
+			RetryInterval:        time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if result.Status != actions.SEND_STATUS_CONFIRMED {
+			t.Errorf("got %s want %s", result.Status, actions.SEND_STATUS_CONFIRMED)
+		}
+
+		served, idle := a, b
+		if b.attempts.Load() > 0 {
+			served, idle = b, a
+		}
+		if idle.attempts.Load() != 0 {
+			t.Errorf("got %d and %d attempts, want all on one endpoint", a.attempts.Load(), b.attempts.Load())
+		}
+		for _, method := range []string{"getLatestBlockhash", "sendTransaction", "getSignatureStatuses", "getBlockHeight"} {
+			if served.count(method) == 0 {
+				t.Errorf("expected %s to be called on the pinned endpoint", method)
+			}
+		}
+	})
+
+	t.Run("checks the health of the endpoints", func(t *testing.T) {
+		a, b := newUnstableRPC(t, handlers), newUnstableRPC(t, handlers)
+		b.status.Store(http.StatusServiceUnavailable)
+		pool := newTestPool(t, nil, a, b)
+		if err := pool.CheckHealth(context.Background()); err == nil {
+			t.Error("expected an error for the unhealthy endpoint")
+		}
+		if health := pool.Health(); !health[0].Healthy || health[1].Healthy {
+			t.Errorf("unexpected health %+v", health)
+		}
+		b.status.Store(0)
+		if err := pool.CheckHealth(context.Background()); err != nil {
+			t.Errorf("err should be nil: %s", err.Error())
+		}
+		if health := pool.Health(); !health[1].Healthy || health[1].Failures != 0 {
+			t.Errorf("unexpected health %+v", health)
+		}
+	})
+
+	t.Run("rejects invalid endpoints", func(t *testing.T) {
+		for _, endpoints := range [][]string{nil, {"localhost:8899"}} {
+			if _, err := actions.NewRPCPool(endpoints, nil); err == nil {
+				t.Errorf("expected an error for %v", endpoints)
+			}
+		}
+	})
+}
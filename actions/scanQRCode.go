@@ -0,0 +1,71 @@
+package actions
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/url"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+/*
+Decode the payload of the QR code in an image.
+
+Photos and screenshots are scanned harder, then as an inverted code, light
+modules on a dark background.
+
+@throws {QRCodeError}
+*/
+func DecodeQRCode(img image.Image) (string, error) {
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", &QRCodeError{err.Error()}
+	}
+	reader := qrcode.NewQRCodeReader()
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	result, err := reader.Decode(bitmap, hints)
+	if err != nil {
+		inverted, invertErr := gozxing.NewBinaryBitmap(gozxing.NewHybridBinarizer(gozxing.NewLuminanceSourceFromImage(img).Invert()))
+		if invertErr == nil {
+			result, err = reader.Decode(inverted, hints)
+		}
+	}
+	if err != nil {
+		return "", &QRCodeError{fmt.Sprintf("no QR code found: %s", err)}
+	}
+	return result.GetText(), nil
+}
+
+/*
+Decode the QR code of a PNG or JPEG image and parse its payload with `ParseURL`.
+
+@param r - PNG or JPEG image.
+
+@return The fields returned by `ParseURL` and the raw payload, also returned
+with errors when the QR code holds something else than a Solana URL.
+
+@throws {QRCodeError}
+*/
+func ScanQRCode(r io.Reader) (any, string, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, "", &QRCodeError{fmt.Sprintf("invalid image: %s", err)}
+	}
+	payload, err := DecodeQRCode(img)
+	if err != nil {
+		return nil, "", err
+	}
+	link, err := url.Parse(payload)
+	if err != nil {
+		return nil, payload, &QRCodeError{fmt.Sprintf("payload %q is not a URL", payload)}
+	}
+	fields, err := ParseURL(link)
+	if err != nil {
+		return nil, payload, &QRCodeError{fmt.Sprintf("payload %q is not a Solana URL: %s", payload, err)}
+	}
+	return fields, payload, nil
+}
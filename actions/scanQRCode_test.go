@@ -0,0 +1,84 @@
+package actions_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"net/url"
+	"solana-actions/actions"
+	"strings"
+	"testing"
+)
+
+func TestScanQRCode(t *testing.T) {
+	encode := func(t *testing.T, raw string, options *actions.QRCodeOptions) []byte {
+		t.Helper()
+		link, _ := url.Parse(raw)
+		q, err := actions.NewQRCode(link, options)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		png, err := q.PNG()
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		return png
+	}
+
+	t.Run("round trips transfer requests", func(t *testing.T) {
+		raw := "solana:4uhcVJyU9pJkvQyS88uRDiswHXSCkY3zQawwpjk2NsNY?amount=1.5&label=Coffee%20shop"
+		logo := image.NewUniform(color.RGBA{0x99, 0x45, 0xff, 0xff})
+		for _, options := range []*actions.QRCodeOptions{nil, actions.NewSolanaPayQRCodeOptions(nil), actions.NewSolanaPayQRCodeOptions(cropped(logo, 40))} {
+			fields, payload, err := actions.ScanQRCode(bytes.NewReader(encode(t, raw, options)))
+			if err != nil {
+				t.Fatalf("err should be nil: %s", err.Error())
+			}
+			transfer, ok := fields.(*actions.TransferRequestURLFields)
+			if !ok || payload != raw || transfer.Amount.String() != "1.5" || *transfer.Label != "Coffee shop" {
+				t.Errorf("got %+v %s", fields, payload)
+			}
+		}
+	})
+
+	t.Run("decodes JPEG action URLs", func(t *testing.T) {
+		raw := "solana-action:https://example.com/api/donate"
+		img, _, _ := image.Decode(bytes.NewReader(encode(t, raw, nil)))
+		var buf bytes.Buffer
+		jpeg.Encode(&buf, img, &jpeg.Options{Quality: 60})
+		fields, _, err := actions.ScanQRCode(&buf)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		action, ok := fields.(*actions.ActionRequestURLFields)
+		if !ok || action.Link.String() != "https://example.com/api/donate" {
+			t.Errorf("got %+v", fields)
+		}
+	})
+
+	t.Run("rejects non-Solana URLs", func(t *testing.T) {
+		_, payload, err := actions.ScanQRCode(bytes.NewReader(encode(t, "https://example.com/menu", nil)))
+		if err == nil || payload != "https://example.com/menu" || !strings.Contains(err.Error(), "not a Solana URL") {
+			t.Errorf("unexpected error %v %s", err, payload)
+		}
+	})
+
+	t.Run("rejects images without a QR code", func(t *testing.T) {
+		if _, _, err := actions.ScanQRCode(strings.NewReader("not an image")); err == nil {
+			t.Error("expected an error")
+		}
+		var buf bytes.Buffer
+		jpeg.Encode(&buf, cropped(image.White, 100), nil)
+		if _, _, err := actions.ScanQRCode(&buf); err == nil || !strings.Contains(err.Error(), "no QR code found") {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
+
+// Square image of `size` pixels filled with `src`
+func cropped(src image.Image, size int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), src, image.Point{}, draw.Src)
+	return img
+}
@@ -0,0 +1,321 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/mr-tron/base58"
+)
+
+// Thrown when a transaction can't be signed or the cluster can't be reached while sending it
+type SendTransactionError struct {
+	Message string
+}
+
+func (e *SendTransactionError) Error() string {
+	return fmt.Sprintf("SendTransactionError: %s", e.Message)
+}
+
+// Signs transactions on behalf of the `account` of an action
+type Signer interface {
+	PublicKey() common.PublicKey
+	Sign(message []byte) ([]byte, error)
+}
+
+type keypairSigner struct {
+	account types.Account
+}
+
+// Creates a `Signer` for a local keypair
+func NewKeypairSigner(account types.Account) Signer {
+	return &keypairSigner{account}
+}
+
+func (s *keypairSigner) PublicKey() common.PublicKey {
+	return s.account.PublicKey
+}
+
+func (s *keypairSigner) Sign(message []byte) ([]byte, error) {
+	return s.account.Sign(message), nil
+}
+
+/*
+Sign the signature slot of `signer` in a transaction.
+
+@throws {SendTransactionError}
+*/
+func SignTransaction(tx *types.Transaction, signer Signer) error {
+	msg, err := tx.Message.Serialize()
+	if err != nil {
+		return &SendTransactionError{err.Error()}
+	}
+	required := int(tx.Message.Header.NumRequireSignatures)
+	for len(tx.Signatures) < required {
+		tx.Signatures = append(tx.Signatures, make(types.Signature, 64))
+	}
+	publicKey := signer.PublicKey()
+	for i := 0; i < required && i < len(tx.Message.Accounts); i++ {
+		if tx.Message.Accounts[i] != publicKey {
+			continue
+		}
+		sig, err := signer.Sign(msg)
+		if err != nil {
+			return &SendTransactionError{err.Error()}
+		}
+		tx.Signatures[i] = sig
+		return nil
+	}
+	return &SendTransactionError{fmt.Sprintf("%s is not a signer of the transaction", publicKey)}
+}
+
+// Outcome of `SendAndConfirm`
+type SendStatus string
+
+const (
+	SEND_STATUS_CONFIRMED SendStatus = "confirmed"
+
+	// The blockhash expired before the transaction was confirmed
+	SEND_STATUS_EXPIRED SendStatus = "expired"
+
+	// The transaction was rejected or failed with a program error
+	SEND_STATUS_FAILED SendStatus = "failed"
+)
+
+type SendResult struct {
+	Status SendStatus `json:"status"`
+
+	// Base58 encoded transaction signature
+	Signature string `json:"signature"`
+
+	// Slot the transaction was processed in, zero unless confirmed or failed on chain
+	Slot uint64 `json:"slot,omitempty"`
+
+	// Program error of a failed transaction
+	Err any `json:"err,omitempty"`
+}
+
+// Options for `SendAndConfirm`
+type SendAndConfirmOptions struct {
+	// Commitment to confirm the transaction at, defaults to `confirmed`
+	Commitment rpc.Commitment
+
+	/*
+		Last block height at which the blockhash is valid, as returned by `FetchTransaction`.
+		When zero the blockhash is checked with `isBlockhashValid` instead.
+		Durable nonce transactions expire when their nonce is advanced.
+	*/
+	LastValidBlockHeight uint64
+
+	// Time between status checks and rebroadcasts, defaults to 2 seconds
+	RetryInterval time.Duration
+
+	SkipPreflight bool
+
+	// Settles the spend reserved for the signer once the outcome is known, e.g. a `PolicyEngine`
+	SpendSettler SpendSettler
+}
+
+// JSON-RPC error code returned when preflight simulation fails
+const rpcErrorSendTransactionPreflightFailure = -32002
+
+/*
+Sign, send and confirm an action transaction.
+
+The transaction is rebroadcast until it reaches `options.Commitment` or its
+blockhash expires. Transactions that fail on chain or in preflight are
+reported through `SendResult.Status`, not as an error. The spend reserved for
+the transaction is committed with `options.SpendSettler` once it's confirmed,
+and released when it can't land: when it isn't sent, fails or expires. It
+stays reserved when the outcome is unknown, e.g. when `ctx` is done. Errors
+of the settler are returned along with the result.
+
+@param ctx - Cancels sending and confirming.
+
+@param connection - A connection to the cluster, pinned with `PinRPC` when it
+was used to fetch the transaction.
+
+@param tx - Transaction returned by `FetchTransaction`.
+
+@param signer - Signer for the `account` of the action.
+
+@param options - Send options, may be nil.
+
+@throws {SendTransactionError}
+*/
+func SendAndConfirm(ctx context.Context, conn RPCClient, tx *types.Transaction, signer Signer, options *SendAndConfirmOptions) (*SendResult, error) {
+	if options == nil {
+		options = &SendAndConfirmOptions{}
+	}
+	// Statuses and expiry are checked on the endpoint the transaction was sent to
+	conn = PinRPC(conn)
+	commitment := options.Commitment
+	if commitment == "" {
+		commitment = rpc.CommitmentConfirmed
+	}
+	interval := options.RetryInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	nonceAccount, err := nonceAccountOf(tx)
+	if err != nil {
+		return nil, withReleasedSpend(err, tx, signer, options)
+	}
+	if err := SignTransaction(tx, signer); err != nil {
+		return nil, withReleasedSpend(err, tx, signer, options)
+	}
+	for i, sig := range tx.Signatures {
+		if isEmptySignature(sig) {
+			return nil, withReleasedSpend(&SendTransactionError{fmt.Sprintf("missing signature for %s", tx.Message.Accounts[i])}, tx, signer, options)
+		}
+	}
+	signature := base58.Encode(tx.Signatures[0])
+	sendConfig := client.SendTransactionConfig{
+		SkipPreflight:       options.SkipPreflight,
+		PreflightCommitment: commitment,
+		// Rebroadcasting is handled here so it stops when the blockhash expires
+		MaxRetries: 0,
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	landed := false
+	for {
+		// Landed transactions aren't rebroadcast, a resend would fail preflight as already processed
+		if !landed {
+			if _, err := conn.SendTransactionWithConfig(ctx, *tx, sendConfig); err != nil {
+				var rpcErr *rpc.JsonRpcError
+				if errors.As(err, &rpcErr) && rpcErr.Code == rpcErrorSendTransactionPreflightFailure {
+					return settled(tx, signer, options, &SendResult{Status: SEND_STATUS_FAILED, Signature: signature, Err: rpcErr.Data})
+				}
+				// Transient send errors are retried until the blockhash expires
+				if ctx.Err() != nil {
+					return nil, &SendTransactionError{ctx.Err().Error()}
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, &SendTransactionError{ctx.Err().Error()}
+		case <-ticker.C:
+		}
+
+		status, err := conn.GetSignatureStatus(ctx, signature)
+		if err != nil {
+			return nil, &SendTransactionError{err.Error()}
+		}
+		if status == nil {
+			expired, err := blockhashExpired(ctx, conn, tx, nonceAccount, options.LastValidBlockHeight, commitment)
+			if err != nil {
+				return nil, &SendTransactionError{err.Error()}
+			}
+			if !expired {
+				continue
+			}
+			// The transaction may have landed since the status check, advancing its own nonce
+			if status, err = conn.GetSignatureStatus(ctx, signature); err != nil {
+				return nil, &SendTransactionError{err.Error()}
+			}
+			if status == nil {
+				return settled(tx, signer, options, &SendResult{Status: SEND_STATUS_EXPIRED, Signature: signature})
+			}
+		}
+
+		if status.Err != nil {
+			return settled(tx, signer, options, &SendResult{Status: SEND_STATUS_FAILED, Signature: signature, Slot: status.Slot, Err: status.Err})
+		}
+		if status.ConfirmationStatus != nil && commitmentReached(*status.ConfirmationStatus, commitment) {
+			return settled(tx, signer, options, &SendResult{Status: SEND_STATUS_CONFIRMED, Signature: signature, Slot: status.Slot})
+		}
+		// Landed but not yet at the requested commitment, keep polling without checking expiry
+		landed = true
+	}
+}
+
+// Settle the spend of a final result, which is returned even when the spend can't be settled
+func settled(tx *types.Transaction, signer Signer, options *SendAndConfirmOptions, result *SendResult) (*SendResult, error) {
+	if options.SpendSettler == nil {
+		return result, nil
+	}
+	if result.Status != SEND_STATUS_CONFIRMED {
+		return result, releaseSpend(tx, signer, options)
+	}
+	if err := options.SpendSettler.CommitSpend(tx, signer.PublicKey()); err != nil {
+		return result, &SendTransactionError{fmt.Sprintf("transaction confirmed but its spend wasn't committed: %s", err)}
+	}
+	return result, nil
+}
+
+// Release the spend of a transaction that wasn't sent, adding the release errors to `err`
+func withReleasedSpend(err error, tx *types.Transaction, signer Signer, options *SendAndConfirmOptions) error {
+	if releaseErr := releaseSpend(tx, signer, options); releaseErr != nil {
+		return errors.Join(err, releaseErr)
+	}
+	return err
+}
+
+func releaseSpend(tx *types.Transaction, signer Signer, options *SendAndConfirmOptions) error {
+	if options.SpendSettler == nil {
+		return nil
+	}
+	if err := options.SpendSettler.ReleaseSpend(tx, signer.PublicKey()); err != nil {
+		return &SendTransactionError{fmt.Sprintf("spend wasn't released: %s", err)}
+	}
+	return nil
+}
+
+/*
+Nonce account advanced by the first instruction of a durable nonce transaction.
+
+@return nil when the transaction isn't a durable nonce transaction.
+
+@throws {SendTransactionError} When the instruction references accounts the message doesn't have.
+*/
+func nonceAccountOf(tx *types.Transaction) (*common.PublicKey, error) {
+	if !IsNonceTransaction(tx) {
+		return nil, nil
+	}
+	accounts := tx.Message.Instructions[0].Accounts
+	if len(accounts) == 0 || accounts[0] < 0 || accounts[0] >= len(tx.Message.Accounts) {
+		return nil, &SendTransactionError{"invalid nonce account index"}
+	}
+	return &tx.Message.Accounts[accounts[0]], nil
+}
+
+func blockhashExpired(ctx context.Context, conn RPCClient, tx *types.Transaction, nonceAccount *common.PublicKey, lastValidBlockHeight uint64, commitment rpc.Commitment) (bool, error) {
+	blockhash := tx.Message.RecentBlockHash
+	if nonceAccount != nil {
+		// A nonce transaction stays valid until the nonce is advanced, by another transaction or by itself
+		nonce, err := conn.GetNonceFromNonceAccount(ctx, nonceAccount.String())
+		if err != nil {
+			return false, err
+		}
+		return nonce != blockhash, nil
+	}
+	if lastValidBlockHeight == 0 {
+		valid, err := conn.IsBlockhashValidWithConfig(ctx, blockhash, client.IsBlockhashValidConfig{Commitment: commitment})
+		return !valid, err
+	}
+	height, err := getBlockHeight(ctx, conn, commitment)
+	if err != nil {
+		return false, err
+	}
+	return height > lastValidBlockHeight, nil
+}
+
+var commitmentLevels = map[rpc.Commitment]int{
+	rpc.CommitmentProcessed: 0,
+	rpc.CommitmentConfirmed: 1,
+	rpc.CommitmentFinalized: 2,
+}
+
+func commitmentReached(status rpc.Commitment, target rpc.Commitment) bool {
+	return commitmentLevels[status] >= commitmentLevels[target]
+}
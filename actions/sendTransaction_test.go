@@ -0,0 +1,378 @@
+package actions_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"solana-actions/actions"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+const testBlockhash = "4uhcVJyU9pJkvQyS88uRDiswHXSCkY3zQawwpjk2NsNY"
+
+// JSON-RPC method handler of a fake cluster, returns the result or a JSON-RPC error
+type rpcHandler func(params json.RawMessage) (any, *rpc.JsonRpcError)
+
+// Fake cluster answering JSON-RPC calls with the given handlers
+type fakeRPC struct {
+	*httptest.Server
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newFakeRPC(t *testing.T, handlers map[string]rpcHandler) *fakeRPC {
+	t.Helper()
+	f := &fakeRPC{calls: map[string]int{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     any             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.calls[req.Method]++
+		f.mu.Unlock()
+
+		res := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		handler, ok := handlers[req.Method]
+		if !ok {
+			res["error"] = &rpc.JsonRpcError{Code: -32601, Message: "method not found: " + req.Method}
+		} else if result, rpcErr := handler(req.Params); rpcErr != nil {
+			res["error"] = rpcErr
+		} else {
+			res["result"] = result
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeRPC) client() *client.Client {
+	return client.NewClient(f.URL)
+}
+
+func (f *fakeRPC) count(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+func withContext(value any) any {
+	return map[string]any{"context": map[string]any{"slot": 1}, "value": value}
+}
+
+func latestBlockhashHandler(lastValidBlockHeight uint64) rpcHandler {
+	return func(json.RawMessage) (any, *rpc.JsonRpcError) {
+		return withContext(map[string]any{"blockhash": testBlockhash, "lastValidBlockHeight": lastValidBlockHeight}), nil
+	}
+}
+
+func encodeTestTransaction(t *testing.T, tx *types.Transaction) string {
+	t.Helper()
+	for i := range tx.Signatures {
+		if tx.Signatures[i] == nil {
+			tx.Signatures[i] = make(types.Signature, 64)
+		}
+	}
+	raw, err := tx.Serialize()
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestSerializeTransaction(t *testing.T) {
+	user := types.NewAccount()
+	provider := types.NewAccount()
+	fake := newFakeRPC(t, map[string]rpcHandler{
+		"getLatestBlockhash": latestBlockhashHandler(500),
+	})
+
+	t.Run("replaces the blockhash of transactions only the account signs", func(t *testing.T) {
+		tx := newTestTransaction(user.PublicKey, system.Transfer(system.TransferParam{From: user.PublicKey, To: provider.PublicKey, Amount: 1}))
+
+		serialized, err := actions.SerializeTransaction(fake.client(), user.PublicKey, encodeTestTransaction(t, tx), rpc.CommitmentConfirmed)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if serialized.Message.RecentBlockHash != testBlockhash {
+			t.Errorf("got %s want %s", serialized.Message.RecentBlockHash, testBlockhash)
+		}
+	})
+
+	t.Run("keeps the blockhash of transactions partially signed by the provider", func(t *testing.T) {
+		tx := newTestTransaction(provider.PublicKey, system.Transfer(system.TransferParam{From: user.PublicKey, To: provider.PublicKey, Amount: 1}))
+		msg, _ := tx.Message.Serialize()
+		tx.Signatures[0] = provider.Sign(msg)
+
+		serialized, err := actions.SerializeTransaction(fake.client(), user.PublicKey, encodeTestTransaction(t, tx), rpc.CommitmentConfirmed)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if serialized.Message.RecentBlockHash != tx.Message.RecentBlockHash {
+			t.Errorf("got %s want %s", serialized.Message.RecentBlockHash, tx.Message.RecentBlockHash)
+		}
+	})
+
+	t.Run("throws on invalid signatures", func(t *testing.T) {
+		tx := newTestTransaction(provider.PublicKey, system.Transfer(system.TransferParam{From: user.PublicKey, To: provider.PublicKey, Amount: 1}))
+		tx.Signatures[0] = user.Sign([]byte("not the message"))
+
+		_, err := actions.SerializeTransaction(fake.client(), user.PublicKey, encodeTestTransaction(t, tx), rpc.CommitmentConfirmed)
+		if err == nil || err.Error() != "SerializeTransactionError: invalid signature" {
+			t.Errorf("got %v want %s", err, "SerializeTransactionError: invalid signature")
+		}
+	})
+
+	t.Run("verifies the signature of each signer", func(t *testing.T) {
+		cosigner := types.NewAccount()
+		transfer := system.Transfer(system.TransferParam{From: cosigner.PublicKey, To: user.PublicKey, Amount: 1})
+		transfer.Accounts[0].IsSigner = true
+		tx := newTestTransaction(provider.PublicKey, transfer, system.Transfer(system.TransferParam{From: user.PublicKey, To: provider.PublicKey, Amount: 1}))
+		msg, _ := tx.Message.Serialize()
+		for i, account := range tx.Message.Accounts[:len(tx.Signatures)] {
+			switch account {
+			case provider.PublicKey:
+				tx.Signatures[i] = provider.Sign(msg)
+			case cosigner.PublicKey:
+				tx.Signatures[i] = cosigner.Sign(msg)
+			}
+		}
+		if _, err := actions.SerializeTransaction(fake.client(), user.PublicKey, encodeTestTransaction(t, tx), rpc.CommitmentConfirmed); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+
+		// A valid signature of the message by another key
+		tx.Signatures[0] = cosigner.Sign(msg)
+		_, err := actions.SerializeTransaction(fake.client(), user.PublicKey, encodeTestTransaction(t, tx), rpc.CommitmentConfirmed)
+		if err == nil || err.Error() != "SerializeTransactionError: invalid signature" {
+			t.Errorf("got %v want %s", err, "SerializeTransactionError: invalid signature")
+		}
+	})
+
+	t.Run("decodes base64 transactions", func(t *testing.T) {
+		tx := newTestTransaction(user.PublicKey, system.Transfer(system.TransferParam{From: user.PublicKey, To: provider.PublicKey, Amount: 1}))
+		raw, _ := tx.Serialize()
+		for _, encoded := range []string{string(raw), base64.RawURLEncoding.EncodeToString(raw) + "~"} {
+			var serializeErr *actions.SerializeTransactionError
+			if _, err := actions.SerializeTransaction(fake.client(), user.PublicKey, encoded, rpc.CommitmentConfirmed); !errors.As(err, &serializeErr) {
+				t.Errorf("expected a SerializeTransactionError, got %v", err)
+			}
+		}
+	})
+
+	t.Run("throws on transactions without a fee payer", func(t *testing.T) {
+		// An empty signature, a message header, no account, a blockhash and no instruction
+		raw := append(append([]byte{1}, make([]byte, 64)...), append([]byte{1, 0, 0, 0}, make([]byte, 33)...)...)
+		_, err := actions.SerializeTransaction(fake.client(), user.PublicKey, base64.StdEncoding.EncodeToString(raw), rpc.CommitmentConfirmed)
+		var serializeErr *actions.SerializeTransactionError
+		if !errors.As(err, &serializeErr) || serializeErr.Message != "missing fee payer" {
+			t.Errorf("got %v want a missing fee payer error", err)
+		}
+	})
+
+	t.Run("throws on missing signatures of other signers", func(t *testing.T) {
+		tx := newTestTransaction(provider.PublicKey, system.Transfer(system.TransferParam{From: user.PublicKey, To: provider.PublicKey, Amount: 1}))
+
+		_, err := actions.SerializeTransaction(fake.client(), user.PublicKey, encodeTestTransaction(t, tx), rpc.CommitmentConfirmed)
+		if err == nil || err.Error() != "SerializeTransactionError: missing signature" {
+			t.Errorf("got %v want %s", err, "SerializeTransactionError: missing signature")
+		}
+	})
+}
+
+func TestSendAndConfirm(t *testing.T) {
+	user := types.NewAccount()
+	signer := actions.NewKeypairSigner(user)
+	options := &actions.SendAndConfirmOptions{LastValidBlockHeight: 100, RetryInterval: time.Millisecond}
+
+	newTx := func() *types.Transaction {
+		return newTestTransaction(user.PublicKey, system.Transfer(system.TransferParam{From: user.PublicKey, To: types.NewAccount().PublicKey, Amount: 1}))
+	}
+
+	t.Run("rebroadcasts until confirmed", func(t *testing.T) {
+		var statusCalls int
+		fake := newFakeRPC(t, map[string]rpcHandler{
+			"sendTransaction": func(json.RawMessage) (any, *rpc.JsonRpcError) { return "sig", nil },
+			"getSignatureStatuses": func(json.RawMessage) (any, *rpc.JsonRpcError) {
+				statusCalls++
+				if statusCalls < 3 {
+					return withContext([]any{nil}), nil
+				}
+				return withContext([]any{map[string]any{"slot": 42, "confirmationStatus": "confirmed"}}), nil
+			},
+			"getBlockHeight": func(json.RawMessage) (any, *rpc.JsonRpcError) { return 90, nil },
+		})
+
+		result, err := actions.SendAndConfirm(context.Background(), fake.client(), newTx(), signer, options)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if result.Status != actions.SEND_STATUS_CONFIRMED || result.Slot != 42 {
+			t.Errorf("got %s at slot %d want %s at slot 42", result.Status, result.Slot, actions.SEND_STATUS_CONFIRMED)
+		}
+		if fake.count("sendTransaction") != 3 {
+			t.Errorf("got %d sends want 3", fake.count("sendTransaction"))
+		}
+	})
+
+	t.Run("settles the reserved spend", func(t *testing.T) {
+		failing := false
+		fake := newFakeRPC(t, map[string]rpcHandler{
+			"sendTransaction": func(json.RawMessage) (any, *rpc.JsonRpcError) { return "sig", nil },
+			"getSignatureStatuses": func(json.RawMessage) (any, *rpc.JsonRpcError) {
+				if failing {
+					return withContext([]any{map[string]any{"slot": 42, "err": map[string]any{"InstructionError": []any{0, "Custom"}}}}), nil
+				}
+				return withContext([]any{map[string]any{"slot": 42, "confirmationStatus": "confirmed"}}), nil
+			},
+		})
+		policy, err := actions.LoadPolicy(strings.NewReader(`{"perDay": {"sol": "0.000000001"}}`))
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		engine, _ := actions.NewPolicyEngine(policy, nil)
+		settling := *options
+		settling.SpendSettler = engine
+
+		failing = true
+		tx := newTx()
+		if err := engine.VerifyTransaction(tx, user.PublicKey); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if result, err := actions.SendAndConfirm(context.Background(), fake.client(), tx, signer, &settling); err != nil || result.Status != actions.SEND_STATUS_FAILED {
+			t.Fatalf("got %+v, %v want a failed transaction", result, err)
+		}
+
+		failing = false
+		tx = newTx()
+		if err := engine.VerifyTransaction(tx, user.PublicKey); err != nil {
+			t.Fatalf("the spend of failed transactions should be released: %s", err.Error())
+		}
+		if _, err := actions.SendAndConfirm(context.Background(), fake.client(), tx, signer, &settling); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if err := engine.VerifyTransaction(newTx(), user.PublicKey); err == nil {
+			t.Error("expected the daily cap to count the confirmed transaction")
+		}
+	})
+
+	t.Run("stops when the blockhash expires", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{
+			"sendTransaction":      func(json.RawMessage) (any, *rpc.JsonRpcError) { return "sig", nil },
+			"getSignatureStatuses": func(json.RawMessage) (any, *rpc.JsonRpcError) { return withContext([]any{nil}), nil },
+			"getBlockHeight":       func(json.RawMessage) (any, *rpc.JsonRpcError) { return 101, nil },
+		})
+
+		result, err := actions.SendAndConfirm(context.Background(), fake.client(), newTx(), signer, options)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if result.Status != actions.SEND_STATUS_EXPIRED {
+			t.Errorf("got %s want %s", result.Status, actions.SEND_STATUS_EXPIRED)
+		}
+	})
+
+	t.Run("confirms nonce transactions that advanced their own nonce", func(t *testing.T) {
+		nonce := &actions.DurableNonce{Account: types.NewAccount().PublicKey, Authority: user.PublicKey, Value: "EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG"}
+		transfer := system.Transfer(system.TransferParam{From: user.PublicKey, To: types.NewAccount().PublicKey, Amount: 1})
+		tx, err := types.NewTransaction(types.NewTransactionParam{Message: nonce.NewMessage(user.PublicKey, []types.Instruction{transfer}), Signers: []types.Account{user}})
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		advanced := types.NewAccount().PublicKey
+		var statusCalls int
+		fake := newFakeRPC(t, map[string]rpcHandler{
+			"sendTransaction": func(json.RawMessage) (any, *rpc.JsonRpcError) { return "sig", nil },
+			// The transaction lands between the status check and the nonce check
+			"getSignatureStatuses": func(json.RawMessage) (any, *rpc.JsonRpcError) {
+				statusCalls++
+				if statusCalls == 1 {
+					return withContext([]any{nil}), nil
+				}
+				return withContext([]any{map[string]any{"slot": 42, "confirmationStatus": "confirmed"}}), nil
+			},
+			"getAccountInfo": func(json.RawMessage) (any, *rpc.JsonRpcError) {
+				return withContext(map[string]any{
+					"data":       []any{base64.StdEncoding.EncodeToString(advanced.Bytes()), "base64"},
+					"owner":      common.SystemProgramID.String(),
+					"lamports":   1,
+					"executable": false,
+					"rentEpoch":  0,
+				}), nil
+			},
+		})
+
+		result, err := actions.SendAndConfirm(context.Background(), fake.client(), &tx, signer, options)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if result.Status != actions.SEND_STATUS_CONFIRMED || fake.count("getAccountInfo") != 1 {
+			t.Errorf("got %s after %d nonce checks want %s after 1", result.Status, fake.count("getAccountInfo"), actions.SEND_STATUS_CONFIRMED)
+		}
+	})
+
+	t.Run("throws on nonce instructions with invalid accounts", func(t *testing.T) {
+		nonce := &actions.DurableNonce{Account: types.NewAccount().PublicKey, Authority: user.PublicKey, Value: "EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG"}
+		transfer := system.Transfer(system.TransferParam{From: user.PublicKey, To: types.NewAccount().PublicKey, Amount: 1})
+		tx, err := types.NewTransaction(types.NewTransactionParam{Message: nonce.NewMessage(user.PublicKey, []types.Instruction{transfer}), Signers: []types.Account{user}})
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		tx.Message.Instructions[0].Accounts[0] = len(tx.Message.Accounts)
+		fake := newFakeRPC(t, map[string]rpcHandler{})
+
+		_, err = actions.SendAndConfirm(context.Background(), fake.client(), &tx, signer, options)
+		var sendErr *actions.SendTransactionError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("expected a SendTransactionError, got %v", err)
+		}
+		if fake.count("sendTransaction") != 0 {
+			t.Error("invalid transactions shouldn't be sent")
+		}
+	})
+
+	t.Run("reports program errors", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{
+			"sendTransaction": func(json.RawMessage) (any, *rpc.JsonRpcError) { return "sig", nil },
+			"getSignatureStatuses": func(json.RawMessage) (any, *rpc.JsonRpcError) {
+				return withContext([]any{map[string]any{"slot": 7, "err": map[string]any{"InstructionError": []any{0, "Custom"}}}}), nil
+			},
+		})
+
+		result, err := actions.SendAndConfirm(context.Background(), fake.client(), newTx(), signer, options)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if result.Status != actions.SEND_STATUS_FAILED || result.Err == nil {
+			t.Errorf("got %s (%v) want %s with an error", result.Status, result.Err, actions.SEND_STATUS_FAILED)
+		}
+	})
+
+	t.Run("throws when the signer isn't part of the transaction", func(t *testing.T) {
+		fake := newFakeRPC(t, nil)
+		other := actions.NewKeypairSigner(types.NewAccount())
+
+		_, err := actions.SendAndConfirm(context.Background(), fake.client(), newTx(), other, options)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -0,0 +1,237 @@
+package actions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mr-tron/base58"
+)
+
+// Serves the GET and POST requests of an action
+type ActionProvider interface {
+	// Metadata of the action
+	GetAction(r *http.Request) (*ActionGetResponse, error)
+
+	// Transaction for the `account` of the request
+	PostAction(r *http.Request, req ActionPostRequest) (*ActionPostResponse, error)
+}
+
+/*
+Implemented by providers that return `post` next action links.
+
+It receives the callback a client sends once the transaction is confirmed
+and returns the next action to show.
+*/
+type NextActionProvider interface {
+	NextAction(r *http.Request, req NextActionPostRequest) (*ActionGetResponse, error)
+}
+
+/*
+Create an `http.Handler` serving an action with the standard CORS headers.
+
+The responses carry `X-Action-Version`, use `NewActionHandlerWithOptions` to
+also declare the clusters of the action.
+
+POST requests carrying a `signature` are routed to `NextAction` when the
+provider implements `NextActionProvider`. Signed messages sent with `data`
+are verified with `VerifySignMessage` against the `account`, the `Domain` and
+the `Nonces` of the options first, so they are rejected by a handler without
+them.
+
+Returning an `*ActionError` from the provider responds with 400 and its
+message, any other error responds with 500. Actions failing `Validate` and
+responses of an unknown `type` are not served. Bodies above
+`MAX_ACTION_REQUEST_BYTES` are rejected with 413.
+*/
+func NewActionHandler(provider ActionProvider) http.Handler {
+	// Without clusters the options can't fail
+	h, _ := NewActionHandlerWithOptions(provider, nil)
+	return h
+}
+
+// Options for `NewActionHandlerWithOptions`
+type ActionHandlerOptions struct {
+	// Clusters the transactions of the action are built for, sent as CAIP-2 IDs in `X-Blockchain-Ids`
+	Clusters []Cluster
+
+	// Version sent in `X-Action-Version`, defaults to `ACTIONS_SPEC_VERSION`
+	ActionVersion string
+
+	// Domain of the sign-message payloads issued by the provider
+	Domain string
+
+	// Store the provider issues the nonces of its sign-message payloads in
+	Nonces NonceStore
+}
+
+/*
+Create an `http.Handler` serving an action, see `NewActionHandler`.
+
+Clients check `X-Blockchain-Ids` against their RPC with `CheckBlockchainIds`,
+so a devnet action is refused by a wallet on mainnet.
+
+@param options - Handler options, may be nil.
+
+@throws {ClusterError} For clusters without a known genesis hash.
+*/
+func NewActionHandlerWithOptions(provider ActionProvider, options *ActionHandlerOptions) (http.Handler, error) {
+	if options == nil {
+		options = &ActionHandlerOptions{}
+	}
+	h := &actionHandler{provider: provider, headers: http.Header{}, domain: options.Domain, nonces: options.Nonces}
+	for key, value := range ACTIONS_CORS_HEADERS {
+		h.headers.Set(key, value)
+	}
+	version := options.ActionVersion
+	if version == "" {
+		version = ACTIONS_SPEC_VERSION
+	}
+	h.headers.Set(ACTION_VERSION_HEADER, version)
+	var ids []string
+	for _, cluster := range options.Clusters {
+		if cluster.GenesisHash() == "" {
+			return nil, &ClusterError{fmt.Sprintf("unknown cluster %q", cluster)}
+		}
+		ids = append(ids, cluster.BlockchainID())
+	}
+	if len(ids) > 0 {
+		h.headers.Set(BLOCKCHAIN_IDS_HEADER, strings.Join(ids, ","))
+	}
+	return h, nil
+}
+
+// Size above which a POST body is rejected, requests only carry an account and a callback
+const MAX_ACTION_REQUEST_BYTES = MAX_ACTION_RESPONSE_BYTES
+
+type actionHandler struct {
+	provider ActionProvider
+
+	// Set on every response
+	headers http.Header
+
+	// Checked against signed messages
+	domain string
+	nonces NonceStore
+}
+
+func (h *actionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for key, values := range h.headers {
+		w.Header()[key] = values
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		action, err := h.provider.GetAction(r)
+		writeActionResponse(w, action, validateAction(action, err))
+	case http.MethodPost:
+		var body NextActionPostRequest
+		r.Body = http.MaxBytesReader(w, r.Body, MAX_ACTION_REQUEST_BYTES)
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			var sizeErr *http.MaxBytesError
+			if errors.As(err, &sizeErr) {
+				writeActionError(w, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			writeActionError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if !isValidPublicKey(body.Account) {
+			writeActionError(w, http.StatusBadRequest, "invalid account")
+			return
+		}
+
+		if next, ok := h.provider.(NextActionProvider); ok && body.Signature != "" {
+			if body.Data != nil {
+				if err := VerifySignMessage(body.Data, body.Account, body.Signature, &VerifySignMessageOptions{Domain: h.domain, Nonces: h.nonces}); err != nil {
+					writeActionError(w, http.StatusBadRequest, "invalid message signature")
+					return
+				}
+			}
+			action, err := next.NextAction(r, body)
+			writeActionResponse(w, action, validateAction(action, err))
+			return
+		}
+		resp, err := h.provider.PostAction(r, ActionPostRequest{Account: body.Account})
+		writeActionResponse(w, resp, validatePostResponse(resp, err))
+	default:
+		writeActionError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// Links of a POST response showing `action` once the transaction is confirmed
+func NewInlineNextAction(action *ActionGetResponse) *ActionPostResponseLinks {
+	return &ActionPostResponseLinks{Next: NextActionLink{Type: NEXT_ACTION_LINK_INLINE, Action: action}}
+}
+
+// Links of a POST response that calls back `href` once the transaction is confirmed
+func NewPostNextAction(href string) *ActionPostResponseLinks {
+	return &ActionPostResponseLinks{Next: NextActionLink{Type: NEXT_ACTION_LINK_POST, Href: href}}
+}
+
+// Invalid actions returned by a provider are server errors, not user facing ones
+func validateAction(action *ActionGetResponse, err error) error {
+	if err != nil {
+		return err
+	}
+	if action == nil {
+		return &ActionValidationError{"provider returned no action"}
+	}
+	return action.Validate()
+}
+
+func validatePostResponse(resp *ActionPostResponse, err error) error {
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return &ActionValidationError{"provider returned no response"}
+	}
+	switch resp.ResponseType() {
+	case POST_RESPONSE_TYPE_TRANSACTION:
+		if resp.Transaction == "" {
+			return &ActionValidationError{"missing transaction"}
+		}
+	case POST_RESPONSE_TYPE_MESSAGE:
+		if resp.Data == nil {
+			return &SignMessageError{"missing data"}
+		}
+		if err := resp.Data.Validate(); err != nil {
+			return err
+		}
+	default:
+		return &ActionValidationError{fmt.Sprintf("invalid response type %q", resp.Type)}
+	}
+	if resp.Links != nil && resp.Links.Next.Action != nil {
+		return resp.Links.Next.Action.Validate()
+	}
+	return nil
+}
+
+func writeActionResponse(w http.ResponseWriter, payload any, err error) {
+	if err != nil {
+		var actionErr *ActionError
+		if errors.As(err, &actionErr) {
+			writeActionError(w, http.StatusBadRequest, actionErr.Message)
+			return
+		}
+		writeActionError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func writeActionError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&ActionError{Message: message})
+}
+
+func isValidPublicKey(s string) bool {
+	decoded, err := base58.Decode(s)
+	return err == nil && len(decoded) == 32
+}
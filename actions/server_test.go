@@ -0,0 +1,186 @@
+package actions_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"solana-actions/actions"
+	"strings"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Action provider of a two step flow: approve, then show a receipt
+type chainedProvider struct {
+	callbacks []actions.NextActionPostRequest
+}
+
+func (p *chainedProvider) GetAction(r *http.Request) (*actions.ActionGetResponse, error) {
+	if r.URL.Query().Get("empty") != "" {
+		return nil, nil
+	}
+	return &actions.ActionGetResponse{Icon: "https://example.com/icon.png", Title: "Approve", Description: "Approve the mint", Label: "Approve"}, nil
+}
+
+func (p *chainedProvider) PostAction(r *http.Request, req actions.ActionPostRequest) (*actions.ActionPostResponse, error) {
+	if r.URL.Query().Get("fail") != "" {
+		return nil, &actions.ActionError{Message: "sold out"}
+	}
+	switch r.URL.Query().Get("empty") {
+	case "response":
+		return nil, nil
+	case "transaction":
+		return &actions.ActionPostResponse{Message: &req.Account}, nil
+	case "type":
+		return &actions.ActionPostResponse{Type: "unknown", Transaction: "dHg="}, nil
+	}
+	return &actions.ActionPostResponse{Transaction: "dHg=", Links: actions.NewPostNextAction("/api/next")}, nil
+}
+
+func (p *chainedProvider) NextAction(r *http.Request, req actions.NextActionPostRequest) (*actions.ActionGetResponse, error) {
+	p.callbacks = append(p.callbacks, req)
+	return &actions.ActionGetResponse{Title: "Receipt", Description: "Minted", Label: "Done"}, nil
+}
+
+func TestActionHandler(t *testing.T) {
+	provider := &chainedProvider{}
+	server := httptest.NewServer(actions.NewActionHandler(provider))
+	defer server.Close()
+	account := types.NewAccount().PublicKey.String()
+
+	post := func(path string, body any) *http.Response {
+		t.Helper()
+		raw, _ := json.Marshal(body)
+		res, err := http.Post(server.URL+path, "application/json", bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		return res
+	}
+
+	t.Run("answers preflight requests with CORS headers", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodOptions, server.URL+"/api/action", nil)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		for key, value := range actions.ACTIONS_CORS_HEADERS {
+			if res.Header.Get(key) != value {
+				t.Errorf("%s: got %s want %s", key, res.Header.Get(key), value)
+			}
+		}
+	})
+
+	t.Run("serves the action metadata", func(t *testing.T) {
+		res, err := http.Get(server.URL + "/api/action")
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		var action map[string]any
+		json.NewDecoder(res.Body).Decode(&action)
+		if action["title"] != "Approve" {
+			t.Errorf("got %v want %s", action["title"], "Approve")
+		}
+	})
+
+	t.Run("returns the transaction with its next action", func(t *testing.T) {
+		res := post("/api/action", actions.ActionPostRequest{Account: account})
+		var resp actions.ActionPostResponse
+		json.NewDecoder(res.Body).Decode(&resp)
+		if resp.Links == nil || resp.Links.Next.Type != actions.NEXT_ACTION_LINK_POST || resp.Links.Next.Href != "/api/next" {
+			t.Errorf("expected a post next action, got %+v", resp.Links)
+		}
+	})
+
+	t.Run("rejects invalid accounts", func(t *testing.T) {
+		res := post("/api/action", actions.ActionPostRequest{Account: "not-a-key"})
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("got %d want %d", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("returns action errors to the user", func(t *testing.T) {
+		res := post("/api/action?fail=1", actions.ActionPostRequest{Account: account})
+		var actionErr actions.ActionError
+		json.NewDecoder(res.Body).Decode(&actionErr)
+		if res.StatusCode != http.StatusBadRequest || actionErr.Message != "sold out" {
+			t.Errorf("got %d %q want %d %q", res.StatusCode, actionErr.Message, http.StatusBadRequest, "sold out")
+		}
+	})
+
+	t.Run("answers 500 when the provider returns nothing", func(t *testing.T) {
+		res, err := http.Get(server.URL + "/api/action?empty=action")
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		responses := map[string]*http.Response{
+			"action":      res,
+			"response":    post("/api/action?empty=response", actions.ActionPostRequest{Account: account}),
+			"transaction": post("/api/action?empty=transaction", actions.ActionPostRequest{Account: account}),
+			"type":        post("/api/action?empty=type", actions.ActionPostRequest{Account: account}),
+		}
+		for name, res := range responses {
+			var actionErr actions.ActionError
+			json.NewDecoder(res.Body).Decode(&actionErr)
+			if res.StatusCode != http.StatusInternalServerError || actionErr.Message == "" {
+				t.Errorf("%s: got %d %q want %d with an error", name, res.StatusCode, actionErr.Message, http.StatusInternalServerError)
+			}
+		}
+	})
+
+	t.Run("rejects oversized bodies", func(t *testing.T) {
+		res := post("/api/action", map[string]string{"account": account, "padding": strings.Repeat("a", actions.MAX_ACTION_REQUEST_BYTES)})
+		if res.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("got %d want %d", res.StatusCode, http.StatusRequestEntityTooLarge)
+		}
+	})
+
+	t.Run("client follows post next actions", func(t *testing.T) {
+		link, _ := url.Parse(server.URL + "/api/action")
+		resp := &actions.ActionPostResponse{Transaction: "dHg=", Links: actions.NewPostNextAction("/api/next")}
+
+		next, err := actions.FetchNextAction(link, resp, actions.NextActionPostRequest{Account: account, Signature: "5sig"})
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if next.Title != "Receipt" {
+			t.Errorf("got %s want %s", next.Title, "Receipt")
+		}
+		if len(provider.callbacks) != 1 || provider.callbacks[0].Signature != "5sig" {
+			t.Errorf("expected the callback to carry the signature, got %v", provider.callbacks)
+		}
+	})
+
+	t.Run("client cancels next action callbacks with the context", func(t *testing.T) {
+		link, _ := url.Parse(server.URL + "/api/action")
+		resp := &actions.ActionPostResponse{Transaction: "dHg=", Links: actions.NewPostNextAction("/api/next")}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		callbacks := len(provider.callbacks)
+		if _, err := actions.FetchNextActionWithContext(ctx, link, resp, actions.NextActionPostRequest{Account: account, Signature: "5sig"}); err == nil {
+			t.Error("expected an error")
+		}
+		if len(provider.callbacks) != callbacks {
+			t.Error("expected the callback not to be sent")
+		}
+	})
+
+	t.Run("client returns inline next actions", func(t *testing.T) {
+		link, _ := url.Parse(server.URL + "/api/action")
+		inline := actions.NewCompletedAction("https://example.com/icon.png", "Inline", "Minted", "Done")
+		resp := &actions.ActionPostResponse{Transaction: "dHg=", Links: actions.NewInlineNextAction(inline)}
+
+		next, err := actions.FetchNextAction(link, resp, actions.NextActionPostRequest{Account: account})
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if next != inline {
+			t.Errorf("got %v want %v", next, inline)
+		}
+	})
+}
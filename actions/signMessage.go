@@ -0,0 +1,300 @@
+package actions
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/mr-tron/base58"
+)
+
+// Thrown when a sign-message payload is malformed or its signature doesn't verify
+type SignMessageError struct {
+	Message string
+}
+
+func (e *SignMessageError) Error() string {
+	return fmt.Sprintf("SignMessageError: %s", e.Message)
+}
+
+// How long a signed message is accepted when `SignMessageOptions.TTL` is zero
+const DEFAULT_SIGN_MESSAGE_TTL = 10 * time.Minute
+
+/*
+Tracks the nonces of issued payloads so each signed message is accepted once.
+
+Share the store between the provider issuing payloads and the handler
+verifying them.
+*/
+type NonceStore interface {
+	// Record a nonce handed out with a payload, accepted until `expiresAt`
+	Issue(nonce string, expiresAt time.Time) error
+
+	// Accept a nonce once, failing when it wasn't issued, expired or was already used
+	Consume(nonce string, now time.Time) error
+}
+
+// In-memory `NonceStore`, safe for concurrent use
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: map[string]time.Time{}}
+}
+
+func (s *MemoryNonceStore) Issue(nonce string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.nonces[nonce]; ok {
+		return &SignMessageError{"nonce already issued"}
+	}
+	s.nonces[nonce] = expiresAt
+	return nil
+}
+
+func (s *MemoryNonceStore) Consume(nonce string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Expired nonces can't be used anymore, drop them so the store doesn't grow
+	for issued, expiresAt := range s.nonces {
+		if now.After(expiresAt) && issued != nonce {
+			delete(s.nonces, issued)
+		}
+	}
+	expiresAt, ok := s.nonces[nonce]
+	if !ok {
+		return &SignMessageError{"unknown nonce"}
+	}
+	delete(s.nonces, nonce)
+	if now.After(expiresAt) {
+		return &SignMessageError{"nonce expired"}
+	}
+	return nil
+}
+
+// Options for `NewSignMessageData`
+type SignMessageOptions struct {
+	// Human readable statement shown to the user
+	Statement string
+
+	// How long the signature is accepted, defaults to `DEFAULT_SIGN_MESSAGE_TTL`
+	TTL time.Duration
+
+	// Records the nonce of the payload for `VerifySignMessage`
+	Nonces NonceStore
+
+	// Clock used for `issuedAt`, defaults to `time.Now`
+	Now func() time.Time
+}
+
+/*
+Create the payload of a `message` action with a random nonce.
+
+The nonce is recorded in `options.Nonces` until the payload expires.
+
+@param domain - Host of the action, e.g. `example.com`.
+
+@param account - `account` of the Action POST request.
+
+@param options - Message options, may be nil.
+
+@throws {SignMessageError}
+*/
+func NewSignMessageData(domain string, account common.PublicKey, options *SignMessageOptions) (*SignMessageData, error) {
+	if options == nil {
+		options = &SignMessageOptions{}
+	}
+	if domain == "" {
+		return nil, &SignMessageError{"missing domain"}
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, &SignMessageError{err.Error()}
+	}
+	now := time.Now
+	if options.Now != nil {
+		now = options.Now
+	}
+	issuedAt := now().UTC()
+	ttl := options.TTL
+	if ttl <= 0 {
+		ttl = DEFAULT_SIGN_MESSAGE_TTL
+	}
+	expiresAt := issuedAt.Add(ttl)
+
+	data := &SignMessageData{
+		Domain:         domain,
+		Address:        account.String(),
+		Statement:      options.Statement,
+		Nonce:          base58.Encode(nonce),
+		IssuedAt:       issuedAt.Format(time.RFC3339),
+		ExpirationTime: expiresAt.Format(time.RFC3339),
+	}
+	if options.Nonces != nil {
+		if err := options.Nonces.Issue(data.Nonce, expiresAt); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// Creates a `message` Action POST response for `data`
+func NewSignMessageResponse(data *SignMessageData, links *ActionPostResponseLinks) *ActionPostResponse {
+	return &ActionPostResponse{Type: POST_RESPONSE_TYPE_MESSAGE, Data: data, Links: links}
+}
+
+// What the response asks the user to sign, `POST_RESPONSE_TYPE_TRANSACTION` when omitted
+func (r *ActionPostResponse) ResponseType() ActionPostResponseType {
+	if r.Type == "" {
+		return POST_RESPONSE_TYPE_TRANSACTION
+	}
+	return r.Type
+}
+
+/*
+Check the required fields and timestamps of the payload.
+
+@throws {SignMessageError}
+*/
+func (d *SignMessageData) Validate() error {
+	if d.Domain == "" {
+		return &SignMessageError{"missing domain"}
+	}
+	if !isValidPublicKey(d.Address) {
+		return &SignMessageError{"invalid address"}
+	}
+	if d.Nonce == "" {
+		return &SignMessageError{"missing nonce"}
+	}
+	if _, err := time.Parse(time.RFC3339, d.IssuedAt); err != nil {
+		return &SignMessageError{"invalid issuedAt"}
+	}
+	if d.ExpirationTime != "" {
+		if _, err := time.Parse(time.RFC3339, d.ExpirationTime); err != nil {
+			return &SignMessageError{"invalid expirationTime"}
+		}
+	}
+	return nil
+}
+
+/*
+Text of the payload the user signs.
+
+Every field is on its own line so a wallet can show the message as is.
+*/
+func (d *SignMessageData) Message() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s wants you to sign with your Solana account:\n%s\n", d.Domain, d.Address)
+	if d.Statement != "" {
+		fmt.Fprintf(&b, "\n%s\n", d.Statement)
+	}
+	fmt.Fprintf(&b, "\nNonce: %s\nIssued At: %s", d.Nonce, d.IssuedAt)
+	if d.ExpirationTime != "" {
+		fmt.Fprintf(&b, "\nExpiration Time: %s", d.ExpirationTime)
+	}
+	return b.String()
+}
+
+/*
+Sign the payload of a `message` action.
+
+Returns the base58 encoded signature to POST back with the payload.
+
+@throws {SignMessageError}
+*/
+func SignMessage(data *SignMessageData, signer Signer) (string, error) {
+	if data.Address != signer.PublicKey().String() {
+		return "", &SignMessageError{"message address does not match the signer"}
+	}
+	sig, err := signer.Sign([]byte(data.Message()))
+	if err != nil {
+		return "", &SignMessageError{err.Error()}
+	}
+	return base58.Encode(sig), nil
+}
+
+// Options for `VerifySignMessage`, the domain and either nonce check are required
+type VerifySignMessageOptions struct {
+	// Expected domain
+	Domain string
+
+	// Nonce issued with the payload, when the caller kept it
+	Nonce string
+
+	// Store the nonce was issued in, it is consumed so the signature can't be replayed
+	Nonces NonceStore
+
+	// Clock used for the expiration, defaults to `time.Now`
+	Now func() time.Time
+}
+
+/*
+Verify the ed25519 signature of a payload against the POST `account`.
+
+Payloads are rejected unless they carry an expiration, and verification fails
+without an expected domain and a nonce or a `NonceStore` to check the payload
+against. The nonce is consumed only once the signature verifies.
+
+@param data - Signed payload sent back by the client.
+
+@param account - `account` of the POST request.
+
+@param signature - Base58 encoded signature.
+
+@param options - Verify options, may be nil.
+
+@throws {SignMessageError}
+*/
+func VerifySignMessage(data *SignMessageData, account string, signature string, options *VerifySignMessageOptions) error {
+	if options == nil || options.Domain == "" {
+		return &SignMessageError{"missing expected domain"}
+	}
+	if options.Nonce == "" && options.Nonces == nil {
+		return &SignMessageError{"missing expected nonce"}
+	}
+	if data == nil {
+		return &SignMessageError{"missing data"}
+	}
+	if err := data.Validate(); err != nil {
+		return err
+	}
+	if data.Address != account {
+		return &SignMessageError{"message address does not match the account"}
+	}
+	if data.Domain != options.Domain {
+		return &SignMessageError{fmt.Sprintf("unexpected domain %s", data.Domain)}
+	}
+	if options.Nonce != "" && data.Nonce != options.Nonce {
+		return &SignMessageError{"unexpected nonce"}
+	}
+	if data.ExpirationTime == "" {
+		return &SignMessageError{"missing expirationTime"}
+	}
+	now := time.Now
+	if options.Now != nil {
+		now = options.Now
+	}
+	expiration, _ := time.Parse(time.RFC3339, data.ExpirationTime)
+	if now().After(expiration) {
+		return &SignMessageError{"message expired"}
+	}
+
+	sig, err := base58.Decode(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return &SignMessageError{"invalid signature"}
+	}
+	publicKey := common.PublicKeyFromString(account)
+	if !ed25519.Verify(publicKey.Bytes(), []byte(data.Message()), sig) {
+		return &SignMessageError{"invalid signature"}
+	}
+	if options.Nonces != nil {
+		return options.Nonces.Consume(data.Nonce, now())
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package actions_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"solana-actions/actions"
+	"testing"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Action provider of a login flow: sign a message, then show the account
+type loginProvider struct {
+	nonces *actions.MemoryNonceStore
+	signed []actions.NextActionPostRequest
+}
+
+func (p *loginProvider) GetAction(r *http.Request) (*actions.ActionGetResponse, error) {
+	return actions.NewAction("https://example.com/icon.png", "Login", "Sign in with Solana", "Sign in"), nil
+}
+
+func (p *loginProvider) PostAction(r *http.Request, req actions.ActionPostRequest) (*actions.ActionPostResponse, error) {
+	data, err := actions.NewSignMessageData("example.com", common.PublicKeyFromString(req.Account), &actions.SignMessageOptions{TTL: time.Minute, Nonces: p.nonces})
+	if err != nil {
+		return nil, err
+	}
+	return actions.NewSignMessageResponse(data, actions.NewPostNextAction("/api/next")), nil
+}
+
+func (p *loginProvider) NextAction(r *http.Request, req actions.NextActionPostRequest) (*actions.ActionGetResponse, error) {
+	p.signed = append(p.signed, req)
+	return actions.NewCompletedAction("https://example.com/icon.png", "Signed in", req.Account, "Done"), nil
+}
+
+func TestSignMessage(t *testing.T) {
+	user := types.NewAccount()
+	signer := actions.NewKeypairSigner(user)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	data, err := actions.NewSignMessageData("example.com", user.PublicKey, &actions.SignMessageOptions{
+		Statement: "Sign in to Example",
+		TTL:       5 * time.Minute,
+		Now:       func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+
+	t.Run("generates a payload for the account", func(t *testing.T) {
+		if data.Address != user.PublicKey.String() || data.Nonce == "" {
+			t.Errorf("unexpected payload %+v", data)
+		}
+		if data.IssuedAt != "2024-06-01T12:00:00Z" || data.ExpirationTime != "2024-06-01T12:05:00Z" {
+			t.Errorf("got %s - %s want 2024-06-01T12:00:00Z - 2024-06-01T12:05:00Z", data.IssuedAt, data.ExpirationTime)
+		}
+	})
+
+	t.Run("expires payloads by default", func(t *testing.T) {
+		data, err := actions.NewSignMessageData("example.com", user.PublicKey, &actions.SignMessageOptions{Now: func() time.Time { return now }})
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		want := now.Add(actions.DEFAULT_SIGN_MESSAGE_TTL).Format(time.RFC3339)
+		if data.ExpirationTime != want {
+			t.Errorf("got %s want %s", data.ExpirationTime, want)
+		}
+	})
+
+	t.Run("verifies signed payloads", func(t *testing.T) {
+		sig, err := actions.SignMessage(data, signer)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		options := &actions.VerifySignMessageOptions{Domain: "example.com", Nonce: data.Nonce, Now: func() time.Time { return now }}
+		if err := actions.VerifySignMessage(data, user.PublicKey.String(), sig, options); err != nil {
+			t.Errorf("err should be nil: %s", err.Error())
+		}
+
+		other := types.NewAccount().PublicKey.String()
+		if err := actions.VerifySignMessage(data, other, sig, options); err == nil {
+			t.Error("expected an error for another account")
+		}
+
+		tampered := *data
+		tampered.Statement = "Transfer everything"
+		if err := actions.VerifySignMessage(&tampered, user.PublicKey.String(), sig, options); err == nil {
+			t.Error("expected an error for a tampered payload")
+		}
+
+		wrongDomain := &actions.VerifySignMessageOptions{Domain: "evil.com", Now: options.Now}
+		if err := actions.VerifySignMessage(data, user.PublicKey.String(), sig, wrongDomain); err == nil {
+			t.Error("expected an error for another domain")
+		}
+	})
+
+	t.Run("rejects expired payloads", func(t *testing.T) {
+		sig, _ := actions.SignMessage(data, signer)
+		later := &actions.VerifySignMessageOptions{Domain: "example.com", Nonce: data.Nonce, Now: func() time.Time { return now.Add(time.Hour) }}
+		if err := actions.VerifySignMessage(data, user.PublicKey.String(), sig, later); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("fails without a domain or nonce to check", func(t *testing.T) {
+		sig, _ := actions.SignMessage(data, signer)
+		clock := func() time.Time { return now }
+		for name, options := range map[string]*actions.VerifySignMessageOptions{
+			"nil":       nil,
+			"no domain": {Nonce: data.Nonce, Now: clock},
+			"no nonce":  {Domain: "example.com", Now: clock},
+		} {
+			if err := actions.VerifySignMessage(data, user.PublicKey.String(), sig, options); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+	})
+
+	t.Run("accepts each issued nonce once", func(t *testing.T) {
+		nonces := actions.NewMemoryNonceStore()
+		clock := func() time.Time { return now }
+		issued, err := actions.NewSignMessageData("example.com", user.PublicKey, &actions.SignMessageOptions{Nonces: nonces, Now: clock})
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		sig, _ := actions.SignMessage(issued, signer)
+		options := &actions.VerifySignMessageOptions{Domain: "example.com", Nonces: nonces, Now: clock}
+		if err := actions.VerifySignMessage(issued, user.PublicKey.String(), sig, options); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if err := actions.VerifySignMessage(issued, user.PublicKey.String(), sig, options); err == nil {
+			t.Error("expected an error for a replayed message")
+		}
+
+		// Payloads signed by the user but not issued by the store are rejected
+		unissued, _ := actions.SignMessage(data, signer)
+		if err := actions.VerifySignMessage(data, user.PublicKey.String(), unissued, options); err == nil {
+			t.Error("expected an error for an unknown nonce")
+		}
+	})
+
+	t.Run("only signs payloads of the signer", func(t *testing.T) {
+		if _, err := actions.SignMessage(data, actions.NewKeypairSigner(types.NewAccount())); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestMessageActions(t *testing.T) {
+	provider := &loginProvider{nonces: actions.NewMemoryNonceStore()}
+	handler, err := actions.NewActionHandlerWithOptions(provider, &actions.ActionHandlerOptions{Domain: "example.com", Nonces: provider.nonces})
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	user := types.NewAccount()
+	link, _ := url.Parse(server.URL + "/api/action")
+
+	t.Run("fetch routes message actions away from SerializeTransaction", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{})
+		resp, err := actions.FetchTransaction(fake.client(), link, actions.ActionPostRequest{Account: user.PublicKey.String()}, "")
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if resp.ResponseType() != actions.POST_RESPONSE_TYPE_MESSAGE || resp.Data == nil {
+			t.Fatalf("expected a message response, got %+v", resp.ActionPostResponse)
+		}
+		if resp.Data.Address != user.PublicKey.String() {
+			t.Errorf("got %s want %s", resp.Data.Address, user.PublicKey.String())
+		}
+		if fake.count("getLatestBlockhash") != 0 {
+			t.Error("expected no blockhash to be fetched")
+		}
+	})
+
+	t.Run("handler verifies signed messages before the next action", func(t *testing.T) {
+		fake := newFakeRPC(t, map[string]rpcHandler{})
+		resp, err := actions.FetchTransaction(fake.client(), link, actions.ActionPostRequest{Account: user.PublicKey.String()}, "")
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		sig, err := actions.SignMessage(resp.Data, actions.NewKeypairSigner(user))
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+
+		callback := actions.NextActionPostRequest{Account: user.PublicKey.String(), Signature: sig, Data: resp.Data}
+		next, err := actions.FetchNextAction(link, &resp.ActionPostResponse, callback)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if next.ActionType() != actions.ACTION_TYPE_COMPLETED || len(provider.signed) != 1 {
+			t.Errorf("expected the provider to receive the signed message, got %+v", next)
+		}
+
+		forged := *resp.Data
+		forged.Nonce = "forged"
+		raw, _ := json.Marshal(actions.NextActionPostRequest{Account: user.PublicKey.String(), Signature: sig, Data: &forged})
+		res, err := http.Post(server.URL+"/api/next", "application/json", bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if res.StatusCode != http.StatusBadRequest || len(provider.signed) != 1 {
+			t.Errorf("got %d want %d", res.StatusCode, http.StatusBadRequest)
+		}
+
+		raw, _ = json.Marshal(callback)
+		res, err = http.Post(server.URL+"/api/next", "application/json", bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if res.StatusCode != http.StatusBadRequest || len(provider.signed) != 1 {
+			t.Errorf("replayed message: got %d want %d", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package actions_test
+
+import (
+	"net/url"
+	"solana-actions/actions"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+func TestTransferRequestURL(t *testing.T) {
+	recipient := types.NewAccount().PublicKey
+	mint := types.NewAccount().PublicKey
+	reference := actions.Reference(types.NewAccount().PublicKey)
+
+	t.Run("parses transfer request URLs", func(t *testing.T) {
+		raw := "solana:" + recipient.String() + "?amount=0.5&spl-token=" + mint.String() + "&reference=" + reference.String() + "&label=Coffee%20Shop&memo=order-1"
+		link, _ := url.Parse(raw)
+		fields, err := actions.ParseURL(link)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		transfer, ok := fields.(*actions.TransferRequestURLFields)
+		if !ok {
+			t.Fatalf("expected transfer request fields, got %T", fields)
+		}
+		if transfer.Recipient != recipient || transfer.Amount.String() != "0.5" || *transfer.SplToken != mint || len(transfer.Reference) != 1 || transfer.Reference[0] != reference {
+			t.Errorf("unexpected fields %+v", transfer)
+		}
+		if *transfer.Label != "Coffee Shop" || *transfer.Memo != "order-1" || transfer.Message != nil {
+			t.Errorf("unexpected fields %+v", transfer)
+		}
+	})
+
+	t.Run("rejects invalid transfer request URLs", func(t *testing.T) {
+		for _, raw := range []string{
+			"solana:not-a-key",
+			"solana:" + recipient.String() + "?amount=-1",
+			"solana:" + recipient.String() + "?amount=1e9",
+			"solana:" + recipient.String() + "?reference=nope",
+		} {
+			link, _ := url.Parse(raw)
+			if _, err := actions.ParseURL(link); err == nil {
+				t.Errorf("expected an error for %s", raw)
+			}
+		}
+	})
+
+	t.Run("encodes transfer request URLs in spec order", func(t *testing.T) {
+		amount, _ := actions.ParseAmount("1.25")
+		label := "Coffee Shop"
+		URL, err := actions.EncodeUrl(&actions.TransferRequestURLFields{
+			Recipient: recipient,
+			Amount:    &amount,
+			Reference: []actions.Reference{reference},
+			Label:     &label,
+		}, actions.SOLANA_PAY_PROTOCOL)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		expected := "solana:" + recipient.String() + "?amount=1.25&reference=" + reference.String() + "&label=Coffee%20Shop"
+		if URL.String() != expected {
+			t.Errorf("got %s want %s", URL.String(), expected)
+		}
+
+		parsed, err := actions.ParseURL(URL)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if *parsed.(*actions.TransferRequestURLFields).Label != label {
+			t.Errorf("got %s want %s", *parsed.(*actions.TransferRequestURLFields).Label, label)
+		}
+	})
+
+	t.Run("round trips several references", func(t *testing.T) {
+		merchant := actions.Reference(types.NewAccount().PublicKey)
+		URL, err := actions.EncodeUrl(&actions.TransferRequestURLFields{
+			Recipient: recipient,
+			Reference: []actions.Reference{reference, merchant},
+		}, actions.SOLANA_PAY_PROTOCOL)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		expected := "solana:" + recipient.String() + "?reference=" + reference.String() + "&reference=" + merchant.String()
+		if URL.String() != expected {
+			t.Errorf("got %s want %s", URL.String(), expected)
+		}
+		parsed, err := actions.ParseURL(URL)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		references := parsed.(*actions.TransferRequestURLFields).Reference
+		if len(references) != 2 || references[0] != reference || references[1] != merchant {
+			t.Errorf("unexpected references %v", references)
+		}
+	})
+
+	t.Run("only encodes with the solana protocol", func(t *testing.T) {
+		if _, err := actions.EncodeUrl(&actions.TransferRequestURLFields{Recipient: recipient}, actions.SOLANA_ACTIONS_PROTOCOL); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
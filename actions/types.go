@@ -43,10 +43,11 @@ func (sp SupportedProtocol) IsValidProtocol() bool {
 Standard headers
 */
 var ACTIONS_CORS_HEADERS = map[string]string{
-	"Access-Control-Allow-Origin":  "*",
-	"Access-Control-Allow-Methods": "GET, POST, PUT, OPTIONS",
-	"Access-Control-Allow-Headers": "Content-Type, Authorization, Content-Encoding, Accept-Encoding",
-	"Content-Type":                 "application/json",
+	"Access-Control-Allow-Origin":   "*",
+	"Access-Control-Allow-Methods":  "GET, POST, PUT, OPTIONS",
+	"Access-Control-Allow-Headers":  "Content-Type, Authorization, Content-Encoding, Accept-Encoding",
+	"Access-Control-Expose-Headers": "X-Action-Version, X-Blockchain-Ids",
+	"Content-Type":                  "application/json",
 }
 
 // `reference` in the [Solana Actions spec](https://github.com/solana-labs/solana-pay/blob/master/SPEC.md#reference)
@@ -57,19 +58,35 @@ func (ref Reference) String() string {
 
 }
 
+func (ref Reference) MarshalText() ([]byte, error) {
+	return []byte(ref.String()), nil
+}
+
+func (ref *Reference) UnmarshalText(text []byte) error {
+	key, err := parsePublicKey(string(text))
+	if err != nil {
+		return err
+	}
+	*ref = Reference(key)
+	return nil
+}
+
 // `memo` in the [Solana Actions spec](https://github.com/solana-labs/solana-pay/blob/master/SPEC.md#memo)
 type Memo string
 
 type ActionsJson struct {
-	Rules []ActionRuleObject
+	Rules []ActionRuleObject `json:"rules"`
+
+	//base58 public key signing the Action Identity memo of the provider's transactions (optional)
+	Identity string `json:"identity,omitempty"`
 }
 
 type ActionRuleObject struct {
 	//relative (preferred) or absolute path to perform the rule mapping from
-	PathPattern string
+	PathPattern string `json:"pathPattern"`
 
 	//relative (preferred) or absolute path that supports Action requests
-	ApiPath string
+	ApiPath string `json:"apiPath"`
 }
 
 /*
@@ -86,6 +103,32 @@ type ActionRequestURLFields struct {
 	Message *string `json:"message,omitempty"`
 }
 
+/*
+Fields of a Solana Pay transfer request URL.
+*/
+type TransferRequestURLFields struct {
+	//`recipient` in the Solana Pay spec
+	Recipient common.PublicKey `json:"recipient"`
+
+	//`amount` in the Solana Pay spec, decimal amount of SOL or of `SplToken` (optional)
+	Amount *Amount `json:"amount,omitempty"`
+
+	//`spl-token` in the Solana Pay spec, mint of the transferred token (optional)
+	SplToken *common.PublicKey `json:"splToken,omitempty"`
+
+	//`reference` in the Solana Pay spec, a URL may carry several (optional)
+	Reference []Reference `json:"reference,omitempty"`
+
+	//`label` in the Solana Pay spec
+	Label *string `json:"label,omitempty"`
+
+	//`message` in the Solana Pay spec
+	Message *string `json:"message,omitempty"`
+
+	//`memo` in the Solana Pay spec
+	Memo *string `json:"memo,omitempty"`
+}
+
 /**
  * Fields of a blink URL to support a Solana Action.
  */
@@ -104,32 +147,52 @@ type BlinkURLFields struct {
  */
 type ActionGetRequest struct{}
 
+// Type of an `ActionGetResponse`
+type ActionType string
+
+const (
+	// Interactive action, the default when `type` is omitted
+	ACTION_TYPE_ACTION ActionType = "action"
+
+	// Terminal state of an action chain, rendered disabled with its success message
+	ACTION_TYPE_COMPLETED ActionType = "completed"
+
+	// Action sending the user to `externalLink` once its transaction is signed
+	ACTION_TYPE_EXTERNAL_LINK ActionType = "external-link"
+)
+
 /**
  * Response body payload returned from the Action GET Request
  */
 type ActionGetResponse struct {
+	//type of the action, defaults to `action`
+	Type ActionType `json:"type,omitempty"`
+
 	//image url that represents the source of the action request
-	Icon string
+	Icon string `json:"icon"`
 
 	//describes the source of the action request
-	Title string
+	Title string `json:"title"`
 
 	//brief summary of the action to be performed
-	Description string
+	Description string `json:"description"`
 
 	//button text rendered to the user
-	Label string
+	Label string `json:"label"`
 
 	//UI state for the button being rendered to the user
-	Disabled *bool
+	Disabled *bool `json:"disabled,omitempty"`
 
 	Links *struct {
 		//list of related Actions a user could perform
-		Actions []LinkedAction
-	}
+		Actions []LinkedAction `json:"actions"`
+	} `json:"links,omitempty"`
 
 	//non-fatal error message to be displayed to the user
-	Error *ActionError
+	Error *ActionError `json:"error,omitempty"`
+
+	//URL the user is sent to after signing an `external-link` action
+	ExternalLink string `json:"externalLink,omitempty"`
 }
 
 /**
@@ -137,10 +200,10 @@ type ActionGetResponse struct {
  */
 type LinkedAction struct {
 	//URL endpoint for an action
-	Href string
+	Href string `json:"href"`
 
 	//button text rendered to the user
-	Label string
+	Label string `json:"label"`
 
 	//parameters used to accept user input within an action
 	Parameters *[]ActionParameter `json:"parameters,omitempty"`
@@ -162,12 +225,81 @@ type ActionPostRequest struct {
 	Account string `json:"account"`
 }
 
+// What an Action POST response asks the user to sign
+type ActionPostResponseType string
+
+const (
+	// The response carries a `transaction`, the default when `type` is omitted
+	POST_RESPONSE_TYPE_TRANSACTION ActionPostResponseType = "transaction"
+
+	// The response carries an off-chain message in `data`
+	POST_RESPONSE_TYPE_MESSAGE ActionPostResponseType = "message"
+)
+
 // Response body payload returned from the Action POST Request
 type ActionPostResponse struct {
+	// What the user is asked to sign, defaults to `transaction` (optional)
+	Type ActionPostResponseType `json:"type,omitempty"`
 	// Base64 encoded serialized transaction
-	Transaction string `json:"transaction"`
+	Transaction string `json:"transaction,omitempty"`
+	// Off-chain message to sign when `type` is `message`
+	Data *SignMessageData `json:"data,omitempty"`
 	// Describes the nature of the transaction (optional)
 	Message *string `json:"message,omitempty"`
+	// Next action to show once the transaction is confirmed (optional)
+	Links *ActionPostResponseLinks `json:"links,omitempty"`
+}
+
+// Structured off-chain message of a `message` Action POST response
+type SignMessageData struct {
+	// Host of the action requesting the signature
+	Domain string `json:"domain"`
+	// Base58-encoded public key of the account that signs the message
+	Address string `json:"address"`
+	// Human readable statement shown to the user
+	Statement string `json:"statement"`
+	// Random value preventing replays of the signature
+	Nonce string `json:"nonce"`
+	// ISO 8601 time at which the message was issued
+	IssuedAt string `json:"issuedAt"`
+	// ISO 8601 time after which the signature is rejected (optional)
+	ExpirationTime string `json:"expirationTime,omitempty"`
+}
+
+// Links of an Action POST response
+type ActionPostResponseLinks struct {
+	// Next action in a chain of actions
+	Next NextActionLink `json:"next"`
+}
+
+// How the next action of a chain is delivered
+type NextActionLinkType string
+
+const (
+	// The next action is fetched by POSTing a `NextActionPostRequest` to `href`
+	NEXT_ACTION_LINK_POST NextActionLinkType = "post"
+
+	// The next action is embedded in the link
+	NEXT_ACTION_LINK_INLINE NextActionLinkType = "inline"
+)
+
+// Next action to show once the transaction of an action is confirmed
+type NextActionLink struct {
+	Type NextActionLinkType `json:"type"`
+	// Callback endpoint of a `post` link, relative to the action URL or absolute
+	Href string `json:"href,omitempty"`
+	// Next action of an `inline` link
+	Action *ActionGetResponse `json:"action,omitempty"`
+}
+
+// Request body POSTed to a `post` next action link after the transaction is confirmed
+type NextActionPostRequest struct {
+	// Base58-encoded public key of the account that signed the transaction
+	Account string `json:"account"`
+	// Base58-encoded signature of the confirmed transaction or of the signed message
+	Signature string `json:"signature"`
+	// Signed message of a `message` action (optional)
+	Data *SignMessageData `json:"data,omitempty"`
 }
 
 // Non-fatal error message to be displayed to the user
@@ -175,3 +307,8 @@ type ActionError struct {
 	// Non-fatal error message to be displayed to the user
 	Message string `json:"message"`
 }
+
+// Action handlers return an `*ActionError` to show its message to the user
+func (e *ActionError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,240 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Thrown when a blink can't be unfurled
+type UnfurlError struct {
+	Message string
+}
+
+func (e *UnfurlError) Error() string {
+	return fmt.Sprintf("UnfurlError: %s", e.Message)
+}
+
+// Button of an unfurled blink
+type unfurlButton struct {
+	Label    string
+	Href     string
+	Disabled bool
+}
+
+type unfurlPage struct {
+	Title       string
+	Description string
+	Icon        string
+	BlinkURL    string
+	ActionURL   string
+	SiteName    string
+	Error       string
+	Buttons     []unfurlButton
+}
+
+// Provider supplied strings are only ever interpolated by html/template, which escapes them per context
+var unfurlTemplate = template.Must(template.New("unfurl").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<meta name="description" content="{{.Description}}">
+<meta property="og:type" content="website">
+<meta property="og:site_name" content="{{.SiteName}}">
+<meta property="og:url" content="{{.BlinkURL}}">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+{{- if .Icon}}
+<meta property="og:image" content="{{.Icon}}">
+{{- end}}
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+{{- if .Icon}}
+<meta name="twitter:image" content="{{.Icon}}">
+{{- end}}
+<meta name="solana-action" content="{{.ActionURL}}">
+<link rel="canonical" href="{{.BlinkURL}}">
+</head>
+<body>
+<main class="blink">
+{{- if .Icon}}
+<img class="blink-icon" src="{{.Icon}}" alt="{{.Title}}">
+{{- end}}
+<h1 class="blink-title">{{.Title}}</h1>
+<p class="blink-description">{{.Description}}</p>
+{{- if .Error}}
+<p class="blink-error">{{.Error}}</p>
+{{- end}}
+<nav class="blink-actions">
+{{- range .Buttons}}
+{{- if .Disabled}}
+<span class="blink-button" aria-disabled="true">{{.Label}}</span>
+{{- else}}
+<a class="blink-button" href="{{.Href}}">{{.Label}}</a>
+{{- end}}
+{{- end}}
+</nav>
+</main>
+</body>
+</html>
+`))
+
+/*
+Render a blink as a standalone HTML page with Open Graph, Twitter card and
+`solana-action` meta tags.
+
+Every linked action is rendered as a button opening the blink of its href,
+actions without links get a single button with their label.
+
+@param w - Destination of the page.
+
+@param action - Metadata of the action.
+
+@param blink - Blink of the action.
+
+@throws {UnfurlError}
+*/
+func RenderUnfurl(w io.Writer, action *ActionGetResponse, blink *BlinkURLFields) error {
+	link := blink.Action.Link
+	if link == nil || blink.Blink == nil {
+		return &UnfurlError{"blink missing link"}
+	}
+	page := unfurlPage{
+		Title:       action.Title,
+		Description: action.Description,
+		BlinkURL:    blinkURLFor(blink.Blink, link),
+		ActionURL:   fmt.Sprintf("%s:%s", SOLANA_ACTIONS_PROTOCOL, link),
+		SiteName:    blink.Blink.Hostname(),
+	}
+	page.Icon = resolveIconURL(link, action.Icon)
+	if action.Error != nil {
+		page.Error = action.Error.Message
+	}
+
+	disabled := !action.IsInteractive()
+	if action.Links != nil && len(action.Links.Actions) > 0 {
+		for _, linked := range action.Links.Actions {
+			href, err := link.Parse(linked.Href)
+			if err != nil {
+				return &UnfurlError{fmt.Sprintf("invalid linked action href %q", linked.Href)}
+			}
+			page.Buttons = append(page.Buttons, unfurlButton{Label: linked.Label, Href: blinkURLFor(blink.Blink, href), Disabled: disabled})
+		}
+	} else {
+		page.Buttons = []unfurlButton{{Label: action.Label, Href: page.BlinkURL, Disabled: disabled}}
+	}
+
+	if err := unfurlTemplate.Execute(w, page); err != nil {
+		return &UnfurlError{err.Error()}
+	}
+	return nil
+}
+
+/*
+Absolute URL of an action icon, resolved against the action link so relative
+icons are kept. Empty unless it is an http(s) URL.
+*/
+func resolveIconURL(link *url.URL, icon string) string {
+	if icon == "" {
+		return ""
+	}
+	resolved, err := link.Parse(icon)
+	if err != nil || (resolved.Scheme != HTTPS_PROTOCOL && resolved.Scheme != "http") {
+		return ""
+	}
+	return resolved.String()
+}
+
+// Blink URL on the host of `blink` opening the action at `link`
+func blinkURLFor(blink *url.URL, link *url.URL) string {
+	result := *blink
+	query := result.Query()
+	query.Set(BLINKS_QUERY_PARAM, fmt.Sprintf("%s:%s", SOLANA_ACTIONS_PROTOCOL, link))
+	result.RawQuery = query.Encode()
+	return result.String()
+}
+
+// Options for `NewUnfurlHandler`
+type UnfurlHandlerOptions struct {
+	// Scheme and host of the blink URLs, defaults to the host of the request
+	BaseURL *url.URL
+
+	// Run in order on the action link before it is fetched, e.g. a `Registry`
+	URLCheckers []ActionURLChecker
+}
+
+/*
+Create an `http.Handler` unfurling the blink of the request.
+
+The action is read from the `action` query param and its metadata is fetched
+on every request. Malformed blinks respond with 400, actions refused by a URL
+checker with 403 and actions that can't be fetched with 502.
+*/
+func NewUnfurlHandler(options *UnfurlHandlerOptions) http.Handler {
+	if options == nil {
+		options = &UnfurlHandlerOptions{}
+	}
+	return &unfurlHandler{options}
+}
+
+type unfurlHandler struct {
+	options *UnfurlHandlerOptions
+}
+
+func (h *unfurlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	fields, err := parseRequestBlink(r, h.options.BaseURL)
+	if err != nil {
+		http.Error(w, "invalid blink", http.StatusBadRequest)
+		return
+	}
+
+	action, err := FetchActionWithOptions(r.Context(), fields.Action.Link, &FetchActionOptions{URLCheckers: h.options.URLCheckers})
+	if err != nil {
+		var fetchErr *FetchActionError
+		if errors.As(err, &fetchErr) {
+			http.Error(w, "action unavailable", http.StatusBadGateway)
+			return
+		}
+		http.Error(w, "action blocked", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := RenderUnfurl(w, action, fields); err != nil {
+		http.Error(w, "action unavailable", http.StatusBadGateway)
+	}
+}
+
+// Blink fields of a request served on the blink host
+func parseRequestBlink(r *http.Request, baseURL *url.URL) (*BlinkURLFields, error) {
+	blink := *r.URL
+	if baseURL != nil {
+		blink.Scheme = baseURL.Scheme
+		blink.Host = baseURL.Host
+	} else {
+		blink.Scheme = "http"
+		if r.TLS != nil {
+			blink.Scheme = HTTPS_PROTOCOL
+		}
+		blink.Host = r.Host
+	}
+	fields, err := ParseURL(&blink)
+	if err != nil {
+		return nil, err
+	}
+	blinkFields, ok := fields.(*BlinkURLFields)
+	if !ok {
+		return nil, &UnfurlError{"not a blink"}
+	}
+	return blinkFields, nil
+}
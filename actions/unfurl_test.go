@@ -0,0 +1,114 @@
+package actions_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"solana-actions/actions"
+	"strings"
+	"testing"
+)
+
+func TestUnfurl(t *testing.T) {
+	blinkURL, _ := url.Parse("https://dial.to/?action=solana-action:https://actions.example.com/api/donate")
+	fields, err := actions.ParseURL(blinkURL)
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+	blink := fields.(*actions.BlinkURLFields)
+
+	t.Run("renders meta tags and linked action buttons", func(t *testing.T) {
+		action := actions.NewAction("https://example.com/icon.png", "Donate", "Support the builders", "Donate")
+		action.AddLinkedAction(actions.LinkedAction{Href: "/api/donate?amount=1", Label: "1 SOL"})
+		action.AddLinkedAction(actions.LinkedAction{Href: "/api/donate?amount=5", Label: "5 SOL"})
+
+		var page bytes.Buffer
+		if err := actions.RenderUnfurl(&page, action, blink); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		html := page.String()
+		for _, want := range []string{
+			`<meta property="og:title" content="Donate">`,
+			`<meta property="og:image" content="https://example.com/icon.png">`,
+			`<meta name="twitter:card" content="summary_large_image">`,
+			`<meta name="solana-action" content="solana-action:https://actions.example.com/api/donate">`,
+			`>1 SOL</a>`,
+			`action=solana-action%3Ahttps%3A%2F%2Factions.example.com%2Fapi%2Fdonate%3Famount%3D5`,
+		} {
+			if !strings.Contains(html, want) {
+				t.Errorf("expected the page to contain %s", want)
+			}
+		}
+	})
+
+	t.Run("escapes provider supplied strings", func(t *testing.T) {
+		action := actions.NewAction("javascript:alert(1)", `"><script>alert(1)</script>`, "<img src=x onerror=alert(1)>", "Go")
+		action.AddLinkedAction(actions.LinkedAction{Href: "/api/x", Label: "</a><script>alert(2)</script>"})
+
+		var page bytes.Buffer
+		if err := actions.RenderUnfurl(&page, action, blink); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		html := page.String()
+		for _, unsafe := range []string{"<script>", "<img src=x", "javascript:"} {
+			if strings.Contains(html, unsafe) {
+				t.Errorf("expected %s to be escaped", unsafe)
+			}
+		}
+	})
+
+	t.Run("resolves relative icons against the action link", func(t *testing.T) {
+		action := actions.NewAction("/static/icon.png", "Donate", "Support the builders", "Donate")
+		var page bytes.Buffer
+		if err := actions.RenderUnfurl(&page, action, blink); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if want := `<meta property="og:image" content="https://actions.example.com/static/icon.png">`; !strings.Contains(page.String(), want) {
+			t.Errorf("expected the page to contain %s", want)
+		}
+	})
+
+	t.Run("renders completed actions without links", func(t *testing.T) {
+		action := actions.NewCompletedAction("https://example.com/icon.png", "Donated", "Thanks", "Done")
+		var page bytes.Buffer
+		if err := actions.RenderUnfurl(&page, action, blink); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if !strings.Contains(page.String(), `<span class="blink-button" aria-disabled="true">Done</span>`) {
+			t.Error("expected a disabled button")
+		}
+	})
+
+	t.Run("handler fetches the action on request", func(t *testing.T) {
+		provider := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(actions.NewAction("https://example.com/icon.png", "Vote", "Cast your vote", "Vote"))
+		}))
+		defer provider.Close()
+		defaultClient := http.DefaultClient
+		http.DefaultClient = provider.Client()
+		t.Cleanup(func() { http.DefaultClient = defaultClient })
+
+		server := httptest.NewServer(actions.NewUnfurlHandler(nil))
+		defer server.Close()
+
+		res, err := http.Get(server.URL + "/?action=" + url.QueryEscape("solana-action:"+provider.URL+"/api/vote"))
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		body, _ := io.ReadAll(res.Body)
+		if res.StatusCode != http.StatusOK || !strings.Contains(string(body), `<meta property="og:title" content="Vote">`) {
+			t.Errorf("got %d %s", res.StatusCode, body)
+		}
+		if res.Header.Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("got %s want text/html", res.Header.Get("Content-Type"))
+		}
+
+		res, _ = http.Get(server.URL + "/?action=nope")
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("got %d want %d", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
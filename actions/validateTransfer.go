@@ -0,0 +1,170 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+)
+
+// Thrown when a transaction doesn't fulfill a transfer request
+type ValidateTransferError struct {
+	Message string
+}
+
+func (e *ValidateTransferError) Error() string {
+	return fmt.Sprintf("ValidateTransferError: %s", e.Message)
+}
+
+/*
+Check that a confirmed transaction fulfills a transfer request, as Solana Pay
+merchants do before releasing an order.
+
+The recipient must have received at least `Amount` of SOL, or of `SplToken` on
+its token accounts, every reference must be a key of the transaction and the
+memo, when set, must be a memo instruction.
+
+@param connection - A connection to the cluster.
+
+@param signature - Signature of the transaction, e.g. found by `FindReference`.
+
+@param fields - The transfer request, `Amount` is required.
+
+@param options - Options for `getTransaction`, may be nil.
+
+@throws {ValidateTransferError}
+*/
+func ValidateTransfer(connection RPCClient, signature string, fields *TransferRequestURLFields, options *client.GetTransactionConfig) (*client.Transaction, error) {
+	return ValidateTransferWithContext(context.Background(), connection, signature, fields, options)
+}
+
+/*
+Check that a confirmed transaction fulfills a transfer request, see `ValidateTransfer`.
+
+@param ctx - Cancels the `getTransaction` request.
+
+@throws {ValidateTransferError}
+*/
+func ValidateTransferWithContext(ctx context.Context, connection RPCClient, signature string, fields *TransferRequestURLFields, options *client.GetTransactionConfig) (*client.Transaction, error) {
+	if fields.Amount == nil {
+		return nil, &ValidateTransferError{"amount missing"}
+	}
+	config := client.GetTransactionConfig{Commitment: rpc.CommitmentConfirmed}
+	if options != nil {
+		config = *options
+	}
+	tx, err := connection.GetTransactionWithConfig(ctx, signature, config)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil || tx.Meta == nil {
+		return nil, &ValidateTransferError{"transaction not found"}
+	}
+	if tx.Meta.Err != nil {
+		return nil, &ValidateTransferError{fmt.Sprintf("transaction failed: %v", tx.Meta.Err)}
+	}
+
+	if fields.SplToken == nil {
+		err = validateSOLTransfer(tx, fields.Recipient, *fields.Amount)
+	} else {
+		err = validateSPLTransfer(tx, fields.Recipient, *fields.SplToken, *fields.Amount)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, reference := range fields.Reference {
+		if accountIndex(tx.AccountKeys, common.PublicKey(reference)) < 0 {
+			return nil, &ValidateTransferError{fmt.Sprintf("reference %s not found", reference)}
+		}
+	}
+	if fields.Memo != nil && !hasMemo(tx, *fields.Memo) {
+		return nil, &ValidateTransferError{"memo not found"}
+	}
+	return tx, nil
+}
+
+func validateSOLTransfer(tx *client.Transaction, recipient common.PublicKey, amount Amount) error {
+	lamports, err := amount.Lamports()
+	if err != nil {
+		return invalidAmount(err)
+	}
+	expected := new(big.Int).SetUint64(lamports)
+	index := accountIndex(tx.AccountKeys, recipient)
+	if index < 0 || index >= len(tx.Meta.PreBalances) || index >= len(tx.Meta.PostBalances) {
+		return &ValidateTransferError{"recipient not found"}
+	}
+	received := big.NewInt(tx.Meta.PostBalances[index] - tx.Meta.PreBalances[index])
+	if received.Cmp(expected) < 0 {
+		return &ValidateTransferError{fmt.Sprintf("amount not transferred, got %s lamports", received)}
+	}
+	return nil
+}
+
+func validateSPLTransfer(tx *client.Transaction, recipient common.PublicKey, mint common.PublicKey, amount Amount) error {
+	received := new(big.Int)
+	decimals := -1
+	sum := func(balances []rpc.TransactionMetaTokenBalance, sign int64) error {
+		for _, balance := range balances {
+			if balance.Mint != mint.String() || balance.Owner != recipient.String() {
+				continue
+			}
+			value, ok := new(big.Int).SetString(balance.UITokenAmount.Amount, 10)
+			if !ok {
+				return &ValidateTransferError{"invalid token balance"}
+			}
+			received.Add(received, value.Mul(value, big.NewInt(sign)))
+			decimals = int(balance.UITokenAmount.Decimals)
+		}
+		return nil
+	}
+	if err := sum(tx.Meta.PostTokenBalances, 1); err != nil {
+		return err
+	}
+	if err := sum(tx.Meta.PreTokenBalances, -1); err != nil {
+		return err
+	}
+	if decimals < 0 {
+		return &ValidateTransferError{"recipient token account not found"}
+	}
+	units, err := amount.BaseUnits(uint8(decimals))
+	if err != nil {
+		return invalidAmount(err)
+	}
+	if received.Cmp(new(big.Int).SetUint64(units)) < 0 {
+		return &ValidateTransferError{fmt.Sprintf("amount not transferred, got %s base units", received)}
+	}
+	return nil
+}
+
+// Amounts not fitting the mint are invalid transfers
+func invalidAmount(err error) error {
+	var amountErr *AmountError
+	if errors.As(err, &amountErr) {
+		return &ValidateTransferError{amountErr.Message}
+	}
+	return err
+}
+
+func accountIndex(keys []common.PublicKey, key common.PublicKey) int {
+	for i, k := range keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasMemo(tx *client.Transaction, memo string) bool {
+	memoProgramID := common.PublicKeyFromString(MEMO_PROGRAM_ID)
+	for _, ix := range tx.Transaction.Message.Instructions {
+		if ix.ProgramIDIndex < len(tx.AccountKeys) && tx.AccountKeys[ix.ProgramIDIndex] == memoProgramID && string(ix.Data) == memo {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package actions_test
+
+import (
+	"encoding/json"
+	"solana-actions/actions"
+	"strings"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/memo"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Confirmed transaction returned by a fake `getTransaction`
+type confirmedTransaction struct {
+	tx           *types.Transaction
+	err          any
+	preBalances  []int64
+	postBalances []int64
+	preTokens    []rpc.TransactionMetaTokenBalance
+	postTokens   []rpc.TransactionMetaTokenBalance
+}
+
+func transactionHandler(t *testing.T, transactions map[string]confirmedTransaction) rpcHandler {
+	return func(params json.RawMessage) (any, *rpc.JsonRpcError) {
+		var args []json.RawMessage
+		var signature string
+		json.Unmarshal(params, &args)
+		json.Unmarshal(args[0], &signature)
+		confirmed, ok := transactions[signature]
+		if !ok {
+			return nil, nil
+		}
+		tokens := func(balances []rpc.TransactionMetaTokenBalance) []rpc.TransactionMetaTokenBalance {
+			if balances == nil {
+				return []rpc.TransactionMetaTokenBalance{}
+			}
+			return balances
+		}
+		return map[string]any{
+			"slot":        1,
+			"transaction": []string{encodeTestTransaction(t, confirmed.tx), "base64"},
+			"meta": map[string]any{
+				"err":               confirmed.err,
+				"fee":               5000,
+				"preBalances":       confirmed.preBalances,
+				"postBalances":      confirmed.postBalances,
+				"preTokenBalances":  tokens(confirmed.preTokens),
+				"postTokenBalances": tokens(confirmed.postTokens),
+				"innerInstructions": []any{},
+				"logMessages":       []string{},
+			},
+		}, nil
+	}
+}
+
+func TestValidateTransfer(t *testing.T) {
+	user := types.NewAccount().PublicKey
+	merchant := types.NewAccount().PublicKey
+	reference := actions.Reference(types.NewAccount().PublicKey)
+	mint := types.NewAccount().PublicKey
+
+	instructions, _ := actions.AddReferences([]types.Instruction{
+		system.Transfer(system.TransferParam{From: user, To: merchant, Amount: 1_500_000_000}),
+	}, reference)
+	instructions = append(instructions, memo.BuildMemo(memo.BuildMemoParam{Memo: []byte("order-1")}))
+	// Accounts: user, merchant, reference, system program, memo program
+	paid := newTestTransaction(user, instructions...)
+	fake := newFakeRPC(t, map[string]rpcHandler{"getTransaction": transactionHandler(t, map[string]confirmedTransaction{
+		"sol": {tx: paid, preBalances: []int64{5e9, 1e9, 0, 1, 1}, postBalances: []int64{3.5e9 - 5000, 2.5e9, 0, 1, 1}},
+		"failed": {tx: paid, err: map[string]any{"InstructionError": []any{0, "Custom"}},
+			preBalances: []int64{5e9, 1e9, 0, 1, 1}, postBalances: []int64{5e9 - 5000, 1e9, 0, 1, 1}},
+		"spl": {tx: paid, preBalances: []int64{5e9, 1e9, 0, 1, 1}, postBalances: []int64{5e9 - 5000, 1e9, 0, 1, 1},
+			preTokens:  []rpc.TransactionMetaTokenBalance{{AccountIndex: 1, Mint: mint.String(), Owner: merchant.String(), UITokenAmount: rpc.TokenAccountBalance{Amount: "100", Decimals: 6}}},
+			postTokens: []rpc.TransactionMetaTokenBalance{{AccountIndex: 1, Mint: mint.String(), Owner: merchant.String(), UITokenAmount: rpc.TokenAccountBalance{Amount: "2500100", Decimals: 6}}},
+		},
+	})})
+
+	fields := func(value string, splToken *common.PublicKey, orderMemo string) *actions.TransferRequestURLFields {
+		amount, _ := actions.ParseAmount(value)
+		return &actions.TransferRequestURLFields{Recipient: merchant, Amount: &amount, SplToken: splToken, Reference: []actions.Reference{reference}, Memo: &orderMemo}
+	}
+
+	t.Run("validates SOL and SPL transfers", func(t *testing.T) {
+		if _, err := actions.ValidateTransfer(fake.client(), "sol", fields("1.5", nil, "order-1"), nil); err != nil {
+			t.Errorf("err should be nil: %s", err.Error())
+		}
+		if _, err := actions.ValidateTransfer(fake.client(), "spl", fields("2.5", &mint, "order-1"), nil); err != nil {
+			t.Errorf("err should be nil: %s", err.Error())
+		}
+	})
+
+	t.Run("rejects transactions not fulfilling the request", func(t *testing.T) {
+		other := actions.Reference(types.NewAccount().PublicKey)
+		otherMint := types.NewAccount().PublicKey
+		missingReference := fields("1.5", nil, "order-1")
+		missingReference.Reference = append(missingReference.Reference, other)
+		for name, test := range map[string]struct {
+			signature string
+			fields    *actions.TransferRequestURLFields
+			message   string
+		}{
+			"amount":    {"sol", fields("1.6", nil, "order-1"), "amount not transferred"},
+			"decimals":  {"spl", fields("2.0000001", &mint, "order-1"), "more than 6 decimals"},
+			"mint":      {"spl", fields("1", &otherMint, "order-1"), "token account not found"},
+			"memo":      {"sol", fields("1.5", nil, "order-2"), "memo not found"},
+			"reference": {"sol", missingReference, "reference " + other.String()},
+			"failed":    {"failed", fields("1.5", nil, "order-1"), "transaction failed"},
+			"missing":   {"unknown", fields("1.5", nil, "order-1"), "transaction not found"},
+		} {
+			_, err := actions.ValidateTransfer(fake.client(), test.signature, test.fields, nil)
+			if _, ok := err.(*actions.ValidateTransferError); !ok || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("%s: unexpected error %v", name, err)
+			}
+		}
+	})
+}
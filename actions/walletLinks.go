@@ -0,0 +1,268 @@
+package actions
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Thrown when wallet links can't be built
+type WalletLinkError struct {
+	Message string
+}
+
+func (e *WalletLinkError) Error() string {
+	return fmt.Sprintf("WalletLinkError: %s", e.Message)
+}
+
+// Wallets with default link templates
+const (
+	WALLET_PHANTOM  = "phantom"
+	WALLET_SOLFLARE = "solflare"
+	WALLET_BACKPACK = "backpack"
+)
+
+// How a wallet link opens the action
+type WalletLinkScheme string
+
+const (
+	// Opens the blink, or the action link, in the in-app browser of the wallet
+	WALLET_LINK_BROWSE WalletLinkScheme = "browse"
+
+	// Hands the `solana-action:` URL to the wallet
+	WALLET_LINK_ACTION WalletLinkScheme = "action"
+)
+
+// Placeholders of the link templates, replaced by the escaped embedded URL and referrer
+const (
+	WALLET_LINK_URL_PLACEHOLDER = "{url}"
+	WALLET_LINK_REF_PLACEHOLDER = "{ref}"
+)
+
+/*
+Templates of the links opening a wallet, with `{url}` and `{ref}` placeholders.
+
+`{url}` must be followed by the end of the link or by a character that isn't
+escaped in it, like `?` or `&`, so the parser can find where it ends.
+*/
+type WalletLinkTemplate struct {
+	Wallet string
+
+	// `https` links, opened by the wallet app when installed and by its website otherwise
+	BrowseUniversalLink string
+	BrowseDeepLink      string
+
+	// Links of the action scheme, empty when the wallet only browses
+	ActionUniversalLink string
+	ActionDeepLink      string
+}
+
+// Link templates of the major wallets, used by `NewWalletLinks` and `ParseURL`. Append to support other wallets.
+var WALLET_LINK_TEMPLATES = []WalletLinkTemplate{
+	{
+		Wallet:              WALLET_PHANTOM,
+		BrowseUniversalLink: "https://phantom.app/ul/browse/{url}?ref={ref}",
+		BrowseDeepLink:      "phantom://browse/{url}?ref={ref}",
+		ActionUniversalLink: "https://phantom.app/ul/v1/action/{url}?ref={ref}",
+		ActionDeepLink:      "phantom://v1/action/{url}?ref={ref}",
+	},
+	{
+		Wallet:              WALLET_SOLFLARE,
+		BrowseUniversalLink: "https://solflare.com/ul/v1/browse/{url}?ref={ref}",
+		BrowseDeepLink:      "solflare://ul/v1/browse/{url}?ref={ref}",
+		ActionUniversalLink: "https://solflare.com/ul/v1/action/{url}?ref={ref}",
+		ActionDeepLink:      "solflare://ul/v1/action/{url}?ref={ref}",
+	},
+	{
+		Wallet:              WALLET_BACKPACK,
+		BrowseUniversalLink: "https://backpack.app/ul/v1/browse/{url}?ref={ref}",
+		BrowseDeepLink:      "backpack://ul/v1/browse/{url}?ref={ref}",
+		ActionUniversalLink: "https://backpack.app/ul/v1/action/{url}?ref={ref}",
+		ActionDeepLink:      "backpack://ul/v1/action/{url}?ref={ref}",
+	},
+}
+
+// Links opening an action in a wallet
+type WalletLink struct {
+	Wallet        string
+	Scheme        WalletLinkScheme
+	UniversalLink *url.URL
+	DeepLink      *url.URL
+}
+
+// Options for `NewWalletLinks` and `NewWalletLink`
+type WalletLinkOptions struct {
+	// Referrer sent to the wallet, defaults to the origin of the blink or of the action link
+	Ref string
+
+	// Templates to use, defaults to `WALLET_LINK_TEMPLATES`
+	Templates []WalletLinkTemplate
+}
+
+/*
+Build the browse and action links of every wallet for an action or a blink.
+
+The browse links open the blink, or the action link when `fields` is an
+`*ActionRequestURLFields`, the action links carry the `solana-action:` URL.
+Wallets without action templates only get browse links.
+
+@param fields - An `*ActionRequestURLFields` or a `*BlinkURLFields`.
+@param options - Link options, may be nil.
+
+@throws {WalletLinkError}
+*/
+func NewWalletLinks(fields any, options *WalletLinkOptions) ([]WalletLink, error) {
+	var links []WalletLink
+	for _, template := range walletLinkTemplates(options) {
+		for _, scheme := range []WalletLinkScheme{WALLET_LINK_BROWSE, WALLET_LINK_ACTION} {
+			if universal, _ := template.patterns(scheme); universal == "" {
+				continue
+			}
+			link, err := newWalletLink(fields, template, scheme, options)
+			if err != nil {
+				return nil, err
+			}
+			links = append(links, *link)
+		}
+	}
+	return links, nil
+}
+
+/*
+Build the links opening an action or a blink in one wallet.
+
+@param fields - An `*ActionRequestURLFields` or a `*BlinkURLFields`.
+@param options - Link options, may be nil.
+
+@throws {WalletLinkError} For unknown wallets and schemes the wallet doesn't support.
+*/
+func NewWalletLink(fields any, wallet string, scheme WalletLinkScheme, options *WalletLinkOptions) (*WalletLink, error) {
+	for _, template := range walletLinkTemplates(options) {
+		if template.Wallet == wallet {
+			return newWalletLink(fields, template, scheme, options)
+		}
+	}
+	return nil, &WalletLinkError{fmt.Sprintf("unknown wallet %q", wallet)}
+}
+
+func walletLinkTemplates(options *WalletLinkOptions) []WalletLinkTemplate {
+	if options != nil && options.Templates != nil {
+		return options.Templates
+	}
+	return WALLET_LINK_TEMPLATES
+}
+
+// Universal and deep link templates of a scheme
+func (t WalletLinkTemplate) patterns(scheme WalletLinkScheme) (string, string) {
+	if scheme == WALLET_LINK_ACTION {
+		return t.ActionUniversalLink, t.ActionDeepLink
+	}
+	return t.BrowseUniversalLink, t.BrowseDeepLink
+}
+
+func newWalletLink(fields any, template WalletLinkTemplate, scheme WalletLinkScheme, options *WalletLinkOptions) (*WalletLink, error) {
+	if scheme != WALLET_LINK_BROWSE && scheme != WALLET_LINK_ACTION {
+		return nil, &WalletLinkError{fmt.Sprintf("unknown scheme %q", scheme)}
+	}
+	universal, deep := template.patterns(scheme)
+	if universal == "" || deep == "" {
+		return nil, &WalletLinkError{fmt.Sprintf("%s doesn't support %s links", template.Wallet, scheme)}
+	}
+	target, origin, err := walletLinkTarget(fields, scheme)
+	if err != nil {
+		return nil, err
+	}
+	ref := origin
+	if options != nil && options.Ref != "" {
+		ref = options.Ref
+	}
+
+	link := &WalletLink{Wallet: template.Wallet, Scheme: scheme}
+	for pattern, field := range map[string]**url.URL{universal: &link.UniversalLink, deep: &link.DeepLink} {
+		raw := strings.NewReplacer(
+			WALLET_LINK_URL_PLACEHOLDER, escapeWalletLinkParam(target),
+			WALLET_LINK_REF_PLACEHOLDER, escapeWalletLinkParam(ref),
+		).Replace(pattern)
+		if *field, err = url.Parse(raw); err != nil {
+			return nil, &WalletLinkError{fmt.Sprintf("invalid %s template: %s", template.Wallet, err)}
+		}
+	}
+	return link, nil
+}
+
+// URL embedded in the links of a scheme and the origin of the action
+func walletLinkTarget(fields any, scheme WalletLinkScheme) (string, string, error) {
+	var action *ActionRequestURLFields
+	var blink *url.URL
+	switch f := fields.(type) {
+	case *ActionRequestURLFields:
+		action = f
+	case *BlinkURLFields:
+		if f.Blink == nil {
+			return "", "", &WalletLinkError{"invalid blink"}
+		}
+		action, blink = &f.Action, f.Blink
+	default:
+		return "", "", &WalletLinkError{"invalid field type, must be of type *ActionRequestURLFields or *BlinkURLFields"}
+	}
+	if action.Link == nil || action.Link.Scheme != HTTPS_PROTOCOL {
+		return "", "", &WalletLinkError{"invalid link"}
+	}
+	actionURL, err := EncodeUrl(action, SOLANA_ACTIONS_PROTOCOL)
+	if err != nil {
+		return "", "", &WalletLinkError{err.Error()}
+	}
+
+	page := action.Link
+	if blink != nil {
+		// The action is escaped once, like in the blinks clients share, so `ParseURL` reads it back
+		copied := *blink
+		query := copied.Query()
+		query.Set(BLINKS_QUERY_PARAM, actionURL.String())
+		copied.RawQuery = query.Encode()
+		page = &copied
+	}
+	origin := page.Scheme + "://" + page.Host
+	if scheme == WALLET_LINK_BROWSE {
+		return page.String(), origin, nil
+	}
+	return actionURL.String(), origin, nil
+}
+
+// Escapes like `encodeURIComponent`, wallets don't decode `+` as a space in paths
+func escapeWalletLinkParam(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+/*
+Find the URL embedded in a link matching `WALLET_LINK_TEMPLATES`.
+
+Reports false when the link isn't a wallet link.
+*/
+func parseWalletLink(link *url.URL) (*url.URL, bool, error) {
+	raw := link.String()
+	for _, template := range WALLET_LINK_TEMPLATES {
+		for _, pattern := range []string{template.BrowseUniversalLink, template.BrowseDeepLink, template.ActionUniversalLink, template.ActionDeepLink} {
+			prefix, suffix, ok := strings.Cut(pattern, WALLET_LINK_URL_PLACEHOLDER)
+			if !ok || prefix == "" || !strings.HasPrefix(raw, prefix) {
+				continue
+			}
+			escaped := raw[len(prefix):]
+			if suffix != "" {
+				if end := strings.IndexByte(escaped, suffix[0]); end >= 0 {
+					escaped = escaped[:end]
+				}
+			}
+			embedded, err := url.QueryUnescape(escaped)
+			if err != nil || embedded == "" {
+				return nil, true, &ParseUrlError{fmt.Sprintf("invalid %s link", template.Wallet)}
+			}
+			target, err := url.Parse(embedded)
+			if err != nil {
+				return nil, true, &ParseUrlError{fmt.Sprintf("invalid %s link", template.Wallet)}
+			}
+			return target, true, nil
+		}
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,124 @@
+package actions_test
+
+import (
+	"errors"
+	"net/url"
+	"solana-actions/actions"
+	"strings"
+	"testing"
+)
+
+func TestWalletLinks(t *testing.T) {
+	link, _ := url.Parse("https://example.com/api/donate?amount=1")
+	label := "Donate"
+	action := &actions.ActionRequestURLFields{Link: link, Label: &label}
+	blinkBase, _ := url.Parse("https://dial.to/")
+	blink := &actions.BlinkURLFields{Blink: blinkBase, Action: *action}
+
+	t.Run("builds browse and action links of every wallet", func(t *testing.T) {
+		links, err := actions.NewWalletLinks(blink, nil)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(links) != 2*len(actions.WALLET_LINK_TEMPLATES) {
+			t.Fatalf("got %d links want %d", len(links), 2*len(actions.WALLET_LINK_TEMPLATES))
+		}
+		for _, l := range links {
+			if l.UniversalLink.Scheme != "https" || l.DeepLink.Scheme != l.Wallet {
+				t.Errorf("%s %s: unexpected links %s %s", l.Wallet, l.Scheme, l.UniversalLink, l.DeepLink)
+			}
+			if ref := l.DeepLink.Query().Get("ref"); ref != "https://dial.to" {
+				t.Errorf("%s %s: got ref %s want https://dial.to", l.Wallet, l.Scheme, ref)
+			}
+		}
+	})
+
+	t.Run("escapes the embedded URL", func(t *testing.T) {
+		l, err := actions.NewWalletLink(action, actions.WALLET_SOLFLARE, actions.WALLET_LINK_BROWSE, &actions.WalletLinkOptions{Ref: "https://shop.example.com"})
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		want := "https://solflare.com/ul/v1/browse/https%3A%2F%2Fexample.com%2Fapi%2Fdonate%3Famount%3D1?ref=https%3A%2F%2Fshop.example.com"
+		if l.UniversalLink.String() != want {
+			t.Errorf("got %s want %s", l.UniversalLink, want)
+		}
+	})
+
+	t.Run("parses the embedded URL back through ParseURL", func(t *testing.T) {
+		for _, wallet := range []string{actions.WALLET_PHANTOM, actions.WALLET_SOLFLARE, actions.WALLET_BACKPACK} {
+			browse, err := actions.NewWalletLink(blink, wallet, actions.WALLET_LINK_BROWSE, nil)
+			if err != nil {
+				t.Fatalf("err should be nil: %s", err.Error())
+			}
+			for _, l := range []*url.URL{browse.UniversalLink, browse.DeepLink} {
+				fields, err := actions.ParseURL(l)
+				if err != nil {
+					t.Fatalf("%s: err should be nil: %s", l, err.Error())
+				}
+				parsed, ok := fields.(*actions.BlinkURLFields)
+				if !ok || parsed.Action.Link.String() != link.String() || *parsed.Action.Label != label {
+					t.Errorf("%s: unexpected fields %#v", l, fields)
+				}
+			}
+
+			act, err := actions.NewWalletLink(action, wallet, actions.WALLET_LINK_ACTION, nil)
+			if err != nil {
+				t.Fatalf("err should be nil: %s", err.Error())
+			}
+			fields, err := actions.ParseURL(act.DeepLink)
+			if err != nil {
+				t.Fatalf("%s: err should be nil: %s", act.DeepLink, err.Error())
+			}
+			parsed, ok := fields.(*actions.ActionRequestURLFields)
+			if !ok || parsed.Link.String() != link.String() || *parsed.Label != label {
+				t.Errorf("%s: unexpected fields %#v", act.DeepLink, fields)
+			}
+		}
+
+		browse, _ := actions.NewWalletLink(action, actions.WALLET_BACKPACK, actions.WALLET_LINK_BROWSE, nil)
+		fields, err := actions.ParseURL(browse.DeepLink)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if parsed, ok := fields.(*actions.ActionRequestURLFields); !ok || parsed.Link.String() != link.String() {
+			t.Errorf("unexpected fields %#v", fields)
+		}
+	})
+
+	t.Run("supports custom templates", func(t *testing.T) {
+		templates := []actions.WalletLinkTemplate{{
+			Wallet:              "example",
+			BrowseUniversalLink: "https://wallet.example.com/open?url={url}&ref={ref}",
+			BrowseDeepLink:      "example://open?url={url}",
+		}}
+		links, err := actions.NewWalletLinks(action, &actions.WalletLinkOptions{Templates: templates})
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if len(links) != 1 || links[0].DeepLink.Query().Get("url") != link.String() {
+			t.Errorf("unexpected links %v", links)
+		}
+		_, err = actions.NewWalletLink(action, "example", actions.WALLET_LINK_ACTION, &actions.WalletLinkOptions{Templates: templates})
+		if err == nil || !strings.Contains(err.Error(), "doesn't support action links") {
+			t.Errorf("expected an unsupported scheme error, got %v", err)
+		}
+	})
+
+	t.Run("rejects invalid fields and wallets", func(t *testing.T) {
+		var walletErr *actions.WalletLinkError
+		if _, err := actions.NewWalletLink(action, "unknown", actions.WALLET_LINK_BROWSE, nil); !errors.As(err, &walletErr) {
+			t.Errorf("expected a WalletLinkError, got %v", err)
+		}
+		if _, err := actions.NewWalletLinks(&actions.TransferRequestURLFields{}, nil); !errors.As(err, &walletErr) {
+			t.Errorf("expected a WalletLinkError, got %v", err)
+		}
+		insecure, _ := url.Parse("http://example.com/api/donate")
+		if _, err := actions.NewWalletLinks(&actions.ActionRequestURLFields{Link: insecure}, nil); !errors.As(err, &walletErr) {
+			t.Errorf("expected a WalletLinkError, got %v", err)
+		}
+		unsupported, _ := url.Parse("phantom://browse/ftp%3A%2F%2Fexample.com?ref=x")
+		if _, err := actions.ParseURL(unsupported); err == nil {
+			t.Error("expected an error for an unsupported embedded URL")
+		}
+	})
+}
@@ -0,0 +1,347 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Thrown when a webhook event can't be queued, delivered or verified
+type WebhookError struct {
+	Message string
+}
+
+func (e *WebhookError) Error() string {
+	return fmt.Sprintf("WebhookError: %s", e.Message)
+}
+
+type WebhookEventType string
+
+const (
+	// The transfer of an order was found and validated
+	WEBHOOK_PAYMENT_CONFIRMED WebhookEventType = "payment.confirmed"
+
+	// A transaction referencing an order failed on chain or doesn't fulfill its transfer
+	WEBHOOK_PAYMENT_FAILED WebhookEventType = "payment.failed"
+
+	// An order expired before being paid
+	WEBHOOK_REFERENCE_EXPIRED WebhookEventType = "reference.expired"
+)
+
+/*
+Header of the webhook signature: `t=<unix timestamp>,v1=<hex HMAC-SHA256>`,
+the HMAC of `<timestamp>.<body>` with the shared secret.
+*/
+const WEBHOOK_SIGNATURE_HEADER = "X-Webhook-Signature"
+
+// Default maximum age of a webhook signature accepted by `VerifyWebhook`
+const WEBHOOK_TOLERANCE = 5 * time.Minute
+
+/*
+Event POSTed to the webhook endpoint.
+
+`ID` is derived from the type, order and signature of the event, so an event
+published again, e.g. by a reconciler after a restart, keeps its ID and
+receivers can drop redelivered events.
+*/
+type WebhookEvent struct {
+	ID        string           `json:"id"`
+	Type      WebhookEventType `json:"type"`
+	CreatedAt time.Time        `json:"createdAt"`
+	Order     Order            `json:"order"`
+
+	// Signature of the transaction, for payment events
+	Signature string `json:"signature,omitempty"`
+
+	// Why the payment failed
+	Reason string `json:"reason,omitempty"`
+}
+
+/*
+Sign a webhook body.
+
+@return The value of the `WEBHOOK_SIGNATURE_HEADER` header.
+*/
+func SignWebhookPayload(secret []byte, timestamp time.Time, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), webhookHMAC(secret, timestamp.Unix(), body))
+}
+
+func webhookHMAC(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+Verify the signature of a webhook request and decode its event, for receivers.
+
+@param secret - Secret shared with the dispatcher.
+
+@param tolerance - Maximum age of the signature, `WEBHOOK_TOLERANCE` when 0.
+
+@throws {WebhookError}
+*/
+func VerifyWebhook(r *http.Request, secret []byte, tolerance time.Duration) (*WebhookEvent, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, &WebhookError{err.Error()}
+	}
+	if err := VerifyWebhookSignature(secret, r.Header.Get(WEBHOOK_SIGNATURE_HEADER), body, tolerance, time.Now()); err != nil {
+		return nil, err
+	}
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, &WebhookError{fmt.Sprintf("invalid event: %s", err)}
+	}
+	return &event, nil
+}
+
+/*
+Verify a `WEBHOOK_SIGNATURE_HEADER` value against the raw body.
+
+@throws {WebhookError}
+*/
+func VerifyWebhookSignature(secret []byte, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	if tolerance == 0 {
+		tolerance = WEBHOOK_TOLERANCE
+	}
+	var timestamp int64 = -1
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			if t, err := strconv.ParseInt(value, 10, 64); err == nil {
+				timestamp = t
+			}
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp < 0 || len(signatures) == 0 {
+		return &WebhookError{"invalid signature header"}
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return &WebhookError{"signature timestamp outside the tolerance"}
+	}
+	expected := webhookHMAC(secret, timestamp, body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return &WebhookError{"signature mismatch"}
+}
+
+// Options for `NewWebhookDispatcher`
+type WebhookDispatcherOptions struct {
+	// Attempts before a delivery is marked failed, defaults to 10
+	MaxAttempts int
+
+	// Delay before the first retry, doubled after each attempt, defaults to 5s
+	InitialBackoff time.Duration
+
+	// Defaults to 1h
+	MaxBackoff time.Duration
+
+	// How long delivered and failed events are kept so they aren't queued again, defaults to 7 days
+	Retention time.Duration
+
+	// Delay between two passes of `Run`, defaults to 1s
+	Interval time.Duration
+
+	// Time limit of each attempt, defaults to 10s
+	Timeout time.Duration
+}
+
+// POSTs the queued events to a webhook endpoint, retrying with exponential backoff
+type WebhookDispatcher struct {
+	endpoint string
+	secret   []byte
+	queue    WebhookQueue
+	options  WebhookDispatcherOptions
+
+	// Serializes the passes of `DeliverDue`
+	mu sync.Mutex
+
+	// Last time the settled deliveries were pruned
+	pruned time.Time
+}
+
+// Delay between two prunes of the settled deliveries by `DeliverDue`
+const WEBHOOK_PRUNE_INTERVAL = time.Hour
+
+/*
+Create a dispatcher of the events of `queue` to `endpoint`.
+
+@param secret - Secret shared with the receiver, signs the events.
+
+@param options - Dispatcher options, may be nil.
+*/
+func NewWebhookDispatcher(endpoint string, secret []byte, queue WebhookQueue, options *WebhookDispatcherOptions) *WebhookDispatcher {
+	d := &WebhookDispatcher{endpoint: endpoint, secret: secret, queue: queue}
+	if options != nil {
+		d.options = *options
+	}
+	if d.options.MaxAttempts == 0 {
+		d.options.MaxAttempts = 10
+	}
+	if d.options.InitialBackoff == 0 {
+		d.options.InitialBackoff = 5 * time.Second
+	}
+	if d.options.MaxBackoff == 0 {
+		d.options.MaxBackoff = time.Hour
+	}
+	if d.options.Retention == 0 {
+		d.options.Retention = 7 * 24 * time.Hour
+	}
+	if d.options.Interval == 0 {
+		d.options.Interval = time.Second
+	}
+	if d.options.Timeout == 0 {
+		d.options.Timeout = 10 * time.Second
+	}
+	return d
+}
+
+/*
+Queue an event about an order, delivered by the next pass.
+
+Publishing an event again is a no-op while the queue keeps it, the queued
+event is returned.
+
+@throws {WebhookError}
+*/
+func (d *WebhookDispatcher) Publish(ctx context.Context, eventType WebhookEventType, order Order, signature string, reason string) (*WebhookEvent, error) {
+	now := time.Now().UTC()
+	event := WebhookEvent{ID: webhookEventID(eventType, order.ID, signature), Type: eventType, CreatedAt: now, Order: order, Signature: signature, Reason: reason}
+	if err := d.queue.Enqueue(ctx, WebhookDelivery{Event: event, Status: WEBHOOK_DELIVERY_PENDING, NextAttempt: now}); err != nil {
+		return nil, err
+	}
+	queued, err := d.queue.Get(ctx, event.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &queued.Event, nil
+}
+
+// ID of the event of a type about an order and a transaction
+func webhookEventID(eventType WebhookEventType, orderID string, signature string) string {
+	hash := sha256.New()
+	for _, part := range []string{string(eventType), orderID, signature} {
+		// Length prefixes keep the parts from running into each other
+		fmt.Fprintf(hash, "%d:%s", len(part), part)
+	}
+	return "evt_" + hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// Delay after the given number of failed attempts
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	delay := d.options.InitialBackoff
+	for i := 1; i < attempts && delay < d.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.options.MaxBackoff)
+}
+
+/*
+Attempt the deliveries that are due, any 2xx response acknowledges an event.
+
+Deliveries settled before `Retention` are pruned every `WEBHOOK_PRUNE_INTERVAL`.
+
+@return The number of events delivered.
+*/
+func (d *WebhookDispatcher) DeliverDue(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	due, err := d.queue.Due(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, delivery := range due {
+		if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+		delivery.Attempts++
+		if err := d.deliver(ctx, &delivery.Event); err != nil {
+			delivery.LastError = err.Error()
+			if delivery.Attempts >= d.options.MaxAttempts {
+				delivery.Status, delivery.SettledAt = WEBHOOK_DELIVERY_FAILED, time.Now()
+			} else {
+				delivery.NextAttempt = time.Now().Add(d.backoff(delivery.Attempts))
+			}
+		} else {
+			delivery.Status, delivery.LastError, delivery.SettledAt = WEBHOOK_DELIVERY_DELIVERED, "", time.Now()
+			delivered++
+		}
+		if err := d.queue.Update(ctx, delivery); err != nil {
+			return delivered, err
+		}
+	}
+
+	if now := time.Now(); now.Sub(d.pruned) >= WEBHOOK_PRUNE_INTERVAL {
+		if _, err := d.queue.Prune(ctx, now.Add(-d.options.Retention)); err != nil {
+			return delivered, err
+		}
+		d.pruned = now
+	}
+	return delivered, nil
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, event *WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	// A hanging endpoint would hold `mu` and delay every other delivery
+	ctx, cancel := context.WithTimeout(ctx, d.options.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WEBHOOK_SIGNATURE_HEADER, SignWebhookPayload(d.secret, time.Now(), body))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return &WebhookError{fmt.Sprintf("endpoint responded with %s", res.Status)}
+	}
+	return nil
+}
+
+/*
+Deliver the queued events every `Interval` until `ctx` is done.
+
+@param onError - Called with the errors of each pass, may be nil.
+*/
+func (d *WebhookDispatcher) Run(ctx context.Context, onError func(error)) error {
+	ticker := time.NewTicker(d.options.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Status of a webhook delivery
+type WebhookDeliveryStatus string
+
+const (
+	WEBHOOK_DELIVERY_PENDING   WebhookDeliveryStatus = "pending"
+	WEBHOOK_DELIVERY_DELIVERED WebhookDeliveryStatus = "delivered"
+
+	// Every attempt failed, the event is kept for inspection
+	WEBHOOK_DELIVERY_FAILED WebhookDeliveryStatus = "failed"
+)
+
+// Delivery of an event to the webhook endpoint
+type WebhookDelivery struct {
+	Event       WebhookEvent          `json:"event"`
+	Status      WebhookDeliveryStatus `json:"status"`
+	Attempts    int                   `json:"attempts"`
+	NextAttempt time.Time             `json:"nextAttempt"`
+	LastError   string                `json:"lastError,omitempty"`
+
+	// When the event was delivered or given up on
+	SettledAt time.Time `json:"settledAt"`
+}
+
+/*
+Delivery queue of a `WebhookDispatcher`, safe for concurrent use.
+
+Settled deliveries, delivered or failed, are kept apart from the pending ones
+until they are pruned, so `Due` only goes through the pending deliveries.
+*/
+type WebhookQueue interface {
+	// Queue a delivery, a no-op when its event is already queued or settled
+	Enqueue(ctx context.Context, delivery WebhookDelivery) error
+
+	// Pending deliveries whose next attempt is before `now`, oldest first
+	Due(ctx context.Context, now time.Time) ([]WebhookDelivery, error)
+
+	// Save the outcome of an attempt
+	Update(ctx context.Context, delivery WebhookDelivery) error
+
+	Get(ctx context.Context, eventID string) (*WebhookDelivery, error)
+
+	/*
+		Remove the deliveries settled before `before`, their events can be queued again.
+
+		@return The number of deliveries removed.
+	*/
+	Prune(ctx context.Context, before time.Time) (int, error)
+}
+
+func sortDeliveries(deliveries []WebhookDelivery) {
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].NextAttempt.Equal(deliveries[j].NextAttempt) {
+			return deliveries[i].Event.ID < deliveries[j].Event.ID
+		}
+		return deliveries[i].NextAttempt.Before(deliveries[j].NextAttempt)
+	})
+}
+
+func isDue(delivery *WebhookDelivery, now time.Time) bool {
+	return delivery.Status == WEBHOOK_DELIVERY_PENDING && !delivery.NextAttempt.After(now)
+}
+
+// In-memory `WebhookQueue`
+type MemoryWebhookQueue struct {
+	mu      sync.Mutex
+	pending map[string]WebhookDelivery
+	settled map[string]WebhookDelivery
+}
+
+func NewMemoryWebhookQueue() *MemoryWebhookQueue {
+	return &MemoryWebhookQueue{pending: map[string]WebhookDelivery{}, settled: map[string]WebhookDelivery{}}
+}
+
+func (q *MemoryWebhookQueue) Enqueue(ctx context.Context, delivery WebhookDelivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.pending[delivery.Event.ID]; ok {
+		return nil
+	}
+	if _, ok := q.settled[delivery.Event.ID]; ok {
+		return nil
+	}
+	q.pending[delivery.Event.ID] = delivery
+	return nil
+}
+
+func (q *MemoryWebhookQueue) Due(ctx context.Context, now time.Time) ([]WebhookDelivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var due []WebhookDelivery
+	for _, delivery := range q.pending {
+		if isDue(&delivery, now) {
+			due = append(due, delivery)
+		}
+	}
+	sortDeliveries(due)
+	return due, nil
+}
+
+func (q *MemoryWebhookQueue) Update(ctx context.Context, delivery WebhookDelivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	id := delivery.Event.ID
+	_, pending := q.pending[id]
+	_, settled := q.settled[id]
+	if !pending && !settled {
+		return &WebhookError{fmt.Sprintf("event %s not found", id)}
+	}
+	delete(q.pending, id)
+	delete(q.settled, id)
+	if delivery.Status == WEBHOOK_DELIVERY_PENDING {
+		q.pending[id] = delivery
+	} else {
+		q.settled[id] = delivery
+	}
+	return nil
+}
+
+func (q *MemoryWebhookQueue) Get(ctx context.Context, eventID string) (*WebhookDelivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if delivery, ok := q.pending[eventID]; ok {
+		return &delivery, nil
+	}
+	if delivery, ok := q.settled[eventID]; ok {
+		return &delivery, nil
+	}
+	return nil, &WebhookError{fmt.Sprintf("event %s not found", eventID)}
+}
+
+func (q *MemoryWebhookQueue) Prune(ctx context.Context, before time.Time) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pruned := 0
+	for id, delivery := range q.settled {
+		if delivery.SettledAt.Before(before) {
+			delete(q.settled, id)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+var (
+	// Pending deliveries, the only ones `Due` goes through
+	boltDeliveriesBucket = []byte("webhookDeliveries")
+
+	boltSettledDeliveriesBucket = []byte("webhookSettledDeliveries")
+)
+
+// `WebhookQueue` persisted in a BoltDB file, deliveries are JSON encoded
+type BoltWebhookQueue struct {
+	db *bolt.DB
+}
+
+/*
+Open or create the BoltDB file of a `WebhookQueue`.
+
+The file is locked until `Close`, a single process can open it.
+
+@throws {WebhookError}
+*/
+func OpenBoltWebhookQueue(path string) (*BoltWebhookQueue, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, &WebhookError{fmt.Sprintf("can't open %s: %s", path, err)}
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		pending, err := tx.CreateBucketIfNotExists(boltDeliveriesBucket)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(boltSettledDeliveriesBucket); err != nil {
+			return err
+		}
+		// Files written before the deliveries were split keep their settled deliveries with the pending ones
+		var settled []WebhookDelivery
+		err = pending.ForEach(func(key []byte, raw []byte) error {
+			var delivery WebhookDelivery
+			if err := json.Unmarshal(raw, &delivery); err != nil {
+				return &WebhookError{fmt.Sprintf("invalid delivery %s: %s", key, err)}
+			}
+			if delivery.Status != WEBHOOK_DELIVERY_PENDING {
+				// Kept for a full retention from now, their settlement time is unknown
+				if delivery.SettledAt.IsZero() {
+					delivery.SettledAt = time.Now()
+				}
+				settled = append(settled, delivery)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i := range settled {
+			if err := putBoltDelivery(tx, &settled[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, &WebhookError{err.Error()}
+	}
+	return &BoltWebhookQueue{db}, nil
+}
+
+func (q *BoltWebhookQueue) Close() error {
+	return q.db.Close()
+}
+
+func (q *BoltWebhookQueue) Enqueue(ctx context.Context, delivery WebhookDelivery) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		if getBoltDelivery(tx, delivery.Event.ID) != nil {
+			return nil
+		}
+		return putBoltDelivery(tx, &delivery)
+	})
+}
+
+func (q *BoltWebhookQueue) Due(ctx context.Context, now time.Time) ([]WebhookDelivery, error) {
+	var due []WebhookDelivery
+	err := q.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDeliveriesBucket).ForEach(func(key []byte, raw []byte) error {
+			var delivery WebhookDelivery
+			if err := json.Unmarshal(raw, &delivery); err != nil {
+				return &WebhookError{fmt.Sprintf("invalid delivery %s: %s", key, err)}
+			}
+			if isDue(&delivery, now) {
+				due = append(due, delivery)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortDeliveries(due)
+	return due, nil
+}
+
+func (q *BoltWebhookQueue) Update(ctx context.Context, delivery WebhookDelivery) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		if getBoltDelivery(tx, delivery.Event.ID) == nil {
+			return &WebhookError{fmt.Sprintf("event %s not found", delivery.Event.ID)}
+		}
+		return putBoltDelivery(tx, &delivery)
+	})
+}
+
+func (q *BoltWebhookQueue) Get(ctx context.Context, eventID string) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	err := q.db.View(func(tx *bolt.Tx) error {
+		raw := getBoltDelivery(tx, eventID)
+		if raw == nil {
+			return &WebhookError{fmt.Sprintf("event %s not found", eventID)}
+		}
+		return json.Unmarshal(raw, &delivery)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (q *BoltWebhookQueue) Prune(ctx context.Context, before time.Time) (int, error) {
+	pruned := 0
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		settled := tx.Bucket(boltSettledDeliveriesBucket)
+		var expired [][]byte
+		err := settled.ForEach(func(key []byte, raw []byte) error {
+			var delivery WebhookDelivery
+			if err := json.Unmarshal(raw, &delivery); err != nil {
+				return &WebhookError{fmt.Sprintf("invalid delivery %s: %s", key, err)}
+			}
+			if delivery.SettledAt.Before(before) {
+				expired = append(expired, append([]byte{}, key...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range expired {
+			if err := settled.Delete(key); err != nil {
+				return err
+			}
+		}
+		pruned = len(expired)
+		return nil
+	})
+	return pruned, err
+}
+
+// Raw delivery of an event, pending or settled
+func getBoltDelivery(tx *bolt.Tx, eventID string) []byte {
+	if raw := tx.Bucket(boltDeliveriesBucket).Get([]byte(eventID)); raw != nil {
+		return raw
+	}
+	return tx.Bucket(boltSettledDeliveriesBucket).Get([]byte(eventID))
+}
+
+// Save a delivery in the bucket of its status
+func putBoltDelivery(tx *bolt.Tx, delivery *WebhookDelivery) error {
+	raw, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	key := []byte(delivery.Event.ID)
+	bucket, other := boltDeliveriesBucket, boltSettledDeliveriesBucket
+	if delivery.Status != WEBHOOK_DELIVERY_PENDING {
+		bucket, other = other, bucket
+	}
+	if err := tx.Bucket(other).Delete(key); err != nil {
+		return err
+	}
+	return tx.Bucket(bucket).Put(key, raw)
+}
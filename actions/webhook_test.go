@@ -0,0 +1,353 @@
+package actions_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"solana-actions/actions"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Webhook endpoint verifying the events, answering `statuses` in turn then 200
+type webhookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	events   []actions.WebhookEvent
+}
+
+func newWebhookReceiver(t *testing.T, secret []byte, statuses ...int) *webhookReceiver {
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event, err := actions.VerifyWebhook(r, secret, 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		if len(receiver.statuses) > 0 {
+			status := receiver.statuses[0]
+			receiver.statuses = receiver.statuses[1:]
+			w.WriteHeader(status)
+			return
+		}
+		receiver.events = append(receiver.events, *event)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) received() []actions.WebhookEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]actions.WebhookEvent{}, r.events...)
+}
+
+// Webhook queue failing its enqueues while `failing` is set
+type flakyWebhookQueue struct {
+	*actions.MemoryWebhookQueue
+	failing bool
+}
+
+func (q *flakyWebhookQueue) Enqueue(ctx context.Context, delivery actions.WebhookDelivery) error {
+	if q.failing {
+		return &actions.WebhookError{Message: "queue unavailable"}
+	}
+	return q.MemoryWebhookQueue.Enqueue(ctx, delivery)
+}
+
+func TestWebhookSignature(t *testing.T) {
+	secret := []byte("whsec_test")
+	body := []byte(`{"id":"evt_1"}`)
+	now := time.Now()
+	header := actions.SignWebhookPayload(secret, now, body)
+
+	if err := actions.VerifyWebhookSignature(secret, header, body, 0, now); err != nil {
+		t.Errorf("err should be nil: %s", err.Error())
+	}
+	for name, test := range map[string]struct {
+		secret []byte
+		header string
+		body   string
+		now    time.Time
+	}{
+		"tampered body": {secret, header, `{"id":"evt_2"}`, now},
+		"wrong secret":  {[]byte("other"), header, string(body), now},
+		"replayed":      {secret, header, string(body), now.Add(10 * time.Minute)},
+		"malformed":     {secret, "v1=abc", string(body), now},
+	} {
+		if err := actions.VerifyWebhookSignature(test.secret, test.header, []byte(test.body), 0, test.now); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("whsec_test")
+	order := actions.Order{ID: "order-1", Reference: actions.NewReference(), Status: actions.ORDER_STATUS_PAID}
+	options := &actions.WebhookDispatcherOptions{InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond, MaxAttempts: 3}
+
+	t.Run("retries until the endpoint acknowledges", func(t *testing.T) {
+		receiver := newWebhookReceiver(t, secret, http.StatusInternalServerError, http.StatusServiceUnavailable)
+		queue := actions.NewMemoryWebhookQueue()
+		dispatcher := actions.NewWebhookDispatcher(receiver.URL, secret, queue, options)
+		event, err := dispatcher.Publish(ctx, actions.WEBHOOK_PAYMENT_CONFIRMED, order, "sig", "")
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		for i := 0; i < 3; i++ {
+			if _, err := dispatcher.DeliverDue(ctx); err != nil {
+				t.Fatalf("err should be nil: %s", err.Error())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		delivery, _ := queue.Get(ctx, event.ID)
+		events := receiver.received()
+		if delivery.Status != actions.WEBHOOK_DELIVERY_DELIVERED || delivery.Attempts != 3 {
+			t.Errorf("unexpected delivery %+v", delivery)
+		}
+		if len(events) != 1 || events[0].ID != event.ID || events[0].Type != actions.WEBHOOK_PAYMENT_CONFIRMED || events[0].Order.ID != "order-1" || events[0].Signature != "sig" {
+			t.Errorf("unexpected events %+v", events)
+		}
+	})
+
+	t.Run("gives up after the maximum attempts", func(t *testing.T) {
+		receiver := newWebhookReceiver(t, []byte("other secret"))
+		queue := actions.NewMemoryWebhookQueue()
+		dispatcher := actions.NewWebhookDispatcher(receiver.URL, secret, queue, options)
+		event, _ := dispatcher.Publish(ctx, actions.WEBHOOK_PAYMENT_FAILED, order, "sig", "amount not transferred")
+		for i := 0; i < 5; i++ {
+			dispatcher.DeliverDue(ctx)
+			time.Sleep(10 * time.Millisecond)
+		}
+		delivery, _ := queue.Get(ctx, event.ID)
+		if delivery.Status != actions.WEBHOOK_DELIVERY_FAILED || delivery.Attempts != 3 || delivery.LastError == "" {
+			t.Errorf("unexpected delivery %+v", delivery)
+		}
+	})
+
+	t.Run("waits for the backoff", func(t *testing.T) {
+		receiver := newWebhookReceiver(t, secret, http.StatusInternalServerError)
+		queue := actions.NewMemoryWebhookQueue()
+		dispatcher := actions.NewWebhookDispatcher(receiver.URL, secret, queue, &actions.WebhookDispatcherOptions{InitialBackoff: time.Hour})
+		dispatcher.Publish(ctx, actions.WEBHOOK_REFERENCE_EXPIRED, order, "", "")
+		dispatcher.DeliverDue(ctx)
+		if delivered, _ := dispatcher.DeliverDue(ctx); delivered != 0 || len(receiver.received()) != 0 {
+			t.Error("the retry should wait for the backoff")
+		}
+	})
+
+	t.Run("times out hanging endpoints", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		t.Cleanup(server.Close)
+		t.Cleanup(func() { close(release) })
+		queue := actions.NewMemoryWebhookQueue()
+		dispatcher := actions.NewWebhookDispatcher(server.URL, secret, queue, &actions.WebhookDispatcherOptions{Timeout: 20 * time.Millisecond})
+		event, _ := dispatcher.Publish(ctx, actions.WEBHOOK_PAYMENT_CONFIRMED, order, "sig", "")
+		start := time.Now()
+		if delivered, err := dispatcher.DeliverDue(ctx); err != nil || delivered != 0 {
+			t.Fatalf("got %d %v", delivered, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("the attempt should time out, took %s", elapsed)
+		}
+		delivery, _ := queue.Get(ctx, event.ID)
+		if delivery.Status != actions.WEBHOOK_DELIVERY_PENDING || delivery.Attempts != 1 || delivery.LastError == "" {
+			t.Errorf("unexpected delivery %+v", delivery)
+		}
+	})
+
+	t.Run("publishes each event once", func(t *testing.T) {
+		receiver := newWebhookReceiver(t, secret)
+		queue := actions.NewMemoryWebhookQueue()
+		dispatcher := actions.NewWebhookDispatcher(receiver.URL, secret, queue, options)
+		first, err := dispatcher.Publish(ctx, actions.WEBHOOK_PAYMENT_CONFIRMED, order, "sig", "")
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		other, _ := dispatcher.Publish(ctx, actions.WEBHOOK_PAYMENT_FAILED, order, "sig", "")
+		if other.ID == first.ID {
+			t.Error("expected events of another type to get another ID")
+		}
+		if _, err := dispatcher.DeliverDue(ctx); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+
+		// Published again, e.g. by a reconciler after a restart
+		again, err := actions.NewWebhookDispatcher(receiver.URL, secret, queue, options).Publish(ctx, actions.WEBHOOK_PAYMENT_CONFIRMED, order, "sig", "")
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if again.ID != first.ID || !again.CreatedAt.Equal(first.CreatedAt) {
+			t.Errorf("got %+v want the queued event %+v", again, first)
+		}
+		if due, _ := queue.Due(ctx, time.Now()); len(due) != 0 {
+			t.Errorf("expected no due deliveries, got %+v", due)
+		}
+		if events := receiver.received(); len(events) != 2 {
+			t.Errorf("got %d events want 2", len(events))
+		}
+	})
+
+	t.Run("prunes settled deliveries after the retention", func(t *testing.T) {
+		for name, open := range map[string]func(t *testing.T) actions.WebhookQueue{
+			"memory": func(t *testing.T) actions.WebhookQueue { return actions.NewMemoryWebhookQueue() },
+			"bolt": func(t *testing.T) actions.WebhookQueue {
+				queue, err := actions.OpenBoltWebhookQueue(filepath.Join(t.TempDir(), "webhooks.db"))
+				if err != nil {
+					t.Fatalf("err should be nil: %s", err.Error())
+				}
+				t.Cleanup(func() { queue.Close() })
+				return queue
+			},
+		} {
+			queue := open(t)
+			receiver := newWebhookReceiver(t, secret)
+			dispatcher := actions.NewWebhookDispatcher(receiver.URL, secret, queue, options)
+			event, _ := dispatcher.Publish(ctx, actions.WEBHOOK_PAYMENT_CONFIRMED, order, "sig", "")
+			pending, _ := dispatcher.Publish(ctx, actions.WEBHOOK_REFERENCE_EXPIRED, order, "", "")
+			queue.Update(ctx, actions.WebhookDelivery{Event: *pending, Status: actions.WEBHOOK_DELIVERY_PENDING, NextAttempt: time.Now().Add(time.Hour)})
+			if delivered, err := dispatcher.DeliverDue(ctx); err != nil || delivered != 1 {
+				t.Fatalf("%s: got %d %v", name, delivered, err)
+			}
+
+			if pruned, err := queue.Prune(ctx, time.Now().Add(-time.Minute)); err != nil || pruned != 0 {
+				t.Errorf("%s: got %d %v want nothing pruned within the retention", name, pruned, err)
+			}
+			if pruned, err := queue.Prune(ctx, time.Now().Add(time.Minute)); err != nil || pruned != 1 {
+				t.Errorf("%s: got %d %v want 1 pruned", name, pruned, err)
+			}
+			if _, err := queue.Get(ctx, event.ID); err == nil {
+				t.Errorf("%s: expected the delivered event to be pruned", name)
+			}
+			if _, err := queue.Get(ctx, pending.ID); err != nil {
+				t.Errorf("%s: expected the pending event to be kept", name)
+			}
+		}
+	})
+
+	t.Run("bolt queue persists deliveries", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "webhooks.db")
+		queue, err := actions.OpenBoltWebhookQueue(path)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		event, _ := actions.NewWebhookDispatcher("http://127.0.0.1:0", secret, queue, nil).Publish(ctx, actions.WEBHOOK_PAYMENT_CONFIRMED, order, "sig", "")
+		queue.Close()
+
+		queue, err = actions.OpenBoltWebhookQueue(path)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		defer queue.Close()
+		receiver := newWebhookReceiver(t, secret)
+		if delivered, err := actions.NewWebhookDispatcher(receiver.URL, secret, queue, nil).DeliverDue(ctx); err != nil || delivered != 1 {
+			t.Fatalf("got %d %v", delivered, err)
+		}
+		if events := receiver.received(); len(events) != 1 || events[0].ID != event.ID || events[0].Order.Reference != order.Reference {
+			t.Errorf("unexpected events %+v", events)
+		}
+	})
+}
+
+func TestReconcilerWebhooks(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("whsec_test")
+	user := types.NewAccount().PublicKey
+	merchant := types.NewAccount().PublicKey
+	amount, _ := actions.ParseAmount("1")
+	transfer := actions.TransferRequestURLFields{Recipient: merchant, Amount: &amount}
+	store := actions.NewMemoryReferenceStore()
+	paidOrder, _ := store.Issue(ctx, "paid", transfer, time.Hour)
+	failedOrder, _ := store.Issue(ctx, "failed", transfer, time.Hour)
+	store.Issue(ctx, "expired", transfer, time.Nanosecond)
+
+	instructions, _ := actions.AddReferences([]types.Instruction{system.Transfer(system.TransferParam{From: user, To: merchant, Amount: 1e9})}, paidOrder.Reference)
+	fake := newFakeRPC(t, map[string]rpcHandler{
+		"getSignaturesForAddress": signaturesHandler(map[string][]rpc.SignatureWithStatus{
+			paidOrder.Reference.String():   {{Signature: "paid-sig", Slot: 2}},
+			failedOrder.Reference.String(): {{Signature: "failed-sig", Slot: 3, Err: map[string]any{"InstructionError": []any{0, "Custom"}}}},
+		}),
+		"getTransaction": transactionHandler(t, map[string]confirmedTransaction{
+			"paid-sig": {tx: newTestTransaction(user, instructions...), preBalances: []int64{3e9, 0, 0, 1}, postBalances: []int64{2e9, 1e9, 0, 1}},
+		}),
+	})
+	receiver := newWebhookReceiver(t, secret)
+	dispatcher := actions.NewWebhookDispatcher(receiver.URL, secret, actions.NewMemoryWebhookQueue(), nil)
+	reconciler := actions.NewReconciler(store, fake.client(), &actions.ReconcilerOptions{Webhooks: dispatcher})
+
+	for i := 0; i < 2; i++ {
+		if _, err := reconciler.ReconcileOnce(ctx); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+	}
+	if _, err := dispatcher.DeliverDue(ctx); err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+	orders := map[actions.WebhookEventType]string{}
+	for _, event := range receiver.received() {
+		orders[event.Type] += event.Order.ID
+	}
+	if len(receiver.received()) != 3 || orders[actions.WEBHOOK_PAYMENT_CONFIRMED] != "paid" || orders[actions.WEBHOOK_PAYMENT_FAILED] != "failed" || orders[actions.WEBHOOK_REFERENCE_EXPIRED] != "expired" {
+		t.Errorf("unexpected events %+v", receiver.received())
+	}
+}
+
+func TestReconcilerWebhookFailures(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("whsec_test")
+	user := types.NewAccount().PublicKey
+	merchant := types.NewAccount().PublicKey
+	amount, _ := actions.ParseAmount("1")
+	store := actions.NewMemoryReferenceStore()
+	order, _ := store.Issue(ctx, "paid", actions.TransferRequestURLFields{Recipient: merchant, Amount: &amount}, time.Hour)
+
+	instructions, _ := actions.AddReferences([]types.Instruction{system.Transfer(system.TransferParam{From: user, To: merchant, Amount: 1e9})}, order.Reference)
+	fake := newFakeRPC(t, map[string]rpcHandler{
+		"getSignaturesForAddress": signaturesHandler(map[string][]rpc.SignatureWithStatus{
+			order.Reference.String(): {{Signature: "paid-sig", Slot: 2}},
+		}),
+		"getTransaction": transactionHandler(t, map[string]confirmedTransaction{
+			"paid-sig": {tx: newTestTransaction(user, instructions...), preBalances: []int64{3e9, 0, 0, 1}, postBalances: []int64{2e9, 1e9, 0, 1}},
+		}),
+	})
+	queue := &flakyWebhookQueue{MemoryWebhookQueue: actions.NewMemoryWebhookQueue(), failing: true}
+	receiver := newWebhookReceiver(t, secret)
+	dispatcher := actions.NewWebhookDispatcher(receiver.URL, secret, queue, nil)
+	reconciler := actions.NewReconciler(store, fake.client(), &actions.ReconcilerOptions{Webhooks: dispatcher})
+
+	if paid, err := reconciler.ReconcileOnce(ctx); err == nil || len(paid) != 0 {
+		t.Fatalf("got %+v, %v want the enqueue error", paid, err)
+	}
+	if pending, _ := store.Pending(ctx); len(pending) != 1 {
+		t.Fatalf("orders should stay pending until the event is queued %+v", pending)
+	}
+
+	queue.failing = false
+	paid, err := reconciler.ReconcileOnce(ctx)
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+	if len(paid) != 1 || paid[0].ID != "paid" {
+		t.Fatalf("unexpected paid orders %+v", paid)
+	}
+	if _, err := dispatcher.DeliverDue(ctx); err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+	events := receiver.received()
+	if len(events) != 1 || events[0].Type != actions.WEBHOOK_PAYMENT_CONFIRMED || events[0].Order.Status != actions.ORDER_STATUS_PAID || events[0].Signature != "paid-sig" {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"solana-actions/actions"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/rpc"
+)
+
+// Output of `find-reference`
+type findReferenceOutput struct {
+	Signature string  `json:"signature"`
+	Slot      uint64  `json:"slot"`
+	BlockTime *int64  `json:"blockTime,omitempty"`
+	Memo      *string `json:"memo,omitempty"`
+
+	// `ok`, or `failed` when the transaction failed on chain with `err`
+	Status string `json:"status"`
+	Err    any    `json:"err,omitempty"`
+
+	// `processed`, `confirmed` or `finalized`, omitted when the cluster doesn't know the signature
+	ConfirmationStatus rpc.Commitment `json:"confirmationStatus,omitempty"`
+}
+
+func newFindReferenceOutput(signature *rpc.SignatureWithStatus, confirmationStatus rpc.Commitment) findReferenceOutput {
+	output := findReferenceOutput{
+		Signature:          signature.Signature,
+		Slot:               signature.Slot,
+		BlockTime:          signature.BlockTime,
+		Memo:               signature.Memo,
+		Status:             "ok",
+		Err:                signature.Err,
+		ConfirmationStatus: confirmationStatus,
+	}
+	if signature.Err != nil {
+		output.Status = "failed"
+	}
+	return output
+}
+
+func runFindReference(c *cli, args []string) int {
+	flags := flag.NewFlagSet("find-reference", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	rpcURL := flags.String("url", rpc.MainnetRPCEndpoint, "RPC endpoint or cluster name, several comma-separated endpoints are pooled")
+	limit := flags.Int("limit", 1000, "signatures fetched per request")
+	maxSignatures := flags.Int("max", actions.MAX_REFERENCE_SIGNATURES, "with several references, signatures scanned per reference at most")
+	commitment := flags.String("commitment", string(rpc.CommitmentConfirmed), "commitment of the signatures")
+	until := flags.String("until", "", "only search signatures newer than this one, the newest of a previous scan")
+	before := flags.String("before", "", "only search signatures older than this one, the oldest of a previous scan")
+	mode := flags.String("mode", string(actions.REFERENCE_MATCH_ALL), "with several references, match transactions carrying all or any of them")
+	if err := flags.Parse(args); err != nil {
+		return EXIT_USAGE
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(c.stderr, "usage: blink find-reference [flags] <reference>...")
+		return EXIT_USAGE
+	}
+	var references []actions.Reference
+	for _, arg := range flags.Args() {
+		key, err := parseKey("reference", arg)
+		if err != nil {
+			return c.failJSON(err)
+		}
+		references = append(references, actions.Reference(key))
+	}
+	conn, err := newRPCClient(*rpcURL)
+	if err != nil {
+		return c.failJSON(err)
+	}
+	config := &client.GetSignaturesForAddressConfig{
+		Limit:      *limit,
+		Until:      *until,
+		Before:     *before,
+		Commitment: rpc.Commitment(*commitment),
+	}
+
+	ctx := context.Background()
+
+	// Several references print every matching signature, newest first
+	if len(references) > 1 {
+		options := &actions.FindSignaturesForReferencesOptions{GetSignaturesForAddressConfig: *config, MaxSignatures: *maxSignatures}
+		signatures, err := actions.FindSignaturesForReferences(ctx, conn, references, actions.ReferenceMatchMode(*mode), options)
+		if err != nil {
+			return c.failJSON(err)
+		}
+		outputs, err := findReferenceOutputs(ctx, conn, signatures)
+		if err != nil {
+			return c.failJSON(err)
+		}
+		return c.writeJSON(outputs)
+	}
+
+	signature, err := actions.FindReference(conn, references[0], config)
+	if err != nil {
+		return c.failJSON(err)
+	}
+	outputs, err := findReferenceOutputs(ctx, conn, []rpc.SignatureWithStatus{*signature})
+	if err != nil {
+		return c.failJSON(err)
+	}
+	return c.writeJSON(outputs[0])
+}
+
+// Outputs of the found signatures with their confirmation status
+func findReferenceOutputs(ctx context.Context, conn actions.RPCClient, signatures []rpc.SignatureWithStatus) ([]findReferenceOutput, error) {
+	keys := make([]string, len(signatures))
+	for i, signature := range signatures {
+		keys[i] = signature.Signature
+	}
+	statuses, err := actions.FetchConfirmationStatuses(ctx, conn, keys)
+	if err != nil {
+		return nil, err
+	}
+	outputs := []findReferenceOutput{}
+	for i := range signatures {
+		outputs = append(outputs, newFindReferenceOutput(&signatures[i], statuses[i]))
+	}
+	return outputs, nil
+}
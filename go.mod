@@ -4,10 +4,16 @@ go 1.22.1
 
 require (
 	github.com/blocto/solana-go-sdk v1.30.0
-	github.com/twystd/tweetnacl-go v0.0.0-20210413205227-681aa97ec383
+	github.com/makiuchi-d/gozxing v0.1.1
+	github.com/mr-tron/base58 v1.2.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	go.etcd.io/bbolt v1.3.10
 )
 
 require (
 	filippo.io/edwards25519 v1.0.0-rc.1 // indirect
-	github.com/mr-tron/base58 v1.2.0 // indirect
+	github.com/near/borsh-go v0.3.2-0.20220516180422-1ff87d108454 // indirect
+	golang.org/x/sys v0.4.0 // indirect
+	golang.org/x/text v0.3.7 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
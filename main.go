@@ -1,47 +1,136 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
-	"net/url"
+	"io"
+	"os"
 	"solana-actions/actions"
+	"strings"
+
+	"github.com/blocto/solana-go-sdk/client"
+)
+
+// Exit codes of the CLI
+const (
+	EXIT_OK = 0
+
+	// The command ran and failed, e.g. the action returned an error or the transaction failed
+	EXIT_FAILURE = 1
+
+	// Invalid command line
+	EXIT_USAGE = 2
 )
 
+type command struct {
+	name  string
+	usage string
+	run   func(c *cli, args []string) int
+}
+
+var commands = []command{
+	{"open", "open [flags] <url>                 render an action and execute it", runOpen},
+	{"parse", "parse <url>                        print the fields of an action, blink or transfer URL", runParse},
+	{"encode", "encode [flags]                     build an action, blink or transfer URL and its QR code", runEncode},
+	{"scan", "scan <image>                       decode the QR code of a PNG or JPEG and parse its URL", runScan},
+	{"find-reference", "find-reference [flags] <ref>...    print the oldest transaction of a reference, or all matching several", runFindReference},
+	{"validate", "validate [flags] <url>             lint an action endpoint against the spec", runValidate},
+	{"serve", "serve [flags]                      run a static action server from a config file", runServe},
+}
+
+// Streams of a CLI invocation
+type cli struct {
+	stdin  *bufio.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
 func main() {
-	fmt.Println("This is my implementation of solana actions ink")
-	urlString := "solana-action:https://actions.alice.com/donate?label=wonderland&message=the_builder"
-	// "https://example.domain?action=solana-action%3Ahttps%3A%2F%2Factions.alice.com%2Fdonate"
+	c := &cli{stdin: bufio.NewReader(os.Stdin), stdout: os.Stdout, stderr: os.Stderr}
+	os.Exit(c.run(os.Args[1:]))
+}
 
-	// Parse the URL string into a *url.URL struct
-	parsedURL, err := url.Parse(urlString)
-	if err != nil {
-		fmt.Println("Error parsing URL:", err)
-		return
+func (c *cli) run(args []string) int {
+	if len(args) == 0 {
+		c.usage()
+		return EXIT_USAGE
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(c, args[1:])
+		}
 	}
+	fmt.Fprintf(c.stderr, "blink: unknown command %q\n", args[0])
+	c.usage()
+	return EXIT_USAGE
+}
+
+func (c *cli) usage() {
+	fmt.Fprintln(c.stderr, "usage: blink <command> [arguments]")
+	fmt.Fprintln(c.stderr)
+	fmt.Fprintln(c.stderr, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(c.stderr, "  %s\n", cmd.usage)
+	}
+}
 
-	// Get the protocol (scheme) of the URL
-	val, err := actions.ParseURL(parsedURL)
+// Print `question` and read one line of input, trimmed
+func (c *cli) prompt(question string) (string, error) {
+	fmt.Fprint(c.stdout, question)
+	line, err := c.stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// Ask a yes/no question, anything but y or yes is a no
+func (c *cli) confirm(question string) (bool, error) {
+	answer, err := c.prompt(question + " [y/N] ")
 	if err != nil {
-		fmt.Println(err)
+		return false, err
 	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
 
-	action, ok := val.(*actions.ActionRequestURLFields)
-	if ok {
-		//do action url stuff
+// Report an error and return `EXIT_FAILURE`
+func (c *cli) fail(err error) int {
+	fmt.Fprintf(c.stderr, "blink: %s\n", err)
+	return EXIT_FAILURE
+}
 
-		url, err := actions.EncodeUrl(action, actions.SOLANA_ACTIONS_PROTOCOL)
-		if err != nil {
-			fmt.Println(err)
-		}
-		fmt.Println(url.String())
+// Print `v` as indented JSON and return `EXIT_OK`
+func (c *cli) writeJSON(v any) int {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return c.fail(err)
 	}
+	return EXIT_OK
+}
 
-	blink, ok := val.(*actions.BlinkURLFields)
-	if ok {
-		//do blinks url stuff
-		fmt.Println(blink.Action.Link)
-		_, err := actions.EncodeUrl(blink, actions.SOLANA_ACTIONS_PROTOCOL)
-		if err != nil {
-			fmt.Println(err)
+// Print `{"error": ...}` and return `EXIT_FAILURE`, for the commands with machine-readable output
+func (c *cli) failJSON(err error) int {
+	c.writeJSON(map[string]string{"error": err.Error()})
+	return EXIT_FAILURE
+}
+
+// Connect to the `-url` flag, a cluster name or endpoints, several comma-separated endpoints are pooled
+func newRPCClient(endpoints string) (actions.RPCClient, error) {
+	if cluster, err := actions.ParseCluster(endpoints); err == nil {
+		return client.NewClient(cluster.RPCEndpoint()), nil
+	}
+	if !strings.Contains(endpoints, ",") {
+		return client.NewClient(endpoints), nil
+	}
+	var urls []string
+	for _, endpoint := range strings.Split(endpoints, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			urls = append(urls, endpoint)
 		}
 	}
+	return actions.NewRPCPool(urls, nil)
 }
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"solana-actions/actions"
+	"strings"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/client"
+)
+
+// Run the CLI with `input` on stdin, returns the exit code and stdout
+func runCLI(t *testing.T, input string, args ...string) (int, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	c := &cli{stdin: bufio.NewReader(strings.NewReader(input)), stdout: &stdout, stderr: &stderr}
+	code := c.run(args)
+	return code, stdout.String()
+}
+
+func TestCLI(t *testing.T) {
+	recipient := "4uhcVJyU9pJkvQyS88uRDiswHXSCkY3zQawwpjk2NsNY"
+
+	t.Run("parse prints the fields as JSON", func(t *testing.T) {
+		code, out := runCLI(t, "", "parse", "solana:"+recipient+"?amount=1.5&label=Coffee")
+		var output parseOutput
+		if err := json.Unmarshal([]byte(out), &output); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if code != EXIT_OK || output.Type != "transfer" || output.Transfer.Amount.String() != "1.5" {
+			t.Errorf("got %d %s", code, out)
+		}
+	})
+
+	t.Run("errors are JSON with a non-zero exit code", func(t *testing.T) {
+		code, out := runCLI(t, "", "parse", "ftp://example.com")
+		if code != EXIT_FAILURE || !strings.Contains(out, `"error"`) {
+			t.Errorf("got %d %s", code, out)
+		}
+		if code, _ := runCLI(t, "", "parse"); code != EXIT_USAGE {
+			t.Errorf("got %d want %d", code, EXIT_USAGE)
+		}
+		if code, _ := runCLI(t, "", "unknown"); code != EXIT_USAGE {
+			t.Errorf("got %d want %d", code, EXIT_USAGE)
+		}
+	})
+
+	t.Run("encode builds URLs from flags", func(t *testing.T) {
+		code, out := runCLI(t, "", "encode", "-recipient", recipient, "-amount", "2", "-memo", "order 1")
+		want := "solana:" + recipient + "?amount=2&memo=order%201"
+		if code != EXIT_OK || !strings.Contains(out, want) {
+			t.Errorf("got %d %s want %s", code, out, want)
+		}
+		qr := filepath.Join(t.TempDir(), "qr.svg")
+		if code, out := runCLI(t, "", "encode", "-recipient", recipient, "-qr", qr); code != EXIT_OK || !strings.Contains(out, qr) {
+			t.Errorf("got %d %s", code, out)
+		}
+		if svg, err := os.ReadFile(qr); err != nil || !strings.HasPrefix(string(svg), "<svg") {
+			t.Errorf("unexpected qr code %s %v", svg, err)
+		}
+		png := filepath.Join(t.TempDir(), "qr.png")
+		runCLI(t, "", "encode", "-recipient", recipient, "-amount", "2", "-qr", png)
+		code, out = runCLI(t, "", "scan", png)
+		if code != EXIT_OK || !strings.Contains(out, `"payload": "solana:`+recipient+`?amount=2"`) || !strings.Contains(out, `"type": "transfer"`) {
+			t.Errorf("got %d %s", code, out)
+		}
+		code, out = runCLI(t, "", "encode", "-recipient", recipient, "-reference", recipient, "-reference", "EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG")
+		if want := "?reference=" + recipient + "&reference=EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG"; code != EXIT_OK || !strings.Contains(out, want) {
+			t.Errorf("got %d %s want %s", code, out, want)
+		}
+		if code, _ := runCLI(t, "", "encode", "-recipient", "nope"); code != EXIT_FAILURE {
+			t.Errorf("got %d want %d", code, EXIT_FAILURE)
+		}
+	})
+
+	t.Run("open and validate refuse transfer request URLs", func(t *testing.T) {
+		transfer := "solana:" + recipient + "?amount=1"
+		for _, args := range [][]string{{"open", "-keypair", "missing.json", transfer}, {"validate", transfer}} {
+			var stdout, stderr bytes.Buffer
+			c := &cli{stdin: bufio.NewReader(strings.NewReader("")), stdout: &stdout, stderr: &stderr}
+			code := c.run(args)
+			if out := stdout.String() + stderr.String(); code != EXIT_FAILURE || !strings.Contains(out, "not an action URL") {
+				t.Errorf("%s: got %d %s", args[0], code, out)
+			}
+		}
+	})
+
+	t.Run("encode builds wallet links that parse back", func(t *testing.T) {
+		code, out := runCLI(t, "", "encode", "-link", "https://example.com/api/donate", "-wallet", "phantom")
+		var output map[string]string
+		if err := json.Unmarshal([]byte(out), &output); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		want := "phantom://browse/https%3A%2F%2Fexample.com%2Fapi%2Fdonate?ref=https%3A%2F%2Fexample.com"
+		if code != EXIT_OK || output["deepLink"] != want {
+			t.Errorf("got %d %s want %s", code, out, want)
+		}
+		if code, out := runCLI(t, "", "parse", output["universalLink"]); code != EXIT_OK || !strings.Contains(out, `"link": "https://example.com/api/donate"`) {
+			t.Errorf("got %d %s", code, out)
+		}
+		if code, _ := runCLI(t, "", "encode", "-link", "https://example.com/api/donate", "-wallet", "unknown"); code != EXIT_FAILURE {
+			t.Errorf("got %d want %d", code, EXIT_FAILURE)
+		}
+	})
+	t.Run("find-reference prints the confirmation status", func(t *testing.T) {
+		rpcServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				ID     int    `json:"id"`
+				Method string `json:"method"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			var result any
+			switch req.Method {
+			case "getSignaturesForAddress":
+				result = []map[string]any{{"signature": "5sig", "slot": 42, "err": nil}}
+			case "getSignatureStatuses":
+				result = map[string]any{"context": map[string]any{"slot": 43}, "value": []any{map[string]any{"slot": 42, "confirmationStatus": "finalized"}}}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+		}))
+		defer rpcServer.Close()
+
+		code, out := runCLI(t, "", "find-reference", "-url", rpcServer.URL, recipient)
+		var output findReferenceOutput
+		if err := json.Unmarshal([]byte(out), &output); err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		if code != EXIT_OK || output.Signature != "5sig" || output.Status != "ok" || output.ConfirmationStatus != "finalized" {
+			t.Errorf("got %d %s", code, out)
+		}
+	})
+}
+
+func TestServe(t *testing.T) {
+	rpcServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID any `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": map[string]any{
+			"context": map[string]any{"slot": 1},
+			"value":   map[string]any{"blockhash": "EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG", "lastValidBlockHeight": 100},
+		}})
+	}))
+	defer rpcServer.Close()
+
+	config := &serveConfig{Cluster: "devnet", Actions: []serveAction{{
+		Path:     "/api/donate",
+		Action:   *actions.NewAction("https://example.com/icon.png", "Donate", "Donate SOL", "Donate 0.1 SOL"),
+		Transfer: &serveTransfer{Recipient: "4uhcVJyU9pJkvQyS88uRDiswHXSCkY3zQawwpjk2NsNY", Amount: "0.1"},
+	}}}
+	handler, err := newServeHandler(config, client.NewClient(rpcServer.URL))
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err.Error())
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	t.Run("serves actions.json", func(t *testing.T) {
+		res, err := http.Get(server.URL + "/actions.json")
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		var actionsJson actions.ActionsJson
+		json.NewDecoder(res.Body).Decode(&actionsJson)
+		if len(actionsJson.Rules) != 1 || actionsJson.Rules[0].ApiPath != "/api/donate" {
+			t.Errorf("unexpected rules %+v", actionsJson.Rules)
+		}
+	})
+
+	t.Run("returns the transfer transaction", func(t *testing.T) {
+		body := strings.NewReader(`{"account": "EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG"}`)
+		res, err := http.Post(server.URL+"/api/donate?amount=0.25", "application/json", body)
+		if err != nil {
+			t.Fatalf("err should be nil: %s", err.Error())
+		}
+		var resp actions.ActionPostResponse
+		json.NewDecoder(res.Body).Decode(&resp)
+		if res.StatusCode != http.StatusOK || resp.Transaction == "" {
+			t.Errorf("got %d %+v", res.StatusCode, resp)
+		}
+		if ids := res.Header.Get(actions.BLOCKCHAIN_IDS_HEADER); ids != actions.CLUSTER_DEVNET.BlockchainID() {
+			t.Errorf("got %q want the devnet blockchain ID", ids)
+		}
+	})
+
+	t.Run("validate lints the served action", func(t *testing.T) {
+		tlsServer := httptest.NewTLSServer(handler)
+		defer tlsServer.Close()
+		defaultClient := http.DefaultClient
+		http.DefaultClient = tlsServer.Client()
+		t.Cleanup(func() { http.DefaultClient = defaultClient })
+
+		code, out := runCLI(t, "", "validate", tlsServer.URL+"/api/donate")
+		var output struct {
+			Status  actions.LintStatus   `json:"status"`
+			Results []actions.LintResult `json:"results"`
+		}
+		json.Unmarshal([]byte(out), &output)
+		if code != EXIT_OK || output.Status != actions.LINT_PASS || len(output.Results) == 0 {
+			t.Errorf("got %d %s", code, out)
+		}
+	})
+
+	t.Run("rejects invalid configs", func(t *testing.T) {
+		invalid := &serveConfig{Actions: []serveAction{{Path: "api", Action: config.Actions[0].Action}}}
+		if _, err := newServeHandler(invalid, nil); err == nil {
+			t.Error("expected an error")
+		}
+		unknownCluster := &serveConfig{Cluster: "localnet", Actions: config.Actions}
+		if _, err := newServeHandler(unknownCluster, nil); err == nil {
+			t.Error("expected an error for an unknown cluster")
+		}
+	})
+}
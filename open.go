@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"solana-actions/actions"
+	"strconv"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Keypair of the Solana CLI
+func defaultKeypairPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "id.json"
+	}
+	return filepath.Join(home, ".config", "solana", "id.json")
+}
+
+func runOpen(c *cli, args []string) int {
+	flags := flag.NewFlagSet("open", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	keypairPath := flags.String("keypair", defaultKeypairPath(), "keypair file signing the transactions")
+	rpcURL := flags.String("url", rpc.MainnetRPCEndpoint, "RPC endpoint or cluster name, several comma-separated endpoints are pooled")
+	registryPath := flags.String("registry", "", "registry file, actions registered as malicious are refused")
+	if err := flags.Parse(args); err != nil {
+		return EXIT_USAGE
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(c.stderr, "usage: blink open [flags] <url>")
+		return EXIT_USAGE
+	}
+
+	var checkers []actions.ActionURLChecker
+	if *registryPath != "" {
+		registry, err := actions.LoadRegistryFile(*registryPath)
+		if err != nil {
+			return c.fail(err)
+		}
+		checkers = append(checkers, registry)
+	}
+	ctx := context.Background()
+	link, err := resolveActionLink(ctx, flags.Arg(0), checkers)
+	if err != nil {
+		return c.fail(err)
+	}
+	account, err := loadKeypair(*keypairPath)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	conn, err := newRPCClient(*rpcURL)
+	if err != nil {
+		return c.fail(err)
+	}
+	session := &openSession{
+		cli:      c,
+		conn:     conn,
+		signer:   actions.NewKeypairSigner(account),
+		checkers: checkers,
+	}
+	return session.run(ctx, link)
+}
+
+/*
+Resolve the Action API URL of any supported URL form: a Solana Action URL, a
+blink, a website mapped by its actions.json, or the Action API URL itself.
+*/
+func resolveActionLink(ctx context.Context, raw string, checkers []actions.ActionURLChecker) (*url.URL, error) {
+	link, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := actions.ParseURL(link)
+	if err == nil {
+		switch f := fields.(type) {
+		case *actions.ActionRequestURLFields:
+			return f.Link, nil
+		case *actions.BlinkURLFields:
+			return f.Action.Link, nil
+		}
+		return nil, errors.New("not an action URL")
+	}
+	if link.Scheme != actions.HTTPS_PROTOCOL {
+		return nil, err
+	}
+	for _, checker := range checkers {
+		if err := checker.CheckActionURL(link); err != nil {
+			return nil, err
+		}
+	}
+	if actionsJson, err := actions.FetchActionsJsonWithContext(ctx, link); err == nil {
+		if apiURL, ok := actionsJson.ResolveActionURL(link); ok {
+			return apiURL, nil
+		}
+	}
+	return link, nil
+}
+
+// Reads a keypair file of the Solana CLI, a JSON array of the 64 secret key bytes
+func loadKeypair(path string) (types.Account, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return types.Account{}, err
+	}
+	var key []byte
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return types.Account{}, fmt.Errorf("invalid keypair file %s", path)
+	}
+	return types.AccountFromBytes(key)
+}
+
+// Executes an action and the actions chained after it
+type openSession struct {
+	cli      *cli
+	conn     actions.RPCClient
+	signer   actions.Signer
+	checkers []actions.ActionURLChecker
+}
+
+func (s *openSession) run(ctx context.Context, link *url.URL) int {
+	action, err := actions.FetchActionWithOptions(ctx, link, &actions.FetchActionOptions{URLCheckers: s.checkers, Conn: s.conn})
+	if err != nil {
+		return s.cli.fail(err)
+	}
+	for action != nil {
+		renderAction(s.cli, action)
+		if !action.IsInteractive() {
+			return EXIT_OK
+		}
+		linked, values, err := s.choose(action)
+		if err != nil {
+			return s.cli.fail(err)
+		}
+		href, err := linked.ResolveHref(link, values)
+		if err != nil {
+			return s.cli.fail(err)
+		}
+
+		// The blockhash is fetched from the endpoint the transaction is sent to
+		conn := actions.PinRPC(s.conn)
+		resp, err := actions.FetchTransactionWithOptions(ctx, conn, href, actions.ActionPostRequest{Account: s.signer.PublicKey().String()}, &actions.FetchTransactionOptions{
+			Commitment:  rpc.CommitmentConfirmed,
+			URLCheckers: s.checkers,
+		})
+		if err != nil {
+			return s.cli.fail(err)
+		}
+		if resp.Message != nil && *resp.Message != "" {
+			fmt.Fprintf(s.cli.stdout, "\n%s\n", *resp.Message)
+		}
+
+		var callback *actions.NextActionPostRequest
+		if resp.ResponseType() == actions.POST_RESPONSE_TYPE_MESSAGE {
+			callback, err = s.signMessage(resp)
+		} else {
+			callback, err = s.sendTransaction(ctx, conn, resp, linked.Label)
+		}
+		if err != nil {
+			return s.cli.fail(err)
+		}
+		if callback == nil {
+			fmt.Fprintln(s.cli.stdout, "Cancelled.")
+			return EXIT_FAILURE
+		}
+		if action.ActionType() == actions.ACTION_TYPE_EXTERNAL_LINK {
+			if externalLink, err := action.ExternalLinkURL(); err == nil {
+				fmt.Fprintf(s.cli.stdout, "Continue at %s\n", externalLink)
+			}
+		}
+
+		if action, err = actions.FetchNextActionWithContext(ctx, href, &resp.ActionPostResponse, *callback); err != nil {
+			return s.cli.fail(err)
+		}
+		link = href
+	}
+	return EXIT_OK
+}
+
+// Prompt for the button to execute and its parameters
+func (s *openSession) choose(action *actions.ActionGetResponse) (*actions.LinkedAction, map[string]string, error) {
+	buttons := action.LinkedActions()
+	index := 0
+	if len(buttons) > 1 {
+		for {
+			answer, err := s.cli.prompt(fmt.Sprintf("Choose an action [1-%d]: ", len(buttons)))
+			if err != nil {
+				return nil, nil, err
+			}
+			if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(buttons) {
+				index = n - 1
+				break
+			}
+		}
+	}
+
+	linked := &buttons[index]
+	values := map[string]string{}
+	if linked.Parameters != nil {
+		for _, param := range *linked.Parameters {
+			label := param.Name
+			if param.Label != nil {
+				label = *param.Label
+			}
+			for {
+				value, err := s.cli.prompt(label + ": ")
+				if err != nil {
+					return nil, nil, err
+				}
+				if value != "" || param.Required == nil || !*param.Required {
+					values[param.Name] = value
+					break
+				}
+			}
+		}
+	}
+	return linked, values, nil
+}
+
+// Preview, confirm, sign and send the transaction, nil when the user declines
+func (s *openSession) sendTransaction(ctx context.Context, conn actions.RPCClient, resp *actions.ActionPostResponseWithSerializedTransaction, label string) (*actions.NextActionPostRequest, error) {
+	tx := resp.Transaction
+	if err := previewTransaction(s.cli, &tx, s.signer.PublicKey(), label); err != nil {
+		return nil, err
+	}
+	ok, err := s.cli.confirm("Sign and send the transaction?")
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	result, err := actions.SendAndConfirm(ctx, conn, &tx, s.signer, &actions.SendAndConfirmOptions{
+		LastValidBlockHeight: resp.LastValidBlockHeight,
+	})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(s.cli.stdout, "Transaction %s: %s\n", result.Status, result.Signature)
+	if result.Status != actions.SEND_STATUS_CONFIRMED {
+		return nil, fmt.Errorf("transaction %s", result.Status)
+	}
+	return &actions.NextActionPostRequest{Account: s.signer.PublicKey().String(), Signature: result.Signature}, nil
+}
+
+// Show, confirm and sign the message of a `message` action, nil when the user declines
+func (s *openSession) signMessage(resp *actions.ActionPostResponseWithSerializedTransaction) (*actions.NextActionPostRequest, error) {
+	fmt.Fprintf(s.cli.stdout, "\nMessage to sign:\n\n%s\n\n", resp.Data.Message())
+	ok, err := s.cli.confirm("Sign the message?")
+	if err != nil || !ok {
+		return nil, err
+	}
+	sig, err := actions.SignMessage(resp.Data, s.signer)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(s.cli.stdout, "Message signed: %s\n", sig)
+	return &actions.NextActionPostRequest{Account: s.signer.PublicKey().String(), Signature: sig, Data: resp.Data}, nil
+}
+
+// Print the action as a card with numbered buttons
+func renderAction(c *cli, action *actions.ActionGetResponse) {
+	fmt.Fprintf(c.stdout, "\n%s\n", action.Title)
+	if action.Description != "" {
+		fmt.Fprintf(c.stdout, "%s\n", action.Description)
+	}
+	if action.Error != nil {
+		fmt.Fprintf(c.stdout, "! %s\n", action.Error.Message)
+	}
+	fmt.Fprintln(c.stdout)
+	state := ""
+	if !action.IsInteractive() {
+		state = " (disabled)"
+	}
+	for i, linked := range action.LinkedActions() {
+		fmt.Fprintf(c.stdout, "  [%d] %s%s\n", i+1, linked.Label, state)
+		if linked.Parameters != nil {
+			for _, param := range *linked.Parameters {
+				required := ""
+				if param.Required != nil && *param.Required {
+					required = ", required"
+				}
+				fmt.Fprintf(c.stdout, "      - %s%s\n", param.Name, required)
+			}
+		}
+	}
+}
+
+// Print the decoded instructions and the risk findings of a transaction
+func previewTransaction(c *cli, tx *types.Transaction, account common.PublicKey, label string) error {
+	decoded, err := actions.DecodeTransaction(tx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "\nTransaction preview:")
+	for _, ix := range decoded {
+		fmt.Fprintf(c.stdout, "  %d. %s\n", ix.Index+1, ix.Summary)
+	}
+
+	findings, err := actions.Analyze(tx, account, &actions.AnalyzeOptions{Label: label})
+	if err != nil {
+		return err
+	}
+	if len(findings) > 0 {
+		fmt.Fprintln(c.stdout, "\nWarnings:")
+		for _, finding := range findings {
+			fmt.Fprintf(c.stdout, "  [%s] %s\n", finding.Severity, finding.Reason)
+		}
+	}
+	fmt.Fprintln(c.stdout)
+	return nil
+}
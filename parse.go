@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"solana-actions/actions"
+	"strings"
+
+	"github.com/blocto/solana-go-sdk/common"
+)
+
+// Fields of a Solana Action URL, with the link as a string
+type actionURLOutput struct {
+	Link    string  `json:"link"`
+	Label   *string `json:"label,omitempty"`
+	Message *string `json:"message,omitempty"`
+}
+
+// Output of `parse`, `type` is `action`, `blink` or `transfer`
+type parseOutput struct {
+	Type     string                            `json:"type"`
+	Blink    string                            `json:"blink,omitempty"`
+	Action   *actionURLOutput                  `json:"action,omitempty"`
+	Transfer *actions.TransferRequestURLFields `json:"transfer,omitempty"`
+}
+
+func newActionURLOutput(fields *actions.ActionRequestURLFields) *actionURLOutput {
+	return &actionURLOutput{Link: fields.Link.String(), Label: fields.Label, Message: fields.Message}
+}
+
+func runParse(c *cli, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(c.stderr, "usage: blink parse <url>")
+		return EXIT_USAGE
+	}
+	link, err := url.Parse(args[0])
+	if err != nil {
+		return c.failJSON(err)
+	}
+	fields, err := actions.ParseURL(link)
+	if err != nil {
+		return c.failJSON(err)
+	}
+	output, err := newParseOutput(fields)
+	if err != nil {
+		return c.failJSON(err)
+	}
+	return c.writeJSON(output)
+}
+
+// Output of the fields returned by `actions.ParseURL`
+func newParseOutput(fields any) (*parseOutput, error) {
+	switch f := fields.(type) {
+	case *actions.ActionRequestURLFields:
+		return &parseOutput{Type: "action", Action: newActionURLOutput(f)}, nil
+	case *actions.BlinkURLFields:
+		return &parseOutput{Type: "blink", Blink: f.Blink.String(), Action: newActionURLOutput(&f.Action)}, nil
+	case *actions.TransferRequestURLFields:
+		return &parseOutput{Type: "transfer", Transfer: f}, nil
+	}
+	return nil, fmt.Errorf("unsupported URL")
+}
+
+// Output of `scan`, the fields of the payload
+type scanOutput struct {
+	Payload string `json:"payload"`
+	*parseOutput
+}
+
+func runScan(c *cli, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(c.stderr, "usage: blink scan <image>")
+		return EXIT_USAGE
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return c.failJSON(err)
+	}
+	defer file.Close()
+	fields, payload, err := actions.ScanQRCode(file)
+	if err != nil {
+		if payload != "" {
+			c.writeJSON(map[string]string{"payload": payload, "error": err.Error()})
+			return EXIT_FAILURE
+		}
+		return c.failJSON(err)
+	}
+	output, err := newParseOutput(fields)
+	if err != nil {
+		return c.failJSON(err)
+	}
+	return c.writeJSON(scanOutput{Payload: payload, parseOutput: output})
+}
+
+func runEncode(c *cli, args []string) int {
+	flags := flag.NewFlagSet("encode", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	link := flags.String("link", "", "action link, encodes a Solana Action URL")
+	blink := flags.String("blink", "", "blink base URL, encodes a blink of -link")
+	recipient := flags.String("recipient", "", "recipient, encodes a transfer request URL")
+	amount := flags.String("amount", "", "transfer amount")
+	splToken := flags.String("spl-token", "", "mint of the transferred token")
+	var references keyList
+	flags.Var(&references, "reference", "transfer reference, repeat the flag for several references")
+	label := flags.String("label", "", "label")
+	message := flags.String("message", "", "message")
+	memo := flags.String("memo", "", "transfer memo")
+	qrPath := flags.String("qr", "", "write a QR code of the URL, SVG for a .svg path and PNG otherwise")
+	qrLevel := flags.String("qr-level", string(actions.QR_LEVEL_Q), "error correction level of the QR code: L, M, Q or H")
+	qrSize := flags.Int("qr-size", actions.QR_DEFAULT_SIZE, "size in pixels of the QR code")
+	wallet := flags.String("wallet", "", "also output the links opening the action in a wallet: phantom, solflare or backpack")
+	walletScheme := flags.String("wallet-scheme", string(actions.WALLET_LINK_BROWSE), "scheme of the wallet links: browse or action")
+	if err := flags.Parse(args); err != nil {
+		return EXIT_USAGE
+	}
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	optional := func(name string, value *string) *string {
+		if set[name] {
+			return value
+		}
+		return nil
+	}
+	if flags.NArg() != 0 || (*link == "") == (*recipient == "") {
+		fmt.Fprintln(c.stderr, "usage: blink encode -link <url> [-blink <url>] | -recipient <key> [flags]")
+		return EXIT_USAGE
+	}
+
+	var fields any
+	protocol := actions.SOLANA_ACTIONS_PROTOCOL
+	if *recipient != "" {
+		transfer, err := transferFields(*recipient, optional("amount", amount), optional("spl-token", splToken), references)
+		if err != nil {
+			return c.failJSON(err)
+		}
+		transfer.Label, transfer.Message, transfer.Memo = optional("label", label), optional("message", message), optional("memo", memo)
+		fields, protocol = transfer, actions.SOLANA_PAY_PROTOCOL
+	} else {
+		actionLink, err := url.Parse(*link)
+		if err != nil {
+			return c.failJSON(err)
+		}
+		action := &actions.ActionRequestURLFields{Link: actionLink, Label: optional("label", label), Message: optional("message", message)}
+		fields = action
+		if *blink != "" {
+			blinkURL, err := url.Parse(*blink)
+			if err != nil {
+				return c.failJSON(err)
+			}
+			fields = &actions.BlinkURLFields{Blink: blinkURL, Action: *action}
+		}
+	}
+
+	encoded, err := actions.EncodeUrl(fields, protocol)
+	if err != nil {
+		return c.failJSON(err)
+	}
+	output := map[string]string{"url": encoded.String()}
+	if *wallet != "" {
+		link, err := actions.NewWalletLink(fields, *wallet, actions.WalletLinkScheme(*walletScheme), nil)
+		if err != nil {
+			return c.failJSON(err)
+		}
+		output["universalLink"], output["deepLink"] = link.UniversalLink.String(), link.DeepLink.String()
+	}
+	if *qrPath != "" {
+		if err := writeQRCode(encoded, *qrPath, actions.QRCodeLevel(*qrLevel), *qrSize); err != nil {
+			return c.failJSON(err)
+		}
+		output["qr"] = *qrPath
+	}
+	return c.writeJSON(output)
+}
+
+// Writes the QR code of `link` with the Solana Pay styling
+func writeQRCode(link *url.URL, path string, level actions.QRCodeLevel, size int) error {
+	options := actions.NewSolanaPayQRCodeOptions(nil)
+	options.Level, options.Size = level, size
+	q, err := actions.NewQRCode(link, options)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(strings.ToLower(path), ".svg") {
+		return os.WriteFile(path, []byte(q.SVG()), 0o644)
+	}
+	raw, err := q.PNG()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0o644)
+}
+
+func transferFields(recipient string, amount *string, splToken *string, references []string) (*actions.TransferRequestURLFields, error) {
+	fields := &actions.TransferRequestURLFields{}
+	var err error
+	if amount != nil {
+		value, err := actions.ParseAmount(*amount)
+		if err != nil {
+			return nil, errors.New("invalid amount")
+		}
+		fields.Amount = &value
+	}
+	if fields.Recipient, err = parseKey("recipient", recipient); err != nil {
+		return nil, err
+	}
+	if splToken != nil {
+		mint, err := parseKey("spl-token", *splToken)
+		if err != nil {
+			return nil, err
+		}
+		fields.SplToken = &mint
+	}
+	for _, reference := range references {
+		key, err := parseKey("reference", reference)
+		if err != nil {
+			return nil, err
+		}
+		fields.Reference = append(fields.Reference, actions.Reference(key))
+	}
+	return fields, nil
+}
+
+// Values of a repeated flag
+type keyList []string
+
+func (l *keyList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *keyList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Parses a base58 public key, `common.PublicKeyFromString` doesn't report invalid keys
+func parseKey(name string, value string) (common.PublicKey, error) {
+	key := common.PublicKeyFromString(value)
+	if key.String() != value {
+		return common.PublicKey{}, errors.New("invalid " + name)
+	}
+	return key, nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"solana-actions/actions"
+	"strings"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/memo"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// Config file of `serve`
+type serveConfig struct {
+	// Cluster of the transactions, `mainnet`, `devnet`, `testnet` or a CAIP-2 ID, declared in `X-Blockchain-Ids`
+	Cluster string `json:"cluster,omitempty"`
+
+	Actions []serveAction `json:"actions"`
+}
+
+// Action served at `path`
+type serveAction struct {
+	Path   string                    `json:"path"`
+	Action actions.ActionGetResponse `json:"action"`
+
+	// SOL transfer returned on POST, the action can't be executed without it
+	Transfer *serveTransfer `json:"transfer,omitempty"`
+
+	// Message returned with the transaction
+	Message string `json:"message,omitempty"`
+}
+
+type serveTransfer struct {
+	Recipient string `json:"recipient"`
+
+	// Amount of SOL, overridden by the `amount` query param of the request
+	Amount string `json:"amount,omitempty"`
+
+	Memo string `json:"memo,omitempty"`
+}
+
+func runServe(c *cli, args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	configPath := flags.String("config", "actions.config.json", "config file of the served actions")
+	addr := flags.String("addr", ":8080", "listen address")
+	rpcURL := flags.String("url", rpc.MainnetRPCEndpoint, "RPC endpoint or cluster name used for blockhashes, several comma-separated endpoints are pooled")
+	if err := flags.Parse(args); err != nil {
+		return EXIT_USAGE
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintln(c.stderr, "usage: blink serve [flags]")
+		return EXIT_USAGE
+	}
+
+	config, err := loadServeConfig(*configPath)
+	if err != nil {
+		return c.failJSON(err)
+	}
+	conn, err := newRPCClient(*rpcURL)
+	if err != nil {
+		return c.failJSON(err)
+	}
+	handler, err := newServeHandler(config, conn)
+	if err != nil {
+		return c.failJSON(err)
+	}
+	c.writeJSON(map[string]any{"listening": *addr, "actions": len(config.Actions)})
+	if err := http.ListenAndServe(*addr, handler); err != nil {
+		return c.failJSON(err)
+	}
+	return EXIT_OK
+}
+
+func loadServeConfig(path string) (*serveConfig, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var config serveConfig
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %s", path, err)
+	}
+	return &config, nil
+}
+
+// Handler serving the configured actions and the actions.json mapping them
+func newServeHandler(config *serveConfig, conn actions.RPCClient) (http.Handler, error) {
+	mux := http.NewServeMux()
+	actionsJson := actions.ActionsJson{Rules: []actions.ActionRuleObject{}}
+	options := &actions.ActionHandlerOptions{}
+	if config.Cluster != "" {
+		cluster, err := actions.ParseCluster(config.Cluster)
+		if err != nil {
+			return nil, err
+		}
+		options.Clusters = []actions.Cluster{cluster}
+	}
+	for i := range config.Actions {
+		entry := &config.Actions[i]
+		if !strings.HasPrefix(entry.Path, "/") {
+			return nil, fmt.Errorf("action %d: path must start with /", i)
+		}
+		if err := entry.Action.Validate(); err != nil {
+			return nil, fmt.Errorf("action %s: %s", entry.Path, err)
+		}
+		if entry.Transfer != nil {
+			if _, err := parseKey("recipient", entry.Transfer.Recipient); err != nil {
+				return nil, fmt.Errorf("action %s: %s", entry.Path, err)
+			}
+		}
+		handler, err := actions.NewActionHandlerWithOptions(&staticProvider{entry, conn}, options)
+		if err != nil {
+			return nil, err
+		}
+		mux.Handle(entry.Path, handler)
+		actionsJson.Rules = append(actionsJson.Rules, actions.ActionRuleObject{PathPattern: entry.Path, ApiPath: entry.Path})
+	}
+	mux.HandleFunc("/actions.json", func(w http.ResponseWriter, r *http.Request) {
+		for key, value := range actions.ACTIONS_CORS_HEADERS {
+			w.Header().Set(key, value)
+		}
+		json.NewEncoder(w).Encode(actionsJson)
+	})
+	return mux, nil
+}
+
+// Serves a configured action, POST returns its SOL transfer
+type staticProvider struct {
+	entry *serveAction
+	conn  actions.RPCClient
+}
+
+func (p *staticProvider) GetAction(r *http.Request) (*actions.ActionGetResponse, error) {
+	action := p.entry.Action
+	return &action, nil
+}
+
+func (p *staticProvider) PostAction(r *http.Request, req actions.ActionPostRequest) (*actions.ActionPostResponse, error) {
+	transfer := p.entry.Transfer
+	if transfer == nil {
+		return nil, &actions.ActionError{Message: "this action has no transaction"}
+	}
+	amount := transfer.Amount
+	if r.URL.Query().Has("amount") {
+		amount = r.URL.Query().Get("amount")
+	}
+	lamports, err := parseLamports(amount)
+	if err != nil {
+		return nil, &actions.ActionError{Message: err.Error()}
+	}
+
+	account := common.PublicKeyFromString(req.Account)
+	instructions := []types.Instruction{system.Transfer(system.TransferParam{
+		From:   account,
+		To:     common.PublicKeyFromString(transfer.Recipient),
+		Amount: lamports,
+	})}
+	if transfer.Memo != "" {
+		instructions = append(instructions, memo.BuildMemo(memo.BuildMemoParam{Memo: []byte(transfer.Memo)}))
+	}
+	blockhash, err := p.conn.GetLatestBlockhashWithConfig(context.Background(), client.GetLatestBlockhashConfig{})
+	if err != nil {
+		return nil, err
+	}
+	msg := types.NewMessage(types.NewMessageParam{FeePayer: account, RecentBlockhash: blockhash.Blockhash, Instructions: instructions})
+	tx := types.Transaction{Message: msg}
+	for i := 0; i < int(msg.Header.NumRequireSignatures); i++ {
+		tx.Signatures = append(tx.Signatures, make(types.Signature, 64))
+	}
+	raw, err := tx.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &actions.ActionPostResponse{Transaction: base64.StdEncoding.EncodeToString(raw)}
+	if p.entry.Message != "" {
+		resp.Message = &p.entry.Message
+	}
+	return resp, nil
+}
+
+// Parses a decimal amount of SOL into lamports
+func parseLamports(amount string) (uint64, error) {
+	sol, err := actions.ParseAmount(amount)
+	if err != nil || sol.IsZero() {
+		return 0, errors.New("invalid amount")
+	}
+	lamports, err := sol.Lamports()
+	if err != nil {
+		return 0, errors.New("invalid amount")
+	}
+	return lamports, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"solana-actions/actions"
+)
+
+func runValidate(c *cli, args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	rpcURL := flags.String("url", "", "RPC endpoint or cluster name checking the blockhash of the transaction, skipped when empty, several comma-separated endpoints are pooled")
+	if err := flags.Parse(args); err != nil {
+		return EXIT_USAGE
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(c.stderr, "usage: blink validate [flags] <url>")
+		return EXIT_USAGE
+	}
+	ctx := context.Background()
+	link, err := resolveActionLink(ctx, flags.Arg(0), nil)
+	if err != nil {
+		return c.failJSON(err)
+	}
+
+	options := &actions.LintOptions{}
+	if *rpcURL != "" {
+		conn, err := newRPCClient(*rpcURL)
+		if err != nil {
+			return c.failJSON(err)
+		}
+		options.Conn = conn
+	}
+	report := actions.LintWithOptions(ctx, link, options)
+	c.writeJSON(map[string]any{"url": report.URL, "status": report.Status(), "results": report.Results})
+	if !report.Passed() {
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
+}